@@ -16,6 +16,8 @@ type DryRun struct {
 	config config.Config
 	cycle  int
 	table  bool
+	output string
+	format string
 }
 
 // NewDryRun returns a new dryrun
@@ -50,6 +52,7 @@ func NewDryRun(cycle string, table bool) DryRun {
 // DryRunCommand returns the cobra command for dryrun
 func DryRunCommand() *cobra.Command {
 	var table bool
+	var output, format string
 
 	var dryrun = &cobra.Command{
 		Use:     "dryrun",
@@ -62,20 +65,34 @@ func DryRunCommand() *cobra.Command {
 			}
 
 			dryrun := NewDryRun(args[0], table)
+			dryrun.output = output
+			dryrun.format = format
 			dryrun.execute()
 		},
 	}
 	dryrun.PersistentFlags().BoolVarP(&table, "table", "t", false, "formats result into a table (Default: json)")
+	dryrun.PersistentFlags().StringVarP(&output, "output", "o", "", "also writes the payout to a csv or json lines file")
+	dryrun.PersistentFlags().StringVar(&format, "format", "", "csv or jsonl (Default: from the output file extension)")
 
 	return dryrun
 }
 
 func (d *DryRun) execute() {
+	if d.output != "" {
+		if _, err := print.ValidateExport(d.output, d.format); err != nil {
+			log.WithField("error", err.Error()).Fatal("Failed to validate export.")
+		}
+	}
+
 	rewardsSplit, err := d.payout.Execute()
 	if err != nil {
 		log.WithField("error", err.Error()).Fatal("Failed to execute payout.")
 	}
 
+	if d.output != "" {
+		export(d.config, rewardsSplit, d.output, d.format)
+	}
+
 	if d.table {
 		print.Table(d.cycle, d.config.Baker.Address, rewardsSplit)
 	} else {
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/payout"
+	"github.com/goat-systems/tzpay/v3/internal/print"
+	"github.com/goat-systems/tzpay/v3/internal/reconcile"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// ExportCommand returns the cobra command for export
+func ExportCommand() *cobra.Command {
+	var output, format string
+	var fromLevel, toLevel int
+
+	var export = &cobra.Command{
+		Use:     "export",
+		Short:   "export writes a payout to a csv or json lines file for accounting",
+		Long:    "export computes the payout for a cycle, matches it with the transfers made on chain and writes a row per delegator and a summary row to a csv or json lines file",
+		Example: `tzpay export <cycle> --output payouts-270.csv`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				log.Fatal("Missing cycle as argument.")
+			}
+
+			if output == "" {
+				log.Fatal("Missing output file.")
+			}
+
+			if _, err := print.ValidateExport(output, format); err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to validate export.")
+			}
+
+			cycle, err := strconv.Atoi(args[0])
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to parse cycle argument into integer.")
+			}
+
+			config, err := config.New()
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to load config.")
+			}
+
+			payout, err := payout.New(config, cycle, false, false)
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to intialize payout.")
+			}
+
+			rewardsSplit, err := payout.Execute()
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to execute payout.")
+			}
+
+			reconciler, err := reconcile.New(config, cycle, &reconcile.StaticPayout{RewardsSplit: rewardsSplit}, fromLevel, toLevel)
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to intialize reconciliation.")
+			}
+
+			report, err := reconciler.Execute()
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to reconcile payout.")
+			}
+
+			export(config, withTransfers(rewardsSplit, report), output, format)
+		},
+	}
+
+	export.PersistentFlags().StringVarP(&output, "output", "o", "", "file to write the export to")
+	export.PersistentFlags().StringVar(&format, "format", "", "csv or jsonl (Default: from the output file extension)")
+	export.PersistentFlags().IntVar(&fromLevel, "from-level", 0, "first level to search for transfers (Default: first level of the cycle the payout is due)")
+	export.PersistentFlags().IntVar(&toLevel, "to-level", 0, "last level to search for transfers (Default: last level of the cycle the payout is due)")
+
+	return export
+}
+
+/*
+withTransfers sets the operation hashes of the transfers found on chain and values each line at the
+price of a tez when it was paid.
+*/
+func withTransfers(rewardsSplit tzkt.RewardsSplit, report reconcile.Report) tzkt.RewardsSplit {
+	hashes := map[string]string{}
+	quotes := map[string]tzkt.Fiat{}
+	operations := map[string]struct{}{}
+	var last tzkt.Fiat
+	for _, entry := range report.Entries {
+		var entryHashes []string
+		for _, transfer := range entry.Transfers {
+			entryHashes = append(entryHashes, transfer.Hash)
+			if _, ok := operations[transfer.Hash]; !ok {
+				operations[transfer.Hash] = struct{}{}
+				rewardsSplit.OperationLink = append(rewardsSplit.OperationLink, "https://tzkt.io/"+transfer.Hash)
+				last = transfer.Quotes
+			}
+		}
+		hashes[entry.Address] = strings.Join(entryHashes, " ")
+		if len(entry.Transfers) > 0 {
+			quotes[entry.Address] = entry.Transfers[0].Quotes
+		}
+	}
+
+	valueAt := func(valuation *tzkt.Valuation, mutez int, quotes tzkt.Fiat) *tzkt.Valuation {
+		if valuation == nil {
+			valuation = &tzkt.Valuation{}
+		}
+		valuation.Injection = quotes.Value(mutez)
+		return valuation
+	}
+
+	for i := range rewardsSplit.Delegators {
+		delegator := &rewardsSplit.Delegators[i]
+		for j := range delegator.LiquidityProviders {
+			liquidityProvider := &delegator.LiquidityProviders[j]
+			liquidityProvider.OperationHash = hashes[liquidityProvider.Address]
+			liquidityProvider.Fiat = valueAt(liquidityProvider.Fiat, liquidityProvider.Amount(), quotes[liquidityProvider.Address])
+		}
+
+		if delegator.LiquidityProviders == nil {
+			delegator.OperationHash = hashes[delegator.Address]
+			delegator.Fiat = valueAt(delegator.Fiat, delegator.Amount(), quotes[delegator.Address])
+		}
+	}
+
+	if last != nil {
+		if rewardsSplit.Quotes == nil {
+			rewardsSplit.Quotes = &tzkt.Valuation{}
+		}
+		rewardsSplit.Quotes.Injection = last
+		rewardsSplit.BakerRewardsFiat = valueAt(rewardsSplit.BakerRewardsFiat, rewardsSplit.BakerRewards, last)
+		rewardsSplit.BakerCollectedFeesFiat = valueAt(rewardsSplit.BakerCollectedFeesFiat, rewardsSplit.BakerCollectedFees, last)
+	}
+
+	return rewardsSplit
+}
+
+// export writes the payout to output, logging rather than exiting on failure since a run has already paid it
+func export(config config.Config, rewardsSplit tzkt.RewardsSplit, output, format string) {
+	if output == "" {
+		return
+	}
+
+	if err := print.Export(output, format, print.Rows(config.Baker.Address, rewardsSplit)); err != nil {
+		log.WithField("error", err.Error()).Error("Failed to export payout.")
+		return
+	}
+
+	log.WithField("output", output).Info("Payout exported.")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/payout"
+	"github.com/goat-systems/tzpay/v3/internal/pnl"
+	"github.com/goat-systems/tzpay/v3/internal/print"
+	"github.com/goat-systems/tzpay/v3/internal/reconcile"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// PnLCommand returns the cobra command for pnl
+func PnLCommand() *cobra.Command {
+	var table bool
+
+	var pnlCmd = &cobra.Command{
+		Use:     "pnl",
+		Short:   "pnl reports the baker's profit and loss",
+		Long:    "pnl breaks down the baker's income for a cycle or range of cycles: own stake rewards, fees collected, network fees and burns spent on payouts, and double baking, double endorsing and revelation losses, with net profit",
+		Example: `tzpay pnl <cycle|from-to>`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				log.Fatal("Missing cycle or range of cycles as argument.")
+			}
+
+			fromCycle, toCycle, err := pnl.ParseCycles(args[0])
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to parse cycle argument.")
+			}
+
+			config, err := config.New()
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to load config.")
+			}
+
+			statement, err := pnl.New(config, fromCycle, toCycle, func(cycle int) (reconcile.Payout, error) {
+				return payout.New(config, cycle, false, false)
+			})
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to intialize profit and loss.")
+			}
+
+			report, err := statement.Execute()
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to compute profit and loss.")
+			}
+
+			if table {
+				print.PnLTable(report)
+			} else if err := print.PnLJSON(report); err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to print JSON report.")
+			}
+		},
+	}
+
+	pnlCmd.PersistentFlags().BoolVarP(&table, "table", "t", false, "formats result into a table (Default: json)")
+
+	return pnlCmd
+}
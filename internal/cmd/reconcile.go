@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/ledger"
+	"github.com/goat-systems/tzpay/v3/internal/payout"
+	"github.com/goat-systems/tzpay/v3/internal/print"
+	"github.com/goat-systems/tzpay/v3/internal/reconcile"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// ReconcileCommand returns the cobra command for reconcile
+func ReconcileCommand() *cobra.Command {
+	var table bool
+	var recorded, format string
+	var fromLevel, toLevel int
+
+	var reconcileCmd = &cobra.Command{
+		Use:     "reconcile",
+		Short:   "reconcile compares a payout with the transfers made on chain",
+		Long:    "reconcile compares the payout recorded for a cycle, or computed again if none was, with the transfers made on chain and reports whether each delegator was paid, underpaid, overpaid, paid twice or missed, with operation hashes",
+		Example: `tzpay reconcile <cycle>`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				log.Fatal("Missing cycle as argument.")
+			}
+
+			cycle, err := strconv.Atoi(args[0])
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to parse cycle argument into integer.")
+			}
+
+			config, err := config.New()
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to load config.")
+			}
+
+			payout, err := recordedPayout(config, cycle, recorded, format)
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to intialize payout.")
+			}
+
+			reconciler, err := reconcile.New(config, cycle, payout, fromLevel, toLevel)
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to intialize reconciliation.")
+			}
+
+			report, err := reconciler.Execute()
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to reconcile payout.")
+			}
+
+			if table {
+				print.ReconciliationTable(report)
+			} else if err := print.ReconciliationJSON(report); err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to print JSON report.")
+			}
+		},
+	}
+
+	reconcileCmd.PersistentFlags().BoolVarP(&table, "table", "t", false, "formats result into a table (Default: json)")
+	reconcileCmd.PersistentFlags().StringVar(&recorded, "payout", "", "export of the payout as written by run --output or export (Default: the payout recorded in the true up ledger when paying on expected rewards, else the payout computed again)")
+	reconcileCmd.PersistentFlags().StringVar(&format, "format", "", "csv or jsonl (Default: from the payout file extension)")
+	reconcileCmd.PersistentFlags().IntVar(&fromLevel, "from-level", 0, "first level to search for transfers (Default: first level of the cycle the payout is due)")
+	reconcileCmd.PersistentFlags().IntVar(&toLevel, "to-level", 0, "last level to search for transfers (Default: last level of the cycle the payout is due)")
+
+	return reconcileCmd
+}
+
+/*
+recordedPayout returns the payout recorded for cycle: the export at path if set, or what was sent from the cycle's
+expected rewards if they were paid on expectation. If neither was recorded, the payout is computed again.
+*/
+func recordedPayout(cfg config.Config, cycle int, path, format string) (reconcile.Payout, error) {
+	if path != "" {
+		rows, err := print.ReadExport(path, format)
+		if err != nil {
+			return nil, err
+		}
+
+		rewardsSplit := print.RewardsSplit(rows)
+		if rewardsSplit.Cycle != cycle {
+			return nil, errors.Errorf("export '%s' is the payout of cycle %d, not %d", path, rewardsSplit.Cycle, cycle)
+		}
+
+		return &reconcile.StaticPayout{RewardsSplit: rewardsSplit}, nil
+	}
+
+	if cfg.Baker.PayoutMode == config.ExpectedPayouts {
+		expectations, err := ledger.LoadExpectations(cfg.Baker.TrueUpLedger)
+		if err != nil {
+			return nil, err
+		}
+
+		if expectation, ok := expectations.Cycles[cycle]; ok && expectation.Paid != nil {
+			return &reconcile.StaticPayout{RewardsSplit: expectation.RewardsSplit()}, nil
+		}
+	}
+
+	log.WithField("cycle", cycle).Info("No payout was recorded for the cycle, computing it again.")
+	return payout.New(cfg, cycle, false, false)
+}
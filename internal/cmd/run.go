@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"fmt"
 	"strconv"
 
 	"github.com/goat-systems/tzpay/v3/internal/config"
@@ -18,6 +17,8 @@ import (
 type Run struct {
 	config   config.Config
 	table    bool
+	output   string
+	format   string
 	verbose  bool
 	notifier notifier.PayoutNotifier
 }
@@ -63,6 +64,7 @@ func NewRun(table bool, verbose bool) Run {
 func RunCommand() *cobra.Command {
 	var table bool
 	var verbose bool
+	var output, format string
 
 	var run = &cobra.Command{
 		Use:     "run",
@@ -80,17 +82,27 @@ func RunCommand() *cobra.Command {
 			}
 
 			run := NewRun(table, verbose)
+			run.output = output
+			run.format = format
 			run.execute(cycle)
 		},
 	}
 
 	run.PersistentFlags().BoolVarP(&table, "table", "t", false, "formats result into a table (Default: json)")
 	run.PersistentFlags().BoolVarP(&verbose, "verbose", "v", true, "will print confirmations in between injections.")
+	run.PersistentFlags().StringVarP(&output, "output", "o", "", "also writes the payout to a csv or json lines file")
+	run.PersistentFlags().StringVar(&format, "format", "", "csv or jsonl (Default: from the output file extension)")
 
 	return run
 }
 
 func (r *Run) execute(cycle int) {
+	if r.output != "" {
+		if _, err := print.ValidateExport(r.output, r.format); err != nil {
+			log.WithField("error", err.Error()).Fatal("Failed to validate export.")
+		}
+	}
+
 	payout, err := payout.New(r.config, cycle, true, r.verbose)
 	if err != nil {
 		log.WithField("error", err.Error()).Fatal("Failed to intialize payout.")
@@ -101,7 +113,11 @@ func (r *Run) execute(cycle int) {
 		log.WithField("error", err.Error()).Fatal("Failed to execute payout.")
 	}
 
-	err = r.notifier.Notify(fmt.Sprintf("[TZPAY] payout for cycle %d: \n%s\n #tezos #blockchain", cycle, rewardsSplit.OperationLink))
+	if r.output != "" {
+		export(r.config, rewardsSplit, r.output, r.format)
+	}
+
+	err = r.notifier.Notify(notifier.PayoutMessage(cycle, rewardsSplit))
 	if err != nil {
 		log.WithField("error", err.Error()).Error("Failed to notify.")
 	}
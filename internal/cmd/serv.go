@@ -5,6 +5,8 @@ import (
 
 	"github.com/goat-systems/go-tezos/v3/rpc"
 	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/failover"
+	"github.com/goat-systems/tzpay/v3/internal/ledger"
 	"github.com/goat-systems/tzpay/v3/internal/payout"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -24,7 +26,7 @@ func newServer(verbose bool) (server, error) {
 		return server{}, errors.Wrap(err, "failed to load configuration")
 	}
 
-	rpc, err := rpc.New(config.API.Tezos)
+	rpc, err := failover.NewRPC(config.API)
 	if err != nil {
 		return server{}, errors.Wrap(err, "failed to connect to tezos rpc")
 	}
@@ -77,9 +79,29 @@ func (s *server) start() {
 		log.WithField("error", err.Error()).Fatal("Server failed to get network constants used for cycle math.")
 	}
 
+	// paying on expectation, a cycle is paid once the head is this far into it
+	expectedOffset := s.runner.config.Baker.ExpectedPayoutOffset
+	if expectedOffset >= constants.BlocksPerCycle {
+		expectedOffset = constants.BlocksPerCycle - 1
+	}
+
+	// the cycle the server starts in is paid from its expected rewards unless the true up ledger has it already
+	expectedCycle := block.Metadata.Level.Cycle - 1
+	if s.runner.config.Baker.PayoutMode == config.ExpectedPayouts {
+		expectations, err := ledger.LoadExpectations(s.runner.config.Baker.TrueUpLedger)
+		if err != nil {
+			log.WithField("error", err.Error()).Fatal("Server failed to load payouts made from expected rewards.")
+		}
+
+		if _, ok := expectations.Cycles[block.Metadata.Level.Cycle]; ok {
+			expectedCycle = block.Metadata.Level.Cycle
+		}
+	}
+
 	go func() {
 		currentCycle := block.Metadata.Level.Cycle
 		log.WithField("current-cycle", currentCycle).Info("Current cycle.")
+
 		ticker := time.NewTicker(time.Second * 30)
 		for range ticker.C {
 			b, err := s.rpcClient.Head()
@@ -89,6 +111,18 @@ func (s *server) start() {
 			}
 			log.WithField("level", b.Header.Level).Debug("Found a new block.")
 
+			expected := s.runner.config.Baker.PayoutMode == config.ExpectedPayouts
+			if expected && expectedCycle < b.Metadata.Level.Cycle && b.Metadata.Level.CyclePosition >= expectedOffset {
+				payout, err := payout.New(s.runner.config, b.Metadata.Level.Cycle, true, s.runner.verbose)
+				if err != nil {
+					log.WithFields(log.Fields{"error": err.Error(), "payout-cycle": b.Metadata.Level.Cycle}).Error("Failed to intialize payout.")
+					continue
+				}
+				log.WithField("payout-cycle", b.Metadata.Level.Cycle).Info("Adding payout of expected rewards to queue.")
+				s.queue.Enqueue(*payout)
+				expectedCycle = b.Metadata.Level.Cycle
+			}
+
 			if currentCycle < b.Metadata.Level.Cycle {
 				log.WithFields(log.Fields{"current-cycle": b.Metadata.Level.Cycle, "last-cycle": currentCycle}).Info("New current cycle found.")
 
@@ -97,6 +131,18 @@ func (s *server) start() {
 					cycleToPayoutFor = b.Metadata.Level.Cycle - constants.PreservedCycles
 				}
 
+				// a cycle paid from its expected rewards is only trued up once it's over
+				if expected {
+					report, err := trueUp(s.runner.config, cycleToPayoutFor)
+					if err != nil {
+						log.WithFields(log.Fields{"error": err.Error(), "payout-cycle": cycleToPayoutFor}).Error("Failed to true up payout.")
+					} else {
+						log.WithFields(log.Fields{"payout-cycle": cycleToPayoutFor, "difference": report.Difference}).Info("Trued up payout of expected rewards.")
+					}
+					currentCycle = b.Metadata.Level.Cycle
+					continue
+				}
+
 				payout, err := payout.New(s.runner.config, cycleToPayoutFor, true, s.runner.verbose)
 				if err != nil {
 					log.WithFields(log.Fields{"error": err.Error(), "payout-cycle": cycleToPayoutFor}).Error("Failed to intialize payout.")
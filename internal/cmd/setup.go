@@ -17,19 +17,45 @@ func NewSetupCommand() *cobra.Command {
 			var sb strings.Builder
 			sb.WriteString("###### REQUIRED ENVIROMENT VARIABLES ######\n")
 			sb.WriteString("TZPAY_BAKER=<TODO (e.g. tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc)>\n")
-			sb.WriteString("TZPAY_BAKER_FEE=<TODO (e.g. 0.05 for 5%)>\n")
+			sb.WriteString("TZPAY_BAKER_FEE=<TODO (e.g. 0.05 or 500bps for 5%)>\n")
 			sb.WriteString("TZPAY_WALLET_ESK=<TODO (e.g. edesk1fddn27MaLcQVEdZpAYiyGQNm6UjtWiBfNP2ZenTy3CFsoSVJgeHM9pP9cvLJ2r5Xp2quQ5mYexW1LRKee2)>\n")
 			sb.WriteString("TZPAY_WALLET_PASSWORD=<TODO (e.g. password12345##)>\n")
 			sb.WriteString("###### OPTIONAL ENVIROMENT VARIABLES ######\n")
 			sb.WriteString("TZPAY_BAKER_MINIMUM_PAYMENT=<TODO (e.g. MUTEZ 10000)>\n")
 			sb.WriteString("TZPAY_BAKER_EARNINGS_ONLY=<TODO (e.g. True)>\n")
 			sb.WriteString("TZPAY_BAKER_BLACK_LIST=<TODO (e.g. KT19Aro5JcjKH7J7RA6sCRihPiBQzQED3oQC, KT1CQiyDJ3mMVDoEqLY8Fz1onFXo5ycp5BDN)>\n")
+			sb.WriteString("TZPAY_BAKER_CARRY_OVER=<TODO (e.g. True)>\n")
+			sb.WriteString("TZPAY_BAKER_CARRY_OVER_LEDGER=<TODO (e.g. /var/lib/tzpay/ledger.json)>\n")
+			sb.WriteString("TZPAY_BAKER_BURN_FEES=<TODO (e.g. skip, pay or deduct)>\n")
+			sb.WriteString("TZPAY_BAKER_SKIPPED_REWARDS=<TODO (e.g. keep, redistribute or send)>\n")
+			sb.WriteString("TZPAY_BAKER_SKIPPED_REWARDS_ADDRESS=<TODO (e.g. tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc)>\n")
+			sb.WriteString("TZPAY_BAKER_REWARDS=<TODO (e.g. actual, missed or ideal)>\n")
+			sb.WriteString("TZPAY_BAKER_KEEP_BLOCK_FEES=<TODO (e.g. True)>\n")
+			sb.WriteString("TZPAY_BAKER_LOSSES=<TODO (e.g. baker, rewards or all)>\n")
+			sb.WriteString("TZPAY_BAKER_SHARE_ACCUSATIONS=<TODO (e.g. True)>\n")
+			sb.WriteString("TZPAY_BAKER_PAYOUT_MODE=<TODO (e.g. actual or expected)>\n")
+			sb.WriteString("TZPAY_BAKER_EXPECTED_PAYOUT_OFFSET=<TODO (e.g. 64)>\n")
+			sb.WriteString("TZPAY_BAKER_TRUE_UP_LEDGER=<TODO (e.g. /var/lib/tzpay/true-up.json)>\n")
 			sb.WriteString("TZPAY_BAKER_LIQUIDITY_CONTRACTS=<TODO (e.g. KT19Aro5JcjKH7J7RA6sCRihPiBQzQED3oQC, KT1CQiyDJ3mMVDoEqLY8Fz1onFXo5ycp5BDN)>\n")
 			sb.WriteString("TZPAY_API_TZKT=<TODO (e.g. https://api.tzkt.io )>\n")
+			sb.WriteString("TZPAY_API_TZKT_FALLBACKS=<TODO (e.g. https://api.tzkt.io)>\n")
+			sb.WriteString("TZPAY_API_TZKT_RETRIES=<TODO (e.g. 3)>\n")
+			sb.WriteString("TZPAY_API_TZKT_RATE_LIMIT=<TODO (e.g. 10)>\n")
 			sb.WriteString("TZPAY_API_TEZOS=<TODO (e.g. https://tezos.giganode.io/)>\n")
+			sb.WriteString("TZPAY_API_TEZOS_FALLBACKS=<TODO (e.g. https://mainnet-tezos.giganode.io)>\n")
+			sb.WriteString("TZPAY_API_TEZOS_TRUSTED=<TODO (e.g. https://mainnet-tezos.giganode.io)>\n")
+			sb.WriteString("TZPAY_API_REWARDS_PROVIDER=<TODO (e.g. tzkt)>\n")
+			sb.WriteString("TZPAY_API_CROSS_VALIDATE=<TODO (e.g. true)>\n")
 			sb.WriteString("TZPAY_OPERATIONS_NETWORK_FEE=<TODO (e.g. 2941)>\n")
 			sb.WriteString("TZPAY_OPERATIONS_GAS_LIMIT=<TODO (e.g. 26283)>\n")
 			sb.WriteString("TZPAY_OPERATIONS_BATCH_SIZE=<TODO (e.g. 125)>\n")
+			sb.WriteString("TZPAY_OPERATIONS_BATCH_OVERHEAD=<TODO (e.g. 1000)>\n")
+			sb.WriteString("TZPAY_OPERATIONS_DEDUCT_NETWORK_FEE=<TODO (e.g. True)>\n")
+			sb.WriteString("TZPAY_OPERATIONS_REVEAL_FEE=<TODO (e.g. 1268)>\n")
+			sb.WriteString("TZPAY_OPERATIONS_REVEAL_GAS_LIMIT=<TODO (e.g. 10000)>\n")
+			sb.WriteString("TZPAY_OPERATIONS_CONFIRMATIONS=<TODO (e.g. 2)>\n")
+			sb.WriteString("TZPAY_OPERATIONS_PAYOUT_ADDRESS=<TODO (e.g. tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo)>\n")
+			sb.WriteString("TZPAY_FIAT_CURRENCIES=<TODO (e.g. usd, eur)>\n")
 			fmt.Println(sb.String())
 		},
 	}
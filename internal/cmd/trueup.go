@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"strconv"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/payout"
+	"github.com/goat-systems/tzpay/v3/internal/print"
+	"github.com/goat-systems/tzpay/v3/internal/trueup"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// TrueUpCommand returns the cobra command for trueup
+func TrueUpCommand() *cobra.Command {
+	var table bool
+
+	var trueUpCmd = &cobra.Command{
+		Use:     "trueup",
+		Short:   "trueup compares a payout made from expected rewards with the cycle's actual rewards",
+		Long:    "trueup computes the payout for a cycle from its actual rewards and records, for every delegator, the difference from the payout made from its expected rewards",
+		Example: `tzpay trueup <cycle>`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				log.Fatal("Missing cycle as argument.")
+			}
+
+			cycle, err := strconv.Atoi(args[0])
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to parse cycle argument into integer.")
+			}
+
+			config, err := config.New()
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to load config.")
+			}
+
+			report, err := trueUp(config, cycle)
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to true up payout.")
+			}
+
+			if table {
+				print.TrueUpTable(report)
+			} else if err := print.TrueUpJSON(report); err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to print JSON report.")
+			}
+		},
+	}
+
+	trueUpCmd.PersistentFlags().BoolVarP(&table, "table", "t", false, "formats result into a table (Default: json)")
+
+	return trueUpCmd
+}
+
+// trueUp trues up the payout made from cycle's expected rewards against the payout its actual rewards make
+func trueUp(cfg config.Config, cycle int) (trueup.Report, error) {
+	actual := cfg
+	actual.Baker.PayoutMode = config.ActualPayouts
+
+	payout, err := payout.New(actual, cycle, false, false)
+	if err != nil {
+		return trueup.Report{Cycle: cycle}, errors.Wrap(err, "failed to intialize payout")
+	}
+
+	return trueup.New(cfg, cycle, payout).Execute()
+}
@@ -1,10 +1,15 @@
 package config
 
 import (
+	"fmt"
+	"math/big"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/go-playground/validator"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
 	"github.com/pkg/errors"
 )
 
@@ -15,32 +20,300 @@ type Config struct {
 	Key           Key
 	Operations    Operations
 	Notifications Notifications
+	Fiat          Fiat
 }
 
 // Baker contains configurations related to the how a baker might run their baking operation
 type Baker struct {
-	Address                      string   `env:"TZPAY_BAKER" validate:"required"`
-	Fee                          float64  `env:"TZPAY_BAKER_FEE" validate:"required"`
-	MinimumPayment               int      `env:"TZPAY_BAKER_MINIMUM_PAYMENT" envDefault:"1"`
-	EarningsOnly                 bool     `env:"TZPAY_BAKER_EARNINGS_ONLY"`
-	DexterLiquidityContractsOnly bool     `env:"TZPAY_BAKER_LIQUIDITY_CONTRACTS_ONLY"`
-	Blacklist                    []string `env:"TZPAY_BAKER_BLACK_LIST" envSeparator:","`
-	DexterLiquidityContracts     []string `env:"TZPAY_BAKER_LIQUIDITY_CONTRACTS" envSeparator:","`
-	BakerPaysBurnFees            bool     `env:"TZPAY_BAKER_PAYS_BURN_FEES"`
-	PayoutWhenRewardsUnfrozen    bool     `env:"TZPAY_REWARDS_UNFROZEN_WAIT"`
-}
-
-// API contains configurations for the tzkt API and a tezos node
+	Address                      string      `env:"TZPAY_BAKER" validate:"required"`
+	Fee                          BasisPoints `env:"TZPAY_BAKER_FEE" validate:"required"`
+	MinimumPayment               int         `env:"TZPAY_BAKER_MINIMUM_PAYMENT" envDefault:"1"`
+	EarningsOnly                 bool        `env:"TZPAY_BAKER_EARNINGS_ONLY"`
+	DexterLiquidityContractsOnly bool        `env:"TZPAY_BAKER_LIQUIDITY_CONTRACTS_ONLY"`
+	Blacklist                    []string    `env:"TZPAY_BAKER_BLACK_LIST" envSeparator:","`
+	DexterLiquidityContracts     []string    `env:"TZPAY_BAKER_LIQUIDITY_CONTRACTS" envSeparator:","`
+	BakerPaysBurnFees            bool        `env:"TZPAY_BAKER_PAYS_BURN_FEES"`
+	BurnFees                     string      `env:"TZPAY_BAKER_BURN_FEES"`
+	CarryOver                    bool        `env:"TZPAY_BAKER_CARRY_OVER"`
+	CarryOverLedger              string      `env:"TZPAY_BAKER_CARRY_OVER_LEDGER" envDefault:"tzpay-ledger.json"`
+	SkippedRewards               string      `env:"TZPAY_BAKER_SKIPPED_REWARDS" envDefault:"keep"`
+	SkippedRewardsAddress        string      `env:"TZPAY_BAKER_SKIPPED_REWARDS_ADDRESS"`
+	PayoutWhenRewardsUnfrozen    bool        `env:"TZPAY_REWARDS_UNFROZEN_WAIT"`
+	Rewards                      string      `env:"TZPAY_BAKER_REWARDS"`
+	KeepBlockFees                bool        `env:"TZPAY_BAKER_KEEP_BLOCK_FEES"`
+	Losses                       string      `env:"TZPAY_BAKER_LOSSES"`
+	ShareAccusations             bool        `env:"TZPAY_BAKER_SHARE_ACCUSATIONS"`
+	PayoutMode                   string      `env:"TZPAY_BAKER_PAYOUT_MODE" envDefault:"actual"`
+	ExpectedPayoutOffset         int         `env:"TZPAY_BAKER_EXPECTED_PAYOUT_OFFSET" validate:"min=0"`
+	TrueUpLedger                 string      `env:"TZPAY_BAKER_TRUE_UP_LEDGER" envDefault:"tzpay-true-up.json"`
+}
+
+// Modes of paying out a cycle
+const (
+	ActualPayouts   = "actual"   // its rewards are paid once it's over
+	ExpectedPayouts = "expected" // its expected rewards are paid while it's under way and trued up once it's over
+)
+
+// Policies for the rewards of skipped delegators and liquidity providers
+const (
+	KeepSkippedRewards         = "keep"
+	RedistributeSkippedRewards = "redistribute"
+	SendSkippedRewards         = "send"
+)
+
+// Policies for the burn of allocating the empty accounts of delegators and liquidity providers
+const (
+	SkipBurnFees   = "skip"
+	PayBurnFees    = "pay"
+	DeductBurnFees = "deduct"
+)
+
+/*
+BurnFeePolicy returns how the burn for allocating empty accounts is handled. Without a policy set the
+baker pays it if TZPAY_BAKER_PAYS_BURN_FEES is set and skips empty accounts otherwise.
+*/
+func (b Baker) BurnFeePolicy() string {
+	if b.BurnFees != "" {
+		return b.BurnFees
+	}
+
+	if b.BakerPaysBurnFees {
+		return PayBurnFees
+	}
+
+	return SkipBurnFees
+}
+
+// Policies for which of a cycle's rewards are distributed
+const (
+	ActualRewards = "actual" // what was earned
+	MissedRewards = "missed" // as well as what was missed of the baker's own blocks and endorsements
+	IdealRewards  = "ideal"  // as well as what the baker's rights went uncovered for, lacking deposits
+)
+
+// Policies for the losses of double baking, double endorsing and missed nonce revelations
+const (
+	BakerLosses       = "baker"   // the baker bears them
+	DeductLostRewards = "rewards" // the rewards and fees lost are deducted from what's distributed
+	DeductAllLosses   = "all"     // the deposits lost are deducted as well
+)
+
+/*
+Accounting is the policy choosing which components of a rewards split are distributed to the baker and its
+delegators: which rewards (actual, missed or ideal), whether block fees are shared or kept by the baker,
+which losses are deducted, whether accusation rewards are shared and whether the rewards of future rights
+are paid on expectation.
+*/
+type Accounting struct {
+	Rewards          string `json:"rewards"`
+	KeepBlockFees    bool   `json:"keep_block_fees"`
+	Losses           string `json:"losses"`
+	ShareAccusations bool   `json:"share_accusations"`
+	Expected         bool   `json:"expected,omitempty"`
+}
+
+/*
+Accounting returns the baker's accounting policy. Without a rewards policy set, only earnings are distributed
+if TZPAY_BAKER_EARNINGS_ONLY is set and missed rewards are covered otherwise. Without a losses policy set,
+the baker bears them.
+*/
+func (b Baker) Accounting() Accounting {
+	accounting := Accounting{
+		Rewards:          b.Rewards,
+		KeepBlockFees:    b.KeepBlockFees,
+		Losses:           b.Losses,
+		ShareAccusations: b.ShareAccusations,
+		Expected:         b.PayoutMode == ExpectedPayouts,
+	}
+
+	if accounting.Rewards == "" {
+		accounting.Rewards = MissedRewards
+		if b.EarningsOnly {
+			accounting.Rewards = ActualRewards
+		}
+	}
+
+	if accounting.Losses == "" {
+		accounting.Losses = BakerLosses
+	}
+
+	return accounting
+}
+
+/*
+String returns the policy in a line, e.g. rewards=missed block_fees=shared losses=baker accusations=kept,
+followed by payout=expected if the rewards of future rights are paid on expectation.
+*/
+func (a Accounting) String() string {
+	share := func(kept bool) string {
+		if kept {
+			return "kept"
+		}
+		return "shared"
+	}
+
+	policy := fmt.Sprintf("rewards=%s block_fees=%s losses=%s accusations=%s", a.Rewards, share(a.KeepBlockFees), a.Losses, share(!a.ShareAccusations))
+	if a.Expected {
+		policy += " payout=" + ExpectedPayouts
+	}
+
+	return policy
+}
+
+/*
+Apply returns what the policy distributes of a rewards split. Missed and uncovered fees are only covered
+and lost fees only deducted if block fees are shared, and the deductions never take the total below zero.
+Paying on expectation, the rewards of the blocks and endorsements the delegate has yet to bake are
+distributed as if they were earned; the fees of those blocks can't be known yet.
+*/
+func (a Accounting) Apply(rewards tzkt.RewardsSplit) tzkt.Accounting {
+	fees := func(mutez int) int {
+		if a.KeepBlockFees {
+			return 0
+		}
+		return mutez
+	}
+
+	accounting := tzkt.Accounting{
+		Policy: a.String(),
+		Earned: rewards.EndorsementRewards +
+			rewards.OwnBlockRewards +
+			rewards.ExtraBlockRewards +
+			rewards.RevelationRewards +
+			fees(rewards.OwnBlockFees+rewards.ExtraBlockFees),
+	}
+
+	if a.KeepBlockFees {
+		accounting.KeptFees = rewards.OwnBlockFees + rewards.ExtraBlockFees
+	}
+
+	if a.Rewards == MissedRewards || a.Rewards == IdealRewards {
+		accounting.Covered += rewards.MissedEndorsementRewards + rewards.MissedOwnBlockRewards + fees(rewards.MissedOwnBlockFees)
+	}
+
+	if a.Rewards == IdealRewards {
+		accounting.Covered += rewards.UncoveredEndorsementRewards + rewards.UncoveredOwnBlockRewards + fees(rewards.UncoveredOwnBlockFees)
+	}
+
+	if a.Expected {
+		accounting.Future = rewards.FutureBlockRewards + rewards.FutureEndorsementRewards
+	}
+
+	if a.ShareAccusations {
+		accounting.Accusations = rewards.DoubleBakingRewards + rewards.DoubleEndorsingRewards
+	}
+
+	if a.Losses == DeductLostRewards || a.Losses == DeductAllLosses {
+		accounting.Deducted = rewards.DoubleBakingLostRewards +
+			rewards.DoubleEndorsingLostRewards +
+			rewards.RevelationLostRewards +
+			fees(rewards.DoubleBakingLostFees+rewards.DoubleEndorsingLostFees+rewards.RevelationLostFees)
+	}
+
+	if a.Losses == DeductAllLosses {
+		accounting.Deducted += rewards.DoubleBakingLostDeposits + rewards.DoubleEndorsingLostDeposits
+	}
+
+	gross := accounting.Earned + accounting.Future + accounting.Covered + accounting.Accusations
+	if accounting.Deducted > gross {
+		accounting.Deducted = gross
+	}
+	accounting.Total = gross - accounting.Deducted
+
+	return accounting
+}
+
+// BasisPointsPerUnit is the number of basis points in a whole (100%)
+const BasisPointsPerUnit = 10000
+
+/*
+BasisPoints is a fraction expressed in hundredths of a percent, so that fees can be applied with
+exact integer arithmetic. 500 basis points is 5%.
+*/
+type BasisPoints int
+
+// UnmarshalText parses either a decimal fraction (e.g. 0.05) or a number of basis points suffixed with bps (e.g. 500bps)
+func (b *BasisPoints) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+	if strings.HasSuffix(value, "bps") {
+		bps, err := strconv.Atoi(strings.TrimSuffix(value, "bps"))
+		if err != nil {
+			return errors.Wrapf(err, "invalid basis points '%s'", value)
+		}
+
+		return b.set(bps, value)
+	}
+
+	fraction, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return errors.Errorf("invalid fraction '%s'", value)
+	}
+
+	fraction.Mul(fraction, big.NewRat(BasisPointsPerUnit, 1))
+	if !fraction.IsInt() {
+		return errors.Errorf("invalid fraction '%s': precision is limited to 1 basis point (0.0001)", value)
+	}
+
+	return b.set(int(fraction.Num().Int64()), value)
+}
+
+func (b *BasisPoints) set(bps int, value string) error {
+	if bps < 0 || bps > BasisPointsPerUnit {
+		return errors.Errorf("invalid fraction '%s': must be between 0 and 1", value)
+	}
+
+	*b = BasisPoints(bps)
+	return nil
+}
+
+/*
+API contains configurations for the tzkt API and a tezos node. Requests fail over to the fallbacks, in
+order, while an endpoint is unhealthy, but operations are only injected into the tezos node and the
+fallbacks listed as trusted.
+*/
 type API struct {
-	TZKT  string `env:"TZPAY_API_TZKT" envDefault:"https://api.tzkt.io" validate:"required"`
-	Tezos string `env:"TZPAY_API_TEZOS" envDefault:"https://mainnet-tezos.giganode.io" validate:"required"`
+	TZKT                   string        `env:"TZPAY_API_TZKT" envDefault:"https://api.tzkt.io" validate:"required"`
+	TZKTFallbacks          []string      `env:"TZPAY_API_TZKT_FALLBACKS" envSeparator:","`
+	TZKTMaxLag             int           `env:"TZPAY_API_TZKT_MAX_LAG" envDefault:"3" validate:"min=0"`
+	TZKTTimeout            time.Duration `env:"TZPAY_API_TZKT_TIMEOUT" envDefault:"10s"`
+	TZKTRetries            int           `env:"TZPAY_API_TZKT_RETRIES" envDefault:"3" validate:"min=0"`
+	TZKTRateLimit          float64       `env:"TZPAY_API_TZKT_RATE_LIMIT" envDefault:"10" validate:"min=0"`
+	Tezos                  string        `env:"TZPAY_API_TEZOS" envDefault:"https://mainnet-tezos.giganode.io" validate:"required"`
+	TezosFallbacks         []string      `env:"TZPAY_API_TEZOS_FALLBACKS" envSeparator:","`
+	TezosTrusted           []string      `env:"TZPAY_API_TEZOS_TRUSTED" envSeparator:","`
+	HealthCheckInterval    time.Duration `env:"TZPAY_API_HEALTH_CHECK_INTERVAL" envDefault:"1m"`
+	RewardsProvider        string        `env:"TZPAY_API_REWARDS_PROVIDER" envDefault:"tzkt"`
+	CrossValidate          bool          `env:"TZPAY_API_CROSS_VALIDATE"`
+	CrossValidateTolerance int           `env:"TZPAY_API_CROSS_VALIDATE_TOLERANCE" validate:"min=0"`
+}
+
+// Providers of the rewards split a payout is computed from
+const (
+	TzktRewardsProvider = "tzkt"
+	NodeRewardsProvider = "node"
+)
+
+// TZKTOptions returns how requests to the tzkt API are timed out, retried and rate limited
+func (a API) TZKTOptions() tzkt.Options {
+	options := tzkt.DefaultOptions
+	options.Timeout = a.TZKTTimeout
+	options.Retries = a.TZKTRetries
+	options.RateLimit = a.TZKTRateLimit
+
+	return options
 }
 
 // Operations contains configurations for modifying the actual operation to be injected into a node
 type Operations struct {
-	NetworkFee int `env:"TZPAY_OPERATIONS_NETWORK_FEE" envDefault:"2941"`
-	GasLimit   int `env:"TZPAY_OPERATIONS_GAS_LIMIT" envDefault:"26283"`
-	BatchSize  int `env:"TZPAY_OPERATIONS_BATCH_SIZE" envDefault:"125"`
+	NetworkFee       int    `env:"TZPAY_OPERATIONS_NETWORK_FEE" envDefault:"2941"`
+	GasLimit         int    `env:"TZPAY_OPERATIONS_GAS_LIMIT" envDefault:"26283"`
+	BatchSize        int    `env:"TZPAY_OPERATIONS_BATCH_SIZE" envDefault:"125"`
+	BatchOverhead    int    `env:"TZPAY_OPERATIONS_BATCH_OVERHEAD"`
+	DeductNetworkFee bool   `env:"TZPAY_OPERATIONS_DEDUCT_NETWORK_FEE"`
+	RevealFee        int    `env:"TZPAY_OPERATIONS_REVEAL_FEE" envDefault:"1268"`
+	RevealGasLimit   int    `env:"TZPAY_OPERATIONS_REVEAL_GAS_LIMIT" envDefault:"10000"`
+	Confirmations    int    `env:"TZPAY_OPERATIONS_CONFIRMATIONS" envDefault:"2" validate:"min=0"`
+	PayoutAddress    string `env:"TZPAY_OPERATIONS_PAYOUT_ADDRESS"`
 }
 
 // Key contains sensitive information regarding
@@ -49,6 +322,11 @@ type Key struct {
 	Password string `env:"TZPAY_WALLET_PASSWORD" validate:"required"`
 }
 
+// Fiat contains configurations for valuing payouts in fiat currencies, which is only done if any are listed
+type Fiat struct {
+	Currencies []string `env:"TZPAY_FIAT_CURRENCIES" envSeparator:","`
+}
+
 // Notifications contains the configurations for notification features
 type Notifications struct {
 	Twitter Twitter
@@ -78,13 +356,85 @@ func New() (Config, error) {
 		return config, errors.Wrap(err, "failed to load enviroment variables")
 	}
 
+	config.API.TZKTFallbacks = cleanList(config.API.TZKTFallbacks)
+	config.API.TezosFallbacks = cleanList(config.API.TezosFallbacks)
+	config.API.TezosTrusted = cleanList(config.API.TezosTrusted)
 	config.Baker.Blacklist = cleanList(config.Baker.Blacklist)
 	config.Baker.DexterLiquidityContracts = cleanList(config.Baker.DexterLiquidityContracts)
+	config.Fiat.Currencies = cleanList(config.Fiat.Currencies)
+	for i := range config.Fiat.Currencies {
+		config.Fiat.Currencies[i] = strings.ToLower(config.Fiat.Currencies[i])
+	}
 
 	if config.Notifications.Twilio.To != nil {
 		config.Notifications.Twilio.To = cleanList(config.Notifications.Twilio.To)
 	}
 
+	for _, currency := range config.Fiat.Currencies {
+		if _, ok := (tzkt.Quote{}).Prices(currency)[currency]; !ok {
+			return config, errors.Errorf("invalid input: unsupported fiat currency '%s'", currency)
+		}
+	}
+
+	for _, trusted := range config.API.TezosTrusted {
+		if !contains(config.API.TezosFallbacks, trusted) {
+			return config, errors.Errorf("invalid input: trusted tezos node '%s' isn't a fallback", trusted)
+		}
+	}
+
+	switch config.API.RewardsProvider {
+	case TzktRewardsProvider, NodeRewardsProvider:
+	default:
+		return config, errors.Errorf("invalid input: unsupported rewards provider '%s'", config.API.RewardsProvider)
+	}
+
+	switch config.Baker.Rewards {
+	case "", ActualRewards:
+	case MissedRewards, IdealRewards:
+		if config.Baker.EarningsOnly {
+			return config, errors.Errorf("invalid input: rewards policy '%s' conflicts with TZPAY_BAKER_EARNINGS_ONLY", config.Baker.Rewards)
+		}
+	default:
+		return config, errors.Errorf("invalid input: unsupported rewards policy '%s'", config.Baker.Rewards)
+	}
+
+	switch config.Baker.Losses {
+	case "", BakerLosses, DeductLostRewards, DeductAllLosses:
+	default:
+		return config, errors.Errorf("invalid input: unsupported losses policy '%s'", config.Baker.Losses)
+	}
+
+	switch config.Baker.PayoutMode {
+	case ActualPayouts:
+	case ExpectedPayouts:
+		// a node doesn't know what future rights will earn
+		if config.API.RewardsProvider == NodeRewardsProvider {
+			return config, errors.Errorf("invalid input: payout mode '%s' requires the '%s' rewards provider", ExpectedPayouts, TzktRewardsProvider)
+		}
+	default:
+		return config, errors.Errorf("invalid input: unsupported payout mode '%s'", config.Baker.PayoutMode)
+	}
+
+	switch config.Baker.SkippedRewards {
+	case KeepSkippedRewards, RedistributeSkippedRewards:
+	case SendSkippedRewards:
+		if config.Baker.SkippedRewardsAddress == "" {
+			return config, errors.Errorf("invalid input: skipped rewards policy '%s' requires an address", SendSkippedRewards)
+		}
+	default:
+		return config, errors.Errorf("invalid input: unsupported skipped rewards policy '%s'", config.Baker.SkippedRewards)
+	}
+
+	switch config.Baker.BurnFees {
+	case "", PayBurnFees:
+	case SkipBurnFees, DeductBurnFees:
+		if config.Baker.BakerPaysBurnFees {
+			return config, errors.Errorf("invalid input: burn fee policy '%s' conflicts with TZPAY_BAKER_PAYS_BURN_FEES", config.Baker.BurnFees)
+		}
+	default:
+		return config, errors.Errorf("invalid input: unsupported burn fee policy '%s'", config.Baker.BurnFees)
+	}
+
 	err := validator.New().Struct(&config)
 	if err != nil {
 		return config, errors.Wrap(err, "invalid input")
@@ -101,3 +451,13 @@ func cleanList(list []string) []string {
 
 	return out
 }
+
+func contains(list []string, s string) bool {
+	for _, element := range list {
+		if element == s {
+			return true
+		}
+	}
+
+	return false
+}
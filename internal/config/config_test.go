@@ -3,8 +3,10 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,6 +34,7 @@ func Test_NewDryRunEnviroment(t *testing.T) {
 				"TZPAY_BAKER_LIQUIDITY_CONTRACTS": "some_contract,        some_contract_2",
 				"TZPAY_WALLET_ESK":                "some_esk",
 				"TZPAY_WALLET_PASSWORD":           "some_pass",
+				"TZPAY_FIAT_CURRENCIES":           "USD, eur",
 			},
 
 			want{
@@ -39,12 +42,18 @@ func Test_NewDryRunEnviroment(t *testing.T) {
 				"",
 				Config{
 					API{
-						TZKT:  "https://api.tzkt.io",
-						Tezos: "https://tezos.giganode.io/",
+						TZKT:                "https://api.tzkt.io",
+						TZKTMaxLag:          3,
+						TZKTTimeout:         10 * time.Second,
+						TZKTRetries:         3,
+						TZKTRateLimit:       10,
+						Tezos:               "https://tezos.giganode.io/",
+						HealthCheckInterval: time.Minute,
+						RewardsProvider:     TzktRewardsProvider,
 					},
 					Baker{
 						Address:        "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
-						Fee:            0.05,
+						Fee:            500,
 						MinimumPayment: 1000,
 						EarningsOnly:   true,
 						Blacklist: []string{
@@ -55,17 +64,27 @@ func Test_NewDryRunEnviroment(t *testing.T) {
 							"some_contract",
 							"some_contract_2",
 						},
+						CarryOverLedger: "tzpay-ledger.json",
+						SkippedRewards:  KeepSkippedRewards,
+						PayoutMode:      ActualPayouts,
+						TrueUpLedger:    "tzpay-true-up.json",
 					},
 					Key{
 						Esk:      "some_esk",
 						Password: "some_pass",
 					},
 					Operations{
-						NetworkFee: 2941,
-						GasLimit:   26283,
-						BatchSize:  125,
+						NetworkFee:     2941,
+						GasLimit:       26283,
+						BatchSize:      125,
+						RevealFee:      1268,
+						RevealGasLimit: 10000,
+						Confirmations:  2,
 					},
 					Notifications{},
+					Fiat{
+						Currencies: []string{"usd", "eur"},
+					},
 				},
 			},
 		},
@@ -86,8 +105,14 @@ func Test_NewDryRunEnviroment(t *testing.T) {
 				"invalid input",
 				Config{
 					API{
-						TZKT:  "https://api.tzkt.io",
-						Tezos: "https://tezos.giganode.io/",
+						TZKT:                "https://api.tzkt.io",
+						TZKTMaxLag:          3,
+						TZKTTimeout:         10 * time.Second,
+						TZKTRetries:         3,
+						TZKTRateLimit:       10,
+						Tezos:               "https://tezos.giganode.io/",
+						HealthCheckInterval: time.Minute,
+						RewardsProvider:     TzktRewardsProvider,
 					},
 					Baker{
 						Address:        "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
@@ -101,17 +126,25 @@ func Test_NewDryRunEnviroment(t *testing.T) {
 							"some_contract",
 							"some_contract_2",
 						},
+						CarryOverLedger: "tzpay-ledger.json",
+						SkippedRewards:  KeepSkippedRewards,
+						PayoutMode:      ActualPayouts,
+						TrueUpLedger:    "tzpay-true-up.json",
 					},
 					Key{
 						Esk:      "some_esk",
 						Password: "some_pass",
 					},
 					Operations{
-						NetworkFee: 2941,
-						GasLimit:   26283,
-						BatchSize:  125,
+						NetworkFee:     2941,
+						GasLimit:       26283,
+						BatchSize:      125,
+						RevealFee:      1268,
+						RevealGasLimit: 10000,
+						Confirmations:  2,
 					},
 					Notifications{},
+					Fiat{},
 				},
 			},
 		},
@@ -128,6 +161,425 @@ func Test_NewDryRunEnviroment(t *testing.T) {
 	}
 }
 
+func Test_FiatCurrencies(t *testing.T) {
+	cases := []struct {
+		name       string
+		currencies string
+		err        bool
+		contains   string
+	}{
+		{"is successful with fiat currencies", "usd,EUR,jpy", false, ""},
+		{"handles unknown currency", "usd,doge", true, "unsupported fiat currency 'doge'"},
+		{"handles crypto currency", "usd,btc", true, "unsupported fiat currency 'btc'"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"TZPAY_BAKER":           "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+				"TZPAY_BAKER_FEE":       "0.05",
+				"TZPAY_WALLET_ESK":      "some_esk",
+				"TZPAY_WALLET_PASSWORD": "some_pass",
+				"TZPAY_FIAT_CURRENCIES": tt.currencies,
+			}
+
+			setEnv(env)
+			defer unsetEnv(env)
+
+			_, err := New()
+			test.CheckErr(t, tt.err, tt.contains, err)
+		})
+	}
+}
+
+func Test_Fallbacks(t *testing.T) {
+	cases := []struct {
+		name     string
+		trusted  string
+		err      bool
+		contains string
+	}{
+		{"is successful", "", false, ""},
+		{"is successful with trusted fallback", "https://node-2.example.com", false, ""},
+		{"handles trusted node that isn't a fallback", "https://node-3.example.com", true, "trusted tezos node 'https://node-3.example.com' isn't a fallback"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"TZPAY_BAKER":               "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+				"TZPAY_BAKER_FEE":           "0.05",
+				"TZPAY_WALLET_ESK":          "some_esk",
+				"TZPAY_WALLET_PASSWORD":     "some_pass",
+				"TZPAY_API_TZKT_FALLBACKS":  "https://tzkt.example.com",
+				"TZPAY_API_TEZOS_FALLBACKS": "https://node-1.example.com, https://node-2.example.com",
+				"TZPAY_API_TEZOS_TRUSTED":   tt.trusted,
+			}
+
+			setEnv(env)
+			defer unsetEnv(env)
+
+			config, err := New()
+			test.CheckErr(t, tt.err, tt.contains, err)
+			assert.Equal(t, []string{"https://tzkt.example.com"}, config.API.TZKTFallbacks)
+			assert.Equal(t, []string{"https://node-1.example.com", "https://node-2.example.com"}, config.API.TezosFallbacks)
+		})
+	}
+}
+
+func Test_RewardsProvider(t *testing.T) {
+	cases := []struct {
+		name     string
+		provider string
+		err      bool
+		contains string
+	}{
+		{"is successful with tzkt", "tzkt", false, ""},
+		{"is successful with node", "node", false, ""},
+		{"handles unsupported provider", "tzstats", true, "unsupported rewards provider 'tzstats'"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"TZPAY_BAKER":                "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+				"TZPAY_BAKER_FEE":            "0.05",
+				"TZPAY_WALLET_ESK":           "some_esk",
+				"TZPAY_WALLET_PASSWORD":      "some_pass",
+				"TZPAY_API_REWARDS_PROVIDER": tt.provider,
+			}
+
+			setEnv(env)
+			defer unsetEnv(env)
+
+			config, err := New()
+			test.CheckErr(t, tt.err, tt.contains, err)
+			assert.Equal(t, tt.provider, config.API.RewardsProvider)
+		})
+	}
+}
+
+func Test_PayoutMode(t *testing.T) {
+	cases := []struct {
+		name     string
+		mode     string
+		provider string
+		err      bool
+		contains string
+	}{
+		{"is successful with actual", "actual", "tzkt", false, ""},
+		{"is successful with expected", "expected", "tzkt", false, ""},
+		{"is successful with actual from the node", "actual", "node", false, ""},
+		{"handles expected from the node", "expected", "node", true, "payout mode 'expected' requires the 'tzkt' rewards provider"},
+		{"handles unsupported mode", "eventual", "tzkt", true, "unsupported payout mode 'eventual'"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"TZPAY_BAKER":                "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+				"TZPAY_BAKER_FEE":            "0.05",
+				"TZPAY_WALLET_ESK":           "some_esk",
+				"TZPAY_WALLET_PASSWORD":      "some_pass",
+				"TZPAY_BAKER_PAYOUT_MODE":    tt.mode,
+				"TZPAY_API_REWARDS_PROVIDER": tt.provider,
+			}
+
+			setEnv(env)
+			defer unsetEnv(env)
+
+			config, err := New()
+			test.CheckErr(t, tt.err, tt.contains, err)
+			assert.Equal(t, tt.mode == ExpectedPayouts, config.Baker.Accounting().Expected)
+		})
+	}
+}
+
+func Test_SkippedRewards(t *testing.T) {
+	cases := []struct {
+		name     string
+		policy   string
+		address  string
+		err      bool
+		contains string
+	}{
+		{"is successful with redistribute", "redistribute", "", false, ""},
+		{"is successful with send", "send", "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", false, ""},
+		{"handles send without address", "send", "", true, "skipped rewards policy 'send' requires an address"},
+		{"handles unsupported policy", "burn", "", true, "unsupported skipped rewards policy 'burn'"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"TZPAY_BAKER":                         "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+				"TZPAY_BAKER_FEE":                     "0.05",
+				"TZPAY_WALLET_ESK":                    "some_esk",
+				"TZPAY_WALLET_PASSWORD":               "some_pass",
+				"TZPAY_BAKER_SKIPPED_REWARDS":         tt.policy,
+				"TZPAY_BAKER_SKIPPED_REWARDS_ADDRESS": tt.address,
+			}
+
+			setEnv(env)
+			defer unsetEnv(env)
+
+			_, err := New()
+			test.CheckErr(t, tt.err, tt.contains, err)
+		})
+	}
+}
+
+func Test_BurnFees(t *testing.T) {
+	type want struct {
+		err      bool
+		contains string
+		policy   string
+	}
+
+	cases := []struct {
+		name      string
+		policy    string
+		bakerPays string
+		want      want
+	}{
+		{"is successful with default", "", "", want{false, "", SkipBurnFees}},
+		{"is successful with baker paying", "", "true", want{false, "", PayBurnFees}},
+		{"is successful with deduct", "deduct", "", want{false, "", DeductBurnFees}},
+		{"handles conflicting policy", "deduct", "true", want{true, "burn fee policy 'deduct' conflicts with TZPAY_BAKER_PAYS_BURN_FEES", ""}},
+		{"handles unsupported policy", "split", "", want{true, "unsupported burn fee policy 'split'", ""}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"TZPAY_BAKER":                "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+				"TZPAY_BAKER_FEE":            "0.05",
+				"TZPAY_WALLET_ESK":           "some_esk",
+				"TZPAY_WALLET_PASSWORD":      "some_pass",
+				"TZPAY_BAKER_BURN_FEES":      tt.policy,
+				"TZPAY_BAKER_PAYS_BURN_FEES": tt.bakerPays,
+			}
+
+			setEnv(env)
+			defer unsetEnv(env)
+
+			config, err := New()
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			if !tt.want.err {
+				assert.Equal(t, tt.want.policy, config.Baker.BurnFeePolicy())
+			}
+		})
+	}
+}
+
+func Test_AccountingPolicy(t *testing.T) {
+	type want struct {
+		err        bool
+		contains   string
+		accounting Accounting
+	}
+
+	cases := []struct {
+		name         string
+		rewards      string
+		losses       string
+		earningsOnly string
+		want         want
+	}{
+		{"is successful with default", "", "", "", want{false, "", Accounting{Rewards: MissedRewards, Losses: BakerLosses}}},
+		{"is successful with earnings only", "", "", "true", want{false, "", Accounting{Rewards: ActualRewards, Losses: BakerLosses}}},
+		{"is successful with ideal", "ideal", "all", "", want{false, "", Accounting{Rewards: IdealRewards, Losses: DeductAllLosses}}},
+		{"is successful with actual and earnings only", "actual", "rewards", "true", want{false, "", Accounting{Rewards: ActualRewards, Losses: DeductLostRewards}}},
+		{"handles conflicting policy", "missed", "", "true", want{true, "rewards policy 'missed' conflicts with TZPAY_BAKER_EARNINGS_ONLY", Accounting{}}},
+		{"handles unsupported rewards policy", "expected", "", "", want{true, "unsupported rewards policy 'expected'", Accounting{}}},
+		{"handles unsupported losses policy", "", "delegators", "", want{true, "unsupported losses policy 'delegators'", Accounting{}}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{
+				"TZPAY_BAKER":               "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+				"TZPAY_BAKER_FEE":           "0.05",
+				"TZPAY_WALLET_ESK":          "some_esk",
+				"TZPAY_WALLET_PASSWORD":     "some_pass",
+				"TZPAY_BAKER_REWARDS":       tt.rewards,
+				"TZPAY_BAKER_LOSSES":        tt.losses,
+				"TZPAY_BAKER_EARNINGS_ONLY": tt.earningsOnly,
+			}
+
+			setEnv(env)
+			defer unsetEnv(env)
+
+			config, err := New()
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			if !tt.want.err {
+				assert.Equal(t, tt.want.accounting, config.Baker.Accounting())
+			}
+		})
+	}
+}
+
+func Test_Accounting_Apply(t *testing.T) {
+	rewardsSplit := tzkt.RewardsSplit{
+		EndorsementRewards:          1000,
+		RevelationRewards:           1012,
+		OwnBlockFees:                213441,
+		OwnBlockRewards:             24124321,
+		ExtraBlockFees:              32321,
+		ExtraBlockRewards:           234123,
+		MissedEndorsementRewards:    2134423,
+		MissedOwnBlockFees:          21234,
+		MissedOwnBlockRewards:       3214312,
+		UncoveredEndorsementRewards: 500000,
+		UncoveredOwnBlockRewards:    1000000,
+		UncoveredOwnBlockFees:       5000,
+		DoubleBakingRewards:         700000,
+		DoubleEndorsingRewards:      300000,
+		DoubleBakingLostDeposits:    5000000,
+		DoubleBakingLostRewards:     100000,
+		DoubleBakingLostFees:        2000,
+		DoubleEndorsingLostDeposits: 2000000,
+		DoubleEndorsingLostRewards:  50000,
+		RevelationLostRewards:       40000,
+		RevelationLostFees:          1000,
+		FutureBlockRewards:          2500000,
+		FutureEndorsementRewards:    1250000,
+	}
+
+	cases := []struct {
+		name         string
+		accounting   Accounting
+		rewardsSplit tzkt.RewardsSplit
+		want         tzkt.Accounting
+	}{
+		{
+			"handles actual rewards",
+			Accounting{Rewards: ActualRewards, Losses: BakerLosses},
+			rewardsSplit,
+			tzkt.Accounting{Policy: "rewards=actual block_fees=shared losses=baker accusations=kept", Earned: 24606218, Total: 24606218},
+		},
+		{
+			"handles missed rewards",
+			Accounting{Rewards: MissedRewards, Losses: BakerLosses},
+			rewardsSplit,
+			tzkt.Accounting{Policy: "rewards=missed block_fees=shared losses=baker accusations=kept", Earned: 24606218, Covered: 5369969, Total: 29976187},
+		},
+		{
+			"handles ideal rewards",
+			Accounting{Rewards: IdealRewards, Losses: BakerLosses},
+			rewardsSplit,
+			tzkt.Accounting{Policy: "rewards=ideal block_fees=shared losses=baker accusations=kept", Earned: 24606218, Covered: 6874969, Total: 31481187},
+		},
+		{
+			"handles kept block fees",
+			Accounting{Rewards: MissedRewards, KeepBlockFees: true, Losses: BakerLosses},
+			rewardsSplit,
+			tzkt.Accounting{Policy: "rewards=missed block_fees=kept losses=baker accusations=kept", Earned: 24360456, Covered: 5348735, KeptFees: 245762, Total: 29709191},
+		},
+		{
+			"handles shared accusations",
+			Accounting{Rewards: ActualRewards, Losses: BakerLosses, ShareAccusations: true},
+			rewardsSplit,
+			tzkt.Accounting{Policy: "rewards=actual block_fees=shared losses=baker accusations=shared", Earned: 24606218, Accusations: 1000000, Total: 25606218},
+		},
+		{
+			"handles deducted lost rewards",
+			Accounting{Rewards: ActualRewards, Losses: DeductLostRewards},
+			rewardsSplit,
+			tzkt.Accounting{Policy: "rewards=actual block_fees=shared losses=rewards accusations=kept", Earned: 24606218, Deducted: 193000, Total: 24413218},
+		},
+		{
+			"handles deducted lost rewards with kept block fees",
+			Accounting{Rewards: ActualRewards, KeepBlockFees: true, Losses: DeductLostRewards},
+			rewardsSplit,
+			tzkt.Accounting{Policy: "rewards=actual block_fees=kept losses=rewards accusations=kept", Earned: 24360456, KeptFees: 245762, Deducted: 190000, Total: 24170456},
+		},
+		{
+			"handles all deducted losses",
+			Accounting{Rewards: ActualRewards, Losses: DeductAllLosses},
+			rewardsSplit,
+			tzkt.Accounting{Policy: "rewards=actual block_fees=shared losses=all accusations=kept", Earned: 24606218, Deducted: 7193000, Total: 17413218},
+		},
+		{
+			"handles expected rewards",
+			Accounting{Rewards: ActualRewards, Losses: BakerLosses, Expected: true},
+			rewardsSplit,
+			tzkt.Accounting{Policy: "rewards=actual block_fees=shared losses=baker accusations=kept payout=expected", Earned: 24606218, Future: 3750000, Total: 28356218},
+		},
+		{
+			"handles losses greater than rewards",
+			Accounting{Rewards: ActualRewards, Losses: DeductAllLosses},
+			tzkt.RewardsSplit{OwnBlockRewards: 1000, DoubleBakingLostDeposits: 5000},
+			tzkt.Accounting{Policy: "rewards=actual block_fees=shared losses=all accusations=kept", Earned: 1000, Deducted: 1000, Total: 0},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.accounting.Apply(tt.rewardsSplit))
+		})
+	}
+}
+
+func Test_BasisPoints(t *testing.T) {
+	type want struct {
+		err         bool
+		contains    string
+		basisPoints BasisPoints
+	}
+
+	cases := []struct {
+		name  string
+		input string
+		want  want
+	}{
+		{
+			"is successful with fraction",
+			"0.05",
+			want{false, "", 500},
+		},
+		{
+			"is successful with basis points precision",
+			"0.0125",
+			want{false, "", 125},
+		},
+		{
+			"is successful with basis points",
+			"750bps",
+			want{false, "", 750},
+		},
+		{
+			"handles precision beyond a basis point",
+			"0.00005",
+			want{true, "precision is limited to 1 basis point", 0},
+		},
+		{
+			"handles fraction out of range",
+			"1.5",
+			want{true, "must be between 0 and 1", 0},
+		},
+		{
+			"handles invalid fraction",
+			"five percent",
+			want{true, "invalid fraction", 0},
+		},
+		{
+			"handles invalid basis points",
+			"5.5bps",
+			want{true, "invalid basis points", 0},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var basisPoints BasisPoints
+			err := basisPoints.UnmarshalText([]byte(tt.input))
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			assert.Equal(t, tt.want.basisPoints, basisPoints)
+		})
+	}
+}
+
 func setEnv(env map[string]string) {
 	for key, element := range env {
 		os.Setenv(key, element)
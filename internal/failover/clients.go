@@ -0,0 +1,203 @@
+package failover
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+// NewRPC returns a tezos rpc client for the node and fallbacks in api, injecting only into trusted nodes
+func NewRPC(api config.API) (*rpc.Client, error) {
+	client, _, err := NewNode(api)
+	return client, err
+}
+
+/*
+NewNode returns a tezos rpc client like NewRPC, along with a Node sharing its connections to the node and
+fallbacks in api for the rpcs the client doesn't cover.
+*/
+func NewNode(api config.API) (*rpc.Client, *Node, error) {
+	if len(api.TezosFallbacks) == 0 {
+		client, err := rpc.New(api.Tezos)
+		return client, &Node{client: &http.Client{Timeout: 10 * time.Second}, host: cleanseHost(api.Tezos)}, err
+	}
+
+	trusted := append([]string{api.Tezos}, api.TezosTrusted...)
+	endpoints := New(append([]string{api.Tezos}, api.TezosFallbacks...), trusted, NodeCheck, api.HealthCheckInterval, injection)
+	httpClient := &http.Client{Timeout: 10 * time.Second, Transport: endpoints}
+
+	// the client reads the network constants from the first node it is given, so give it one that's up
+	var err error
+	for _, host := range endpoints.Hosts() {
+		var client *rpc.Client
+		if client, err = rpc.New(host); err == nil {
+			client.SetClient(httpClient)
+			return client, &Node{client: httpClient, host: host}, nil
+		}
+	}
+
+	return nil, nil, errors.Wrap(err, "failed to connect to any tezos node")
+}
+
+// Node reads what the tezos rpc client doesn't cover from a tezos node
+type Node struct {
+	client *http.Client
+	host   string
+}
+
+// ManagerKey returns the public key pkh revealed as of blockhash, empty if it was never revealed
+func (n *Node) ManagerKey(blockhash, pkh string) (string, error) {
+	var key *string
+	if err := getJSON(n.client, fmt.Sprintf("%s/chains/main/blocks/%s/context/contracts/%s/manager_key", n.host, blockhash, pkh), &key); err != nil {
+		return "", errors.Wrap(err, "failed to get manager key")
+	}
+
+	if key == nil {
+		return "", nil
+	}
+
+	return *key, nil
+}
+
+const (
+	// Applied is the status of an operation's content that was applied
+	Applied = "applied"
+	// Failed is the status of an operation's content that failed, which fails every content of the operation
+	Failed = "failed"
+	// Backtracked is the status of a content applied before a content of its operation failed, and reverted
+	Backtracked = "backtracked"
+	// Skipped is the status of a content that came after a content of its operation that failed
+	Skipped = "skipped"
+)
+
+// Operation is a manager operation included in a block, with the receipt of each of its contents
+type Operation struct {
+	Hash     string    `json:"hash"`
+	Contents []Content `json:"contents"`
+}
+
+// Content is a content of an included manager operation and its receipt
+type Content struct {
+	Kind        string `json:"kind"`
+	Source      string `json:"source"`
+	Destination string `json:"destination,omitempty"`
+	Metadata    struct {
+		OperationResult          Result           `json:"operation_result"`
+		InternalOperationResults []InternalResult `json:"internal_operation_results,omitempty"`
+	} `json:"metadata"`
+}
+
+// InternalResult is the receipt of an operation a content triggered, e.g. a transfer made by a contract
+type InternalResult struct {
+	Kind        string `json:"kind"`
+	Source      string `json:"source"`
+	Destination string `json:"destination,omitempty"`
+	Result      Result `json:"result"`
+}
+
+// Result is the result of applying a content or an internal operation
+type Result struct {
+	Status              string `json:"status"`
+	ConsumedGas         int64  `json:"consumed_gas,string,omitempty"`
+	ConsumedMilligas    int64  `json:"consumed_milligas,string,omitempty"`
+	PaidStorageSizeDiff int64  `json:"paid_storage_size_diff,string,omitempty"`
+}
+
+// Gas returns the gas consumed, rounding up the milligas newer protocols only report
+func (r Result) Gas() int64 {
+	if r.ConsumedGas == 0 && r.ConsumedMilligas > 0 {
+		return (r.ConsumedMilligas + 999) / 1000
+	}
+
+	return r.ConsumedGas
+}
+
+// ManagerOperations returns the manager operations included in the block at level, with their receipts
+func (n *Node) ManagerOperations(level int) ([]Operation, error) {
+	var operations []Operation
+	if err := getJSON(n.client, fmt.Sprintf("%s/chains/main/blocks/%d/operations/3", n.host, level), &operations); err != nil {
+		return nil, errors.Wrapf(err, "failed to get manager operations at level %d", level)
+	}
+
+	return operations, nil
+}
+
+// NewTZKT returns a tzkt client for the api and fallbacks in api
+func NewTZKT(api config.API) *tzkt.Tzkt {
+	options := api.TZKTOptions()
+	if len(api.TZKTFallbacks) > 0 {
+		hosts := append([]string{api.TZKT}, api.TZKTFallbacks...)
+		options.Transport = New(hosts, hosts, TzktCheck(api.TZKTMaxLag), api.HealthCheckInterval, nil)
+	}
+
+	return tzkt.NewTZKTWithOptions(api.TZKT, options)
+}
+
+// injection returns true for the path of an operation injection, which only trusted nodes are sent
+func injection(path string) bool {
+	return strings.HasPrefix(path, "/injection/")
+}
+
+// NodeCheck checks a tezos node is bootstrapped and synced with the network
+func NodeCheck(client *http.Client, host string) error {
+	var status struct {
+		Bootstrapped bool   `json:"bootstrapped"`
+		SyncState    string `json:"sync_state"`
+	}
+	if err := getJSON(client, host+"/chains/main/is_bootstrapped", &status); err != nil {
+		return errors.Wrapf(err, "failed to check tezos node '%s'", host)
+	}
+
+	if !status.Bootstrapped || status.SyncState == "unsynced" {
+		return errors.Errorf("tezos node '%s' isn't bootstrapped", host)
+	}
+
+	return nil
+}
+
+// TzktCheck returns a Check that a tzkt api is synced and at most maxLag levels behind the chain
+func TzktCheck(maxLag int) Check {
+	return func(client *http.Client, host string) error {
+		var head tzkt.Head
+		if err := getJSON(client, host+"/v1/head", &head); err != nil {
+			return errors.Wrapf(err, "failed to check tzkt api '%s'", host)
+		}
+
+		if !head.Synced {
+			return errors.Errorf("tzkt api '%s' isn't synced", host)
+		}
+
+		if lag := head.KnownLevel - head.Level; lag > maxLag {
+			return errors.Errorf("tzkt api '%s' is %d levels behind", host, lag)
+		}
+
+		return nil
+	}
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	byts, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("response returned code %d with body %s", resp.StatusCode, string(byts))
+	}
+
+	return json.Unmarshal(byts, v)
+}
@@ -0,0 +1,230 @@
+package failover
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Check returns an error if the api at host isn't fit to serve requests
+type Check func(client *http.Client, host string) error
+
+type endpoint struct {
+	host    string
+	trusted bool
+	healthy bool
+	checked time.Time
+}
+
+/*
+Endpoints is an http.RoundTripper spreading the requests to an api over several endpoints serving it.
+Requests go to the first healthy endpoint in order, and fail over to the next one when an endpoint can't be
+reached, is rate limiting or is unavailable. An endpoint's health is checked again once interval has passed
+since its last check, so that an endpoint that recovers is used again. If no endpoint is healthy they are
+all still tried, in order. Requests that only trusted endpoints may serve never go to the others.
+*/
+type Endpoints struct {
+	mu          sync.Mutex
+	endpoints   []*endpoint
+	check       Check
+	interval    time.Duration
+	trustedOnly func(path string) bool
+	client      *http.Client
+	transport   http.RoundTripper
+	now         func() time.Time
+}
+
+/*
+New returns a pointer to new Endpoints for hosts, in order of preference. The hosts in trusted are the only
+ones that serve the requests trustedOnly returns true for, given the path and query of the request.
+*/
+func New(hosts, trusted []string, check Check, interval time.Duration, trustedOnly func(path string) bool) *Endpoints {
+	transport := &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout: 10 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: 4,
+	}
+
+	e := &Endpoints{
+		check:       check,
+		interval:    interval,
+		trustedOnly: trustedOnly,
+		client:      &http.Client{Timeout: 10 * time.Second, Transport: transport},
+		transport:   transport,
+		now:         time.Now,
+	}
+
+	isTrusted := map[string]bool{}
+	for _, host := range trusted {
+		isTrusted[cleanseHost(host)] = true
+	}
+
+	for _, host := range hosts {
+		host = cleanseHost(host)
+		e.endpoints = append(e.endpoints, &endpoint{host: host, trusted: isTrusted[host]})
+	}
+
+	return e
+}
+
+// Hosts returns the endpoints' hosts, healthy ones first, in the order requests would go to them
+func (e *Endpoints) Hosts() []string {
+	var hosts []string
+	for _, endpoint := range e.ordered(false) {
+		hosts = append(hosts, endpoint.host)
+	}
+
+	return hosts
+}
+
+// RoundTrip satisfies http.RoundTripper for requests made to any of the endpoints
+func (e *Endpoints) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, ok := e.relative(req.URL.String())
+	if !ok {
+		return e.transport.RoundTrip(req)
+	}
+
+	trustedOnly := e.trustedOnly != nil && e.trustedOnly(path)
+	endpoints := e.ordered(trustedOnly)
+	if len(endpoints) == 0 {
+		return nil, errors.Errorf("failed to find a trusted endpoint for '%s'", path)
+	}
+
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	getBody := req.GetBody
+	if getBody == nil && req.Body != nil && req.Body != http.NoBody {
+		byts, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read request body")
+		}
+		getBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(byts)), nil }
+	}
+
+	var resp *http.Response
+	var err error
+	for i, endpoint := range endpoints {
+		var r *http.Request
+		r, err = retarget(req, endpoint.host+path, getBody)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = e.transport.RoundTrip(r)
+		if err == nil && !unavailable(resp.StatusCode) {
+			return resp, nil
+		}
+
+		e.setHealth(endpoint, false)
+		if resp != nil && i < len(endpoints)-1 {
+			resp.Body.Close()
+		}
+	}
+
+	return resp, err
+}
+
+// relative returns the path and query of a request url built on the host of any of the endpoints
+func (e *Endpoints) relative(u string) (string, bool) {
+	for _, endpoint := range e.endpoints {
+		path := strings.TrimPrefix(u, endpoint.host)
+		if len(path) < len(u) && (path == "" || path[0] == '/' || path[0] == '?') {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// ordered returns the endpoints eligible for a request, checking the health of those due, healthy ones first
+func (e *Endpoints) ordered(trustedOnly bool) []*endpoint {
+	var healthy, unhealthy []*endpoint
+	for _, endpoint := range e.endpoints {
+		if trustedOnly && !endpoint.trusted {
+			continue
+		}
+
+		if e.isHealthy(endpoint) {
+			healthy = append(healthy, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+func (e *Endpoints) isHealthy(endpoint *endpoint) bool {
+	e.mu.Lock()
+	due := endpoint.checked.IsZero() || e.now().Sub(endpoint.checked) >= e.interval
+	healthy := endpoint.healthy
+	e.mu.Unlock()
+
+	if !due || e.check == nil {
+		return healthy || e.check == nil
+	}
+
+	healthy = e.check(e.client, endpoint.host) == nil
+	e.setHealth(endpoint, healthy)
+
+	return healthy
+}
+
+func (e *Endpoints) setHealth(endpoint *endpoint, healthy bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	endpoint.healthy = healthy
+	endpoint.checked = e.now()
+}
+
+// retarget returns a copy of req sent to u, with a fresh copy of the body for every endpoint tried
+func retarget(req *http.Request, u string, getBody func() (io.ReadCloser, error)) (*http.Request, error) {
+	target, err := url.Parse(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to construct request to '%s'", u)
+	}
+
+	r := req.Clone(req.Context())
+	r.URL = target
+	r.Host = ""
+
+	if getBody != nil {
+		if r.Body, err = getBody(); err != nil {
+			return nil, errors.Wrapf(err, "failed to construct request to '%s'", u)
+		}
+	}
+
+	return r, nil
+}
+
+// unavailable returns true for the statuses of an endpoint that can't serve requests at the moment
+func unavailable(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+func cleanseHost(host string) string {
+	host = strings.TrimSuffix(host, "/")
+	if host != "" && !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		host = "http://" + host //default to http
+	}
+
+	return host
+}
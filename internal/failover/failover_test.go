@@ -0,0 +1,236 @@
+package failover
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+// server is an endpoint that can be made unavailable, recording the requests it serves
+type server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	down     bool
+	requests []string
+}
+
+func newServer(name string) *server {
+	s := &server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.URL.Path == "/health" {
+			w.Write([]byte(`{}`))
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		s.requests = append(s.requests, r.URL.RequestURI()+string(body))
+		w.Write([]byte(name))
+	}))
+
+	return s
+}
+
+func (s *server) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *server) served() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func check(client *http.Client, host string) error {
+	return getJSON(client, host+"/health", &struct{}{})
+}
+
+func Test_RoundTrip(t *testing.T) {
+	primary, fallback := newServer("primary"), newServer("fallback")
+	defer primary.Close()
+	defer fallback.Close()
+
+	now := time.Now()
+	endpoints := New([]string{primary.URL, fallback.URL + "/"}, []string{primary.URL}, check, time.Minute, injection)
+	endpoints.now = func() time.Time { return now }
+	client := &http.Client{Transport: endpoints}
+
+	get := func(path string) string {
+		resp, err := client.Get(primary.URL + path)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	post := func(path, body string) int {
+		resp, err := client.Post(primary.URL+path, "application/json", strings.NewReader(body))
+		assert.Nil(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, "primary", get("/chains/main/blocks/head?a=b"))
+
+	// the primary goes down mid payout
+	primary.setDown(true)
+	assert.Equal(t, "fallback", get("/chains/main/blocks/head"))
+	assert.Equal(t, "fallback", get("/chains/main/blocks/head/context/constants"))
+	assert.Equal(t, []string{"/chains/main/blocks/head?a=b"}, primary.served())
+	assert.Equal(t, []string{"/chains/main/blocks/head", "/chains/main/blocks/head/context/constants"}, fallback.served())
+
+	// injections never go to the untrusted fallback
+	assert.Equal(t, http.StatusServiceUnavailable, post("/injection/operation", `"op"`))
+	assert.Len(t, fallback.served(), 2)
+
+	// the primary is used again once it is found healthy
+	primary.setDown(false)
+	assert.Equal(t, "fallback", get("/chains/main/blocks/head"))
+	now = now.Add(time.Minute)
+	assert.Equal(t, "primary", get("/chains/main/blocks/head"))
+	assert.Equal(t, http.StatusOK, post("/injection/operation", `"op"`))
+	assert.Equal(t, "/injection/operation\"op\"", primary.served()[2])
+
+	// bodies are sent again to the next endpoint
+	endpoints = New([]string{primary.URL, fallback.URL}, []string{primary.URL, fallback.URL}, nil, time.Minute, injection)
+	client = &http.Client{Transport: endpoints}
+	primary.setDown(true)
+	assert.Equal(t, http.StatusOK, post("/injection/operation", `"op"`))
+	assert.Equal(t, "/injection/operation\"op\"", fallback.served()[len(fallback.served())-1])
+
+	// every endpoint is still tried when none is healthy
+	fallback.setDown(true)
+	assert.Equal(t, http.StatusServiceUnavailable, post("/injection/operation", `"op"`))
+
+	// requests to other hosts aren't touched
+	other := newServer("other")
+	defer other.Close()
+	resp, err := client.Get(other.URL + "/v1/head")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"/v1/head"}, other.served())
+}
+
+func Test_Hosts(t *testing.T) {
+	primary, fallback := newServer("primary"), newServer("fallback")
+	defer primary.Close()
+	defer fallback.Close()
+
+	endpoints := New([]string{primary.URL, fallback.URL}, nil, check, time.Minute, nil)
+	assert.Equal(t, []string{primary.URL, fallback.URL}, endpoints.Hosts())
+
+	primary.setDown(true)
+	endpoints = New([]string{primary.URL, fallback.URL}, nil, check, time.Minute, nil)
+	assert.Equal(t, []string{fallback.URL, primary.URL}, endpoints.Hosts())
+}
+
+func Test_NodeCheck(t *testing.T) {
+	cases := []struct {
+		name     string
+		response string
+		err      bool
+		contains string
+	}{
+		{"is successful", `{"bootstrapped":true,"sync_state":"synced"}`, false, ""},
+		{"is successful without sync state", `{"bootstrapped":true}`, false, ""},
+		{"handles unbootstrapped node", `{"bootstrapped":false,"sync_state":"synced"}`, true, "isn't bootstrapped"},
+		{"handles unsynced node", `{"bootstrapped":true,"sync_state":"unsynced"}`, true, "isn't bootstrapped"},
+		{"handles bad json", `{`, true, "failed to check tezos node"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/chains/main/is_bootstrapped", r.URL.Path)
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			test.CheckErr(t, tt.err, tt.contains, NodeCheck(http.DefaultClient, server.URL))
+		})
+	}
+}
+
+func Test_TzktCheck(t *testing.T) {
+	cases := []struct {
+		name     string
+		head     string
+		err      bool
+		contains string
+	}{
+		{"is successful", `{"level":100,"knownLevel":100,"synced":true}`, false, ""},
+		{"is successful with lag", `{"level":97,"knownLevel":100,"synced":true}`, false, ""},
+		{"handles lag", `{"level":96,"knownLevel":100,"synced":true}`, true, "is 4 levels behind"},
+		{"handles unsynced api", `{"level":100,"knownLevel":100,"synced":false}`, true, "isn't synced"},
+		{"handles bad json", `{`, true, "failed to check tzkt api"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/head", r.URL.Path)
+				w.Write([]byte(tt.head))
+			}))
+			defer server.Close()
+
+			test.CheckErr(t, tt.err, tt.contains, TzktCheck(3)(http.DefaultClient, server.URL))
+		})
+	}
+}
+
+func Test_NewRPC(t *testing.T) {
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+
+	down := newServer("down")
+	down.setDown(true)
+	defer down.Close()
+
+	client, err := NewRPC(config.API{Tezos: down.URL, TezosFallbacks: []string{node.URL}, HealthCheckInterval: time.Minute})
+	assert.Nil(t, err)
+
+	head, err := client.Head()
+	assert.Nil(t, err)
+	assert.Equal(t, node.Level(), head.Header.Level)
+
+	_, err = NewRPC(config.API{Tezos: down.URL, TezosFallbacks: []string{down.URL + "/"}, HealthCheckInterval: time.Minute})
+	test.CheckErr(t, true, "failed to connect to any tezos node", err)
+}
+
+func Test_NewTZKT(t *testing.T) {
+	lagging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"level":90,"knownLevel":100,"synced":true}`))
+	}))
+	defer lagging.Close()
+
+	indexer := test.NewTzkt(test.TzktFixture{}, nil)
+	defer indexer.Close()
+
+	api := config.API{TZKT: lagging.URL, TZKTFallbacks: []string{indexer.URL}, TZKTMaxLag: 3, HealthCheckInterval: time.Minute}
+	client := NewTZKT(api)
+	assert.Equal(t, lagging.URL, client.Host)
+
+	head, err := client.GetHead()
+	assert.Nil(t, err)
+	assert.Equal(t, tzkt.Head{Synced: true}, head)
+}
@@ -0,0 +1,162 @@
+package ledger
+
+import (
+	"sort"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+/*
+Expectation is a payout made from a cycle's expected rewards: the total rewards it distributed, the net
+rewards every delegator and liquidity provider was owed by it and what every address was sent. Once the
+cycle is over and the payout is trued up, the same amounts computed from the cycle's actual rewards are
+recorded alongside.
+*/
+type Expectation struct {
+	Cycle         int            `json:"cycle"`
+	Rewards       int            `json:"rewards"`
+	Expected      map[string]int `json:"expected"`
+	Paid          map[string]int `json:"paid,omitempty"`
+	ActualRewards int            `json:"actual_rewards,omitempty"`
+	Actual        map[string]int `json:"actual,omitempty"`
+}
+
+// TruedUp returns whether the payout was trued up against the cycle's actual rewards
+func (e Expectation) TruedUp() bool {
+	return e.Actual != nil
+}
+
+// RewardsSplit returns the payout with what every address was sent by it, for it to be reconciled
+func (e Expectation) RewardsSplit() tzkt.RewardsSplit {
+	var addresses []string
+	for address := range e.Paid {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	rewardsSplit := tzkt.RewardsSplit{Cycle: e.Cycle}
+	for _, address := range addresses {
+		rewardsSplit.Delegators = append(rewardsSplit.Delegators, tzkt.Delegator{Address: address, NetRewards: e.Paid[address], Status: tzkt.Paid})
+	}
+
+	return rewardsSplit
+}
+
+/*
+Expectations keeps the payouts made from expected rewards in a json file, until they're trued up against the
+actual rewards of their cycle. Trued up payouts are kept so that the differences recorded can still be settled.
+*/
+type Expectations struct {
+	path   string
+	Cycles map[int]Expectation `json:"cycles"`
+}
+
+// LoadExpectations reads the expectations at path. Expectations that don't exist yet are empty.
+func LoadExpectations(path string) (*Expectations, error) {
+	expectations := &Expectations{
+		path:   path,
+		Cycles: map[int]Expectation{},
+	}
+
+	if err := load(path, expectations); err != nil {
+		return nil, err
+	}
+
+	if expectations.Cycles == nil {
+		expectations.Cycles = map[int]Expectation{}
+	}
+
+	return expectations, nil
+}
+
+// Expect records a payout made from expected rewards, replacing what was recorded for its cycle before
+func (e *Expectations) Expect(rewardsSplit tzkt.RewardsSplit) {
+	e.Cycles[rewardsSplit.Cycle] = Expectation{
+		Cycle:    rewardsSplit.Cycle,
+		Rewards:  total(rewardsSplit),
+		Expected: owed(rewardsSplit),
+		Paid:     paid(rewardsSplit),
+	}
+}
+
+/*
+TrueUp records what the actual rewards of a cycle owe every delegator and liquidity provider next to what its
+payout made from expected rewards did, and returns the cycle's expectation. It fails if no payout was made from
+the cycle's expected rewards.
+*/
+func (e *Expectations) TrueUp(rewardsSplit tzkt.RewardsSplit) (Expectation, error) {
+	expectation, ok := e.Cycles[rewardsSplit.Cycle]
+	if !ok {
+		return expectation, errors.Errorf("failed to true up cycle %d: no payout was made from its expected rewards", rewardsSplit.Cycle)
+	}
+
+	expectation.ActualRewards = total(rewardsSplit)
+	expectation.Actual = owed(rewardsSplit)
+	e.Cycles[rewardsSplit.Cycle] = expectation
+
+	return expectation, nil
+}
+
+// Save writes the expectations back to their file, replacing it only once they're fully written
+func (e *Expectations) Save() error {
+	return save(e.path, e)
+}
+
+// total returns the rewards a payout distributed
+func total(rewardsSplit tzkt.RewardsSplit) int {
+	if rewardsSplit.Accounting == nil {
+		return 0
+	}
+
+	return rewardsSplit.Accounting.Total
+}
+
+// owed returns the net rewards of every delegator and liquidity provider that were paid or are still owed
+func owed(rewardsSplit tzkt.RewardsSplit) map[string]int {
+	amounts := map[string]int{}
+	add := func(address string, netRewards int, status tzkt.Status) {
+		if status.Owed() || !status.Skipped() {
+			amounts[address] += netRewards
+		}
+	}
+
+	for _, delegator := range rewardsSplit.Delegators {
+		if delegator.LiquidityProviders == nil {
+			add(delegator.Address, delegator.NetRewards, delegator.Status)
+			continue
+		}
+
+		for _, liquidityProvider := range delegator.LiquidityProviders {
+			add(liquidityProvider.Address, liquidityProvider.NetRewards, liquidityProvider.Status)
+		}
+	}
+
+	return amounts
+}
+
+// paid returns what the payout sent every delegator, liquidity provider and the address sent the skipped rewards
+func paid(rewardsSplit tzkt.RewardsSplit) map[string]int {
+	amounts := map[string]int{}
+	for _, delegator := range rewardsSplit.Delegators {
+		if delegator.LiquidityProviders == nil {
+			if !delegator.Status.Skipped() {
+				amounts[delegator.Address] += delegator.Amount()
+			}
+			continue
+		}
+
+		for _, liquidityProvider := range delegator.LiquidityProviders {
+			if !liquidityProvider.Status.Skipped() {
+				amounts[liquidityProvider.Address] += liquidityProvider.Amount()
+			}
+		}
+	}
+
+	if skipped := rewardsSplit.SkippedRewards; skipped != nil && skipped.Policy == config.SendSkippedRewards {
+		amounts[skipped.Address] += skipped.Amount
+	}
+
+	return amounts
+}
@@ -0,0 +1,78 @@
+package ledger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Expectations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "true-up.json")
+	expectations, err := LoadExpectations(path)
+	assert.Nil(t, err)
+
+	split := func(accounting *tzkt.Accounting, a, e, f int) tzkt.RewardsSplit {
+		return tzkt.RewardsSplit{
+			Cycle:      270,
+			Accounting: accounting,
+			Delegators: tzkt.Delegators{
+				{Address: "tz1a", NetRewards: a, CarriedOver: 10, Status: tzkt.Paid},
+				{Address: "tz1b", NetRewards: 50, Status: tzkt.CarriedOver},
+				{Address: "tz1c", NetRewards: 50, Status: tzkt.Blacklisted},
+				{
+					Address: "KT1a",
+					Status:  tzkt.Redirected,
+					LiquidityProviders: []tzkt.LiquidityProvider{
+						{Address: "tz1e", NetRewards: e, Status: tzkt.BelowMinimum},
+						{Address: "tz1f", NetRewards: f, Status: tzkt.Paid},
+					},
+				},
+			},
+		}
+	}
+
+	_, err = expectations.TrueUp(split(nil, 400, 20, 2000))
+	test.CheckErr(t, true, "failed to true up cycle 270: no payout was made from its expected rewards", err)
+
+	expectations.Expect(split(&tzkt.Accounting{Total: 3000}, 400, 20, 2000))
+	assert.False(t, expectations.Cycles[270].TruedUp())
+
+	expectation, err := expectations.TrueUp(split(&tzkt.Accounting{Total: 2900}, 380, 20, 2100))
+	assert.Nil(t, err)
+	assert.True(t, expectation.TruedUp())
+	assert.Equal(t, Expectation{
+		Cycle:         270,
+		Rewards:       3000,
+		Expected:      map[string]int{"tz1a": 400, "tz1b": 50, "tz1f": 2000},
+		Paid:          map[string]int{"tz1a": 410, "tz1f": 2000},
+		ActualRewards: 2900,
+		Actual:        map[string]int{"tz1a": 380, "tz1b": 50, "tz1f": 2100},
+	}, expectation)
+
+	// what was sent is what the payout is reconciled against
+	assert.Equal(t, tzkt.RewardsSplit{
+		Cycle: 270,
+		Delegators: tzkt.Delegators{
+			{Address: "tz1a", NetRewards: 410, Status: tzkt.Paid},
+			{Address: "tz1f", NetRewards: 2000, Status: tzkt.Paid},
+		},
+	}, expectation.RewardsSplit())
+
+	assert.Nil(t, expectations.Save())
+	saved, err := LoadExpectations(path)
+	assert.Nil(t, err)
+	assert.Equal(t, expectations.Cycles, saved.Cycles)
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{`), 0644))
+	_, err = LoadExpectations(path)
+	test.CheckErr(t, true, "failed to load ledger", err)
+}
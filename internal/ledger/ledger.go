@@ -0,0 +1,158 @@
+package ledger
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+// Entry is the net rewards of a cycle that were carried over instead of paid, and the cycle they were paid out with
+type Entry struct {
+	Cycle  int `json:"cycle"`
+	Amount int `json:"amount"`
+	PaidIn int `json:"paid_in,omitempty"`
+}
+
+/*
+Ledger keeps the rewards carried over for every address across cycles in a json file, until the
+accumulated amount reaches the baker's minimum payment and is paid out. Paid entries are kept so that
+the payouts of past cycles can still be computed as they were made.
+*/
+type Ledger struct {
+	path     string
+	Balances map[string][]Entry `json:"balances"`
+}
+
+// Load reads the ledger at path. A ledger that doesn't exist yet is empty.
+func Load(path string) (*Ledger, error) {
+	ledger := &Ledger{
+		path:     path,
+		Balances: map[string][]Entry{},
+	}
+
+	if err := load(path, ledger); err != nil {
+		return nil, err
+	}
+
+	if ledger.Balances == nil {
+		ledger.Balances = map[string][]Entry{}
+	}
+
+	return ledger, nil
+}
+
+// Pending returns the rewards carried over for address from the cycles before cycle that weren't paid before cycle
+func (l *Ledger) Pending(address string, cycle int) int {
+	var pending int
+	for _, entry := range l.Balances[address] {
+		if entry.Cycle < cycle && (entry.PaidIn == 0 || entry.PaidIn >= cycle) {
+			pending += entry.Amount
+		}
+	}
+
+	return pending
+}
+
+/*
+Record updates the ledger with a payout. The net rewards of every carried over delegator and liquidity
+provider, or of every one whose transfer failed on chain, are added for the payout's cycle, and the pending
+entries of every one that was paid are marked paid in the payout's cycle. Balances of addresses skipped for
+any other reason are left as they are.
+*/
+func (l *Ledger) Record(rewardsSplit tzkt.RewardsSplit) {
+	for _, delegator := range rewardsSplit.Delegators {
+		if delegator.LiquidityProviders == nil {
+			l.record(rewardsSplit.Cycle, delegator.Address, delegator.NetRewards, delegator.Status)
+			continue
+		}
+
+		for _, liquidityProvider := range delegator.LiquidityProviders {
+			l.record(rewardsSplit.Cycle, liquidityProvider.Address, liquidityProvider.NetRewards, liquidityProvider.Status)
+		}
+	}
+}
+
+func (l *Ledger) record(cycle int, address string, netRewards int, status tzkt.Status) {
+	if !status.Owed() && status.Skipped() {
+		return
+	}
+
+	// a cycle recorded again replaces what was recorded for it before
+	var entries []Entry
+	for _, entry := range l.Balances[address] {
+		if entry.Cycle == cycle {
+			continue
+		}
+
+		if !status.Skipped() && entry.Cycle < cycle && entry.PaidIn == 0 {
+			entry.PaidIn = cycle
+		}
+		entries = append(entries, entry)
+	}
+
+	if status.Owed() {
+		entries = append(entries, Entry{Cycle: cycle, Amount: netRewards})
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Cycle < entries[j].Cycle })
+	}
+
+	if len(entries) == 0 {
+		delete(l.Balances, address)
+		return
+	}
+	l.Balances[address] = entries
+}
+
+// Save writes the ledger back to its file, replacing it only once the new ledger is fully written
+func (l *Ledger) Save() error {
+	return save(l.path, l)
+}
+
+// load reads the json file at path into v, leaving v as it is if the file doesn't exist yet
+func load(path string, v interface{}) error {
+	byts, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to load ledger '%s'", path)
+	}
+
+	if err := json.Unmarshal(byts, v); err != nil {
+		return errors.Wrapf(err, "failed to load ledger '%s'", path)
+	}
+
+	return nil
+}
+
+// save writes v to the json file at path, replacing it only once v is fully written
+func save(path string, v interface{}) error {
+	byts, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "failed to save ledger '%s'", path)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "failed to save ledger '%s'", path)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(byts); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to save ledger '%s'", path)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "failed to save ledger '%s'", path)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "failed to save ledger '%s'", path)
+	}
+
+	return nil
+}
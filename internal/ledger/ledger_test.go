@@ -0,0 +1,125 @@
+package ledger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "ledger.json"), []byte(`{"balances":{"tz1a":[{"cycle":270,"amount":100}]}}`), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{`), 0644))
+
+	type want struct {
+		err      bool
+		contains string
+		balances map[string][]Entry
+	}
+
+	cases := []struct {
+		name string
+		path string
+		want want
+	}{
+		{"is successful", filepath.Join(dir, "ledger.json"), want{false, "", map[string][]Entry{"tz1a": {{Cycle: 270, Amount: 100}}}}},
+		{"is empty when missing", filepath.Join(dir, "missing.json"), want{false, "", map[string][]Entry{}}},
+		{"handles bad json", filepath.Join(dir, "bad.json"), want{true, "failed to load ledger", nil}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger, err := Load(tt.path)
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			if ledger != nil {
+				assert.Equal(t, tt.want.balances, ledger.Balances)
+			}
+		})
+	}
+}
+
+func Test_Pending(t *testing.T) {
+	ledger := &Ledger{
+		Balances: map[string][]Entry{
+			"tz1a": {{Cycle: 268, Amount: 100, PaidIn: 270}, {Cycle: 270, Amount: 200}, {Cycle: 271, Amount: 300}},
+		},
+	}
+
+	assert.Equal(t, 100, ledger.Pending("tz1a", 270))
+	assert.Equal(t, 200, ledger.Pending("tz1a", 271))
+	assert.Equal(t, 500, ledger.Pending("tz1a", 272))
+	assert.Equal(t, 0, ledger.Pending("tz1a", 268))
+	assert.Equal(t, 0, ledger.Pending("tz1b", 272))
+}
+
+func Test_Record(t *testing.T) {
+	ledger := &Ledger{
+		Balances: map[string][]Entry{
+			"tz1a": {{Cycle: 268, Amount: 100}},
+			"tz1b": {{Cycle: 268, Amount: 100}},
+			"tz1c": {{Cycle: 268, Amount: 100}},
+			"tz1d": {{Cycle: 268, Amount: 100}, {Cycle: 270, Amount: 150}},
+			"tz1g": {{Cycle: 268, Amount: 100}},
+		},
+	}
+
+	ledger.Record(tzkt.RewardsSplit{
+		Cycle: 270,
+		Delegators: tzkt.Delegators{
+			{Address: "tz1a", NetRewards: 400, CarriedOver: 100, Status: tzkt.Paid},
+			{Address: "tz1b", NetRewards: 50, CarriedOver: 100, Status: tzkt.CarriedOver},
+			{Address: "tz1c", NetRewards: 50, CarriedOver: 100, Status: tzkt.Blacklisted},
+			{Address: "tz1d", NetRewards: 200, CarriedOver: 100, Status: tzkt.CarriedOver},
+			{
+				Address: "KT1a",
+				Status:  tzkt.Redirected,
+				LiquidityProviders: []tzkt.LiquidityProvider{
+					{Address: "tz1e", NetRewards: 20, Status: tzkt.CarriedOver},
+					{Address: "tz1f", NetRewards: 2000, Status: tzkt.Paid},
+				},
+			},
+			{Address: "tz1g", NetRewards: 300, CarriedOver: 100, Status: tzkt.Failed},
+		},
+	})
+
+	assert.Equal(t, map[string][]Entry{
+		"tz1a": {{Cycle: 268, Amount: 100, PaidIn: 270}},
+		"tz1b": {{Cycle: 268, Amount: 100}, {Cycle: 270, Amount: 50}},
+		"tz1c": {{Cycle: 268, Amount: 100}},
+		"tz1d": {{Cycle: 268, Amount: 100}, {Cycle: 270, Amount: 200}},
+		"tz1e": {{Cycle: 270, Amount: 20}},
+		"tz1g": {{Cycle: 268, Amount: 100}, {Cycle: 270, Amount: 300}},
+	}, ledger.Balances)
+}
+
+func Test_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ledger.json")
+	ledger, err := Load(path)
+	assert.Nil(t, err)
+
+	ledger.Balances["tz1a"] = []Entry{{Cycle: 270, Amount: 100}}
+	assert.Nil(t, ledger.Save())
+
+	saved, err := Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, ledger.Balances, saved.Balances)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	ledger.path = filepath.Join(dir, "missing", "ledger.json")
+	test.CheckErr(t, true, "failed to save ledger", ledger.Save())
+}
@@ -2,9 +2,11 @@ package notifier
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -63,6 +65,43 @@ func NewPayoutNotifier(input PayoutNotifierInput) PayoutNotifier {
 	}
 }
 
+/*
+PayoutMessage returns the notification for the payout of cycle, counting the delegators and liquidity providers
+that were skipped by reason.
+*/
+func PayoutMessage(cycle int, rewardsSplit tzkt.RewardsSplit) string {
+	skipped := map[tzkt.Status]int{}
+	for _, delegator := range rewardsSplit.Delegators {
+		if delegator.LiquidityProviders == nil {
+			skipped[delegator.Status]++
+			continue
+		}
+
+		for _, liquidityProvider := range delegator.LiquidityProviders {
+			skipped[liquidityProvider.Status]++
+		}
+	}
+
+	var reasons []string
+	for _, status := range []tzkt.Status{tzkt.Blacklisted, tzkt.BelowMinimum, tzkt.NeedsBurn, tzkt.RejectsTez, tzkt.CarriedOver, tzkt.Failed} {
+		if skipped[status] > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d %s", skipped[status], strings.Replace(string(status), "_", " ", -1)))
+		}
+	}
+
+	msg := fmt.Sprintf("[TZPAY] payout for cycle %d: \n%s\n", cycle, rewardsSplit.OperationLink)
+	if len(reasons) > 0 {
+		msg += fmt.Sprintf("skipped: %s\n", strings.Join(reasons, ", "))
+	}
+
+	return msg + " #tezos #blockchain"
+}
+
+// UnsettledMessage returns the notification for the payout of cycle that failed after injecting some of its operations
+func UnsettledMessage(cycle int, rewardsSplit tzkt.RewardsSplit) string {
+	return fmt.Sprintf("[TZPAY] payout for cycle %d failed after paying in part: \n%s\nthe rest must be paid by hand\n", cycle, rewardsSplit.OperationLink)
+}
+
 // Notify -
 func (p *PayoutNotifier) Notify(msg string) error {
 	for _, notifier := range p.notifiers {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/goat-systems/go-tezos/v3/rpc"
 	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -204,3 +205,53 @@ func Test_notify(t *testing.T) {
 		})
 	}
 }
+
+func Test_PayoutMessage(t *testing.T) {
+	cases := []struct {
+		name         string
+		rewardsSplit tzkt.RewardsSplit
+		want         string
+	}{
+		{
+			"is successful without skipped delegators",
+			tzkt.RewardsSplit{
+				OperationLink: []string{"https://tzkt.io/oo1"},
+				Delegators: tzkt.Delegators{
+					{Address: "tz1a", Status: tzkt.Paid},
+				},
+			},
+			"[TZPAY] payout for cycle 270: \n[https://tzkt.io/oo1]\n #tezos #blockchain",
+		},
+		{
+			"is successful with skipped delegators and liquidity providers",
+			tzkt.RewardsSplit{
+				OperationLink: []string{"https://tzkt.io/oo1"},
+				Delegators: tzkt.Delegators{
+					{Address: "tz1a", Status: tzkt.BelowMinimum},
+					{Address: "tz1b", Status: tzkt.BelowMinimum},
+					{Address: "KT1a", Status: tzkt.RejectsTez},
+					{
+						Address: "KT1b",
+						Status:  tzkt.Redirected,
+						LiquidityProviders: []tzkt.LiquidityProvider{
+							{Address: "tz1c", Status: tzkt.Blacklisted},
+							{Address: "tz1d", Status: tzkt.Paid},
+						},
+					},
+				},
+			},
+			"[TZPAY] payout for cycle 270: \n[https://tzkt.io/oo1]\nskipped: 1 blacklisted, 2 below minimum, 1 rejects tez\n #tezos #blockchain",
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PayoutMessage(270, tt.rewardsSplit))
+		})
+	}
+}
+
+func Test_UnsettledMessage(t *testing.T) {
+	rewardsSplit := tzkt.RewardsSplit{OperationLink: []string{"https://tzkt.io/oo1"}}
+	assert.Equal(t, "[TZPAY] payout for cycle 270 failed after paying in part: \n[https://tzkt.io/oo1]\nthe rest must be paid by hand\n", UnsettledMessage(270, rewardsSplit))
+}
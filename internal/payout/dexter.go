@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/config"
 	"github.com/goat-systems/tzpay/v3/internal/tzkt"
 	"github.com/pkg/errors"
 	"github.com/valyala/fastjson"
@@ -87,6 +88,7 @@ func (p *Payout) getLiquidityProvidersEarnings(contract tzkt.Delegator) (tzkt.De
 	}
 
 	var liquidityProviders []tzkt.LiquidityProvider
+	var covered, distributed int
 	for _, key := range liquidityProvidersAddresses {
 		found := true
 		balance, err := p.getBalanceFromBigMap(key, bigMap, cycle.BlockHash)
@@ -104,32 +106,38 @@ func (p *Payout) getLiquidityProvidersEarnings(contract tzkt.Delegator) (tzkt.De
 				Share:   float64(balance) / float64(totalLiquidity),
 			}
 
-			lp.GrossRewards = int(lp.Share * float64(contract.GrossRewards))
-			lp.Fee = int(float64(lp.GrossRewards) * p.config.Baker.Fee)
+			lp.GrossRewards = mulDiv(balance, contract.GrossRewards, totalLiquidity)
+			lp.Fee = fee(lp.GrossRewards, p.config.Baker.Fee)
 			lp.NetRewards = lp.GrossRewards - lp.Fee
 
-			if lp.NetRewards < p.config.Baker.MinimumPayment {
-				lp.BlackListed = true
-			}
+			covered += balance
+			distributed += lp.GrossRewards
 
-			if p.isInBlacklist(lp.Address) {
-				lp.BlackListed = true
-			}
+			lp.CarriedOver = p.carriedOver(lp.Address)
+			liquidityProviders = append(liquidityProviders, lp)
+		}
+	}
 
-			if !p.config.Baker.BakerPaysBurnFees {
-				requiresBurnFee, err := p.requiresBurnFee(lp.Address)
-				if err != nil {
-					return contract, errors.Wrapf(err, "failed to get earnings for liquidity providers for contract '%s'", contract.Address)
-				}
-				if requiresBurnFee {
-					lp.BlackListed = true
-				}
-			}
+	// balances are only needed to find empty accounts
+	if p.config.Baker.BurnFeePolicy() != config.PayBurnFees {
+		var addresses []string
+		for _, lp := range liquidityProviders {
+			addresses = append(addresses, lp.Address)
+		}
+		if err := p.lookupBalances(addresses); err != nil {
+			return contract, errors.Wrapf(err, "failed to get earnings for liquidity providers for contract '%s'", contract.Address)
+		}
+	}
 
-			liquidityProviders = append(liquidityProviders, lp)
+	for i := range liquidityProviders {
+		lp := &liquidityProviders[i]
+		if lp.Status, lp.BurnFee, err = p.status(lp.Address, lp.Amount()); err != nil {
+			return contract, errors.Wrapf(err, "failed to get earnings for liquidity providers for contract '%s'", contract.Address)
 		}
 	}
 	contract.LiquidityProviders = liquidityProviders
+	contract.Status = tzkt.Redirected
+	contract.Dust = mulDiv(covered, contract.GrossRewards, totalLiquidity) - distributed
 
 	return contract, nil
 }
@@ -239,7 +247,19 @@ func getBigMapV15(contract ExchangeContractV15) (int, error) {
 }
 
 func (p *Payout) getLiquidityProvidersList(target string) ([]string, error) {
-	transactions, err := p.tzkt.GetTransactions([]tzkt.URLParameters{
+	// keep the order of the first liquidity added so payouts are deterministic
+	liquidityProvidersAddresses := map[string]struct{}{} // map to weed out duplicates
+	out := []string{}
+	err := p.tzkt.PageTransactions(func(transactions []tzkt.Transaction) error {
+		for _, lp := range transactions {
+			if _, ok := liquidityProvidersAddresses[lp.Sender.Address]; ok {
+				continue
+			}
+			liquidityProvidersAddresses[lp.Sender.Address] = struct{}{}
+			out = append(out, lp.Sender.Address)
+		}
+		return nil
+	}, []tzkt.URLParameters{
 		{
 			Key:   "parameters.as",
 			Value: "*addLiquidity*",
@@ -248,25 +268,11 @@ func (p *Payout) getLiquidityProvidersList(target string) ([]string, error) {
 			Key:   "target",
 			Value: target,
 		},
-		{
-			Key:   "limit",
-			Value: "10000",
-		},
 	}...)
-	if err != nil || len(transactions) >= 10000 {
+	if err != nil {
 		return []string{}, errors.Wrapf(err, "failed to get list of liquidity providers for '%s'", target)
 	}
 
-	liquidityProvidersAddresses := map[string]struct{}{} // map to weed out duplicates
-	for _, lp := range transactions {
-		liquidityProvidersAddresses[lp.Sender.Address] = struct{}{}
-	}
-
-	out := []string{}
-	for key := range liquidityProvidersAddresses {
-		out = append(out, key)
-	}
-
 	return out, nil
 }
 
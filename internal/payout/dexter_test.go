@@ -2,6 +2,7 @@ package payout
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/goat-systems/go-tezos/v3/rpc"
@@ -37,7 +38,7 @@ func Test_constructDexterContractPayout(t *testing.T) {
 					},
 					config: config.Config{
 						Baker: config.Baker{
-							Fee: 0.05,
+							Fee: 500,
 							DexterLiquidityContracts: []string{
 								"tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
 							},
@@ -66,7 +67,7 @@ func Test_constructDexterContractPayout(t *testing.T) {
 					tzkt: &test.TzktMock{},
 					config: config.Config{
 						Baker: config.Baker{
-							Fee: 0.05,
+							Fee: 500,
 							DexterLiquidityContracts: []string{
 								"tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
 							},
@@ -92,10 +93,10 @@ func Test_constructDexterContractPayout(t *testing.T) {
 							GrossRewards: 149992399,
 							Share:        1,
 							Fee:          7499619,
-							BlackListed:  false,
+							Status:       tzkt.Paid,
 						},
 					},
-					BlackListed: false},
+					Status: tzkt.Redirected},
 			},
 		},
 	}
@@ -203,10 +204,10 @@ func Test_getLiquidityProvidersEarnings(t *testing.T) {
 							GrossRewards: 149992399,
 							Share:        1,
 							Fee:          7499619,
-							BlackListed:  false,
+							Status:       tzkt.Paid,
 						},
 					},
-					BlackListed: false},
+					Status: tzkt.Redirected},
 			},
 		},
 		{
@@ -233,10 +234,10 @@ func Test_getLiquidityProvidersEarnings(t *testing.T) {
 							GrossRewards: 73932,
 							Share:        0.0004929086226096927,
 							Fee:          3696,
-							BlackListed:  false,
+							Status:       tzkt.Paid,
 						},
 					},
-					BlackListed: false},
+					Status: tzkt.Redirected},
 			},
 		},
 	}
@@ -248,7 +249,7 @@ func Test_getLiquidityProvidersEarnings(t *testing.T) {
 				tzkt: tt.input.tzkt,
 				config: config.Config{
 					Baker: config.Baker{
-						Fee: 0.05,
+						Fee: 500,
 					},
 				},
 			}
@@ -313,6 +314,29 @@ func Test_getLiquidityProvidersList(t *testing.T) {
 			assert.Equal(t, tt.want.list, list)
 		})
 	}
+
+	// a popular pool has more liquidity added than fits in a single request
+	var transactions []tzkt.Transaction
+	var providers []string
+	for i := 0; i < 12000; i++ {
+		var transaction tzkt.Transaction
+		transaction.ID = i + 1
+		transaction.Sender.Address = fmt.Sprintf("tz1%05d", i%5000)
+		transaction.Target.Address = "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv"
+		transaction.Parameters = `{"entrypoint":"addLiquidity"}`
+		transactions = append(transactions, transaction)
+		if i < 5000 {
+			providers = append(providers, transaction.Sender.Address)
+		}
+	}
+
+	indexer := test.NewTzkt(test.TzktFixture{Transactions: transactions}, nil)
+	defer indexer.Close()
+
+	payout := Payout{tzkt: tzkt.NewTZKTWithOptions(indexer.URL, tzkt.Options{})}
+	list, err := payout.getLiquidityProvidersList("KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv")
+	assert.Nil(t, err)
+	assert.Equal(t, providers, list)
 }
 
 func Test_getBalanceFromBigMap(t *testing.T) {
@@ -0,0 +1,507 @@
+package payout
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/ledger"
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EndToEnd(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	cases := []struct {
+		name   string
+		inject bool
+	}{
+		{"dry run", false},
+		{"run", true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := test.LoadNode(test.Fixture("cycle-270"))
+			assert.Nil(t, err)
+			defer node.Close()
+
+			indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+			assert.Nil(t, err)
+			defer indexer.Close()
+
+			payout, err := New(endToEndConfig(node, indexer), 270, tt.inject, false)
+			assert.Nil(t, err)
+
+			rewardsSplit, err := payout.Execute()
+			assert.Nil(t, err)
+			assert.Len(t, rewardsSplit.Delegators, 4)
+
+			var transfers int
+			for _, delegator := range rewardsSplit.Delegators {
+				if delegator.LiquidityProviders == nil {
+					if !delegator.Status.Skipped() {
+						transfers++
+					}
+					continue
+				}
+
+				for _, liquidityProvider := range delegator.LiquidityProviders {
+					if !liquidityProvider.Status.Skipped() {
+						transfers++
+					}
+				}
+			}
+
+			// cycle 270 ends at level 1110016, the payout is included at level 1130598
+			assert.Equal(t, tzkt.Fiat{"usd": 2.5, "eur": 2.1}, rewardsSplit.Quotes.CycleEnd)
+			assert.Equal(t, tzkt.Fiat{"usd": 86.66, "eur": 72.8}, rewardsSplit.Delegators[0].Fiat.CycleEnd)
+
+			if !tt.inject {
+				assert.Nil(t, rewardsSplit.Quotes.Injection)
+				assert.Nil(t, rewardsSplit.Delegators[0].Fiat.Injection)
+				assert.Empty(t, rewardsSplit.OperationLink)
+				assert.Equal(t, 1130597, node.Level())
+				return
+			}
+
+			assert.Equal(t, tzkt.Fiat{"usd": 3, "eur": 2.55}, rewardsSplit.Quotes.Injection)
+			assert.Equal(t, tzkt.Fiat{"usd": 104, "eur": 88.4}, rewardsSplit.Delegators[0].Fiat.Injection)
+			assert.Equal(t, tzkt.Fiat{"usd": 334.82, "eur": 284.59}, rewardsSplit.BakerRewardsFiat.Injection)
+
+			assert.NotZero(t, transfers)
+			assert.Len(t, rewardsSplit.OperationLink, 1)
+			assert.Equal(t, 1130598, node.Level())
+
+			blocks := node.Blocks()
+			assert.Len(t, blocks, 1)
+			assert.Len(t, blocks[0].Operations[3], 1)
+			assert.Len(t, blocks[0].Operations[3][0].Contents, transfers)
+			assert.Equal(t, "https://tzkt.io/"+blocks[0].Operations[3][0].Hash, rewardsSplit.OperationLink[0])
+
+			for _, content := range blocks[0].Operations[3][0].Contents {
+				assert.Equal(t, "applied", content.Metadata.OperationResults.Status)
+			}
+
+			for _, delegator := range rewardsSplit.Delegators {
+				for _, liquidityProvider := range delegator.LiquidityProviders {
+					if !liquidityProvider.Status.Skipped() {
+						assert.Equal(t, blocks[0].Operations[3][0].Hash, liquidityProvider.OperationHash)
+					}
+				}
+
+				if delegator.LiquidityProviders == nil && !delegator.Status.Skipped() {
+					assert.Equal(t, blocks[0].Operations[3][0].Hash, delegator.OperationHash)
+				}
+			}
+		})
+	}
+}
+
+func Test_EndToEndQueue(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+
+	indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	payout, err := New(endToEndConfig(node, indexer), 270, true, false)
+	assert.Nil(t, err)
+
+	queue := NewQueue(nil)
+	queue.tickerDuration = time.Millisecond * 10
+	queue.Start()
+	queue.Enqueue(*payout)
+
+	timeout := time.After(time.Second * 5)
+	for len(node.Blocks()) == 0 {
+		select {
+		case <-timeout:
+			t.Fatal("payout was never included in a block")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+
+	assert.Equal(t, 1130598, node.Level())
+	assert.Less(t, node.Balance("tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo"), 1000000000)
+}
+
+func Test_EndToEndQueueUnsettled(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+	node.Refuse = 2
+
+	indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	cfg := endToEndConfig(node, indexer)
+	cfg.Operations.BatchSize = 2
+
+	payout, err := New(cfg, 270, true, false)
+	assert.Nil(t, err)
+
+	queue := NewQueue(nil)
+	queue.tickerDuration = time.Millisecond * 10
+	logger, hook := logtest.NewNullLogger()
+	queue.logger = logger
+	queue.Start()
+	queue.Enqueue(*payout)
+
+	timeout := time.After(time.Second * 5)
+	for len(hook.Entries) == 0 || hook.LastEntry().Level > logrus.ErrorLevel {
+		select {
+		case <-timeout:
+			t.Fatal("payout never failed")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+	assert.Equal(t, "Payout was or may have been paid in part, it must be settled by hand.", hook.LastEntry().Message)
+
+	// the second batch was refused, and the first isn't injected again by the payout being retried
+	time.Sleep(time.Millisecond * 200)
+	assert.True(t, queue.Empty())
+	assert.Len(t, node.Blocks(), 1)
+	assert.Len(t, node.Blocks()[0].Operations[3], 1)
+	assert.Empty(t, node.Mempool())
+}
+
+func Test_EndToEndReveal(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+	node.SetManagerKey("tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo", "")
+
+	indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	payout, err := New(endToEndConfig(node, indexer), 270, true, false)
+	assert.Nil(t, err)
+
+	rewardsSplit, err := payout.Execute()
+	assert.Nil(t, err)
+
+	blocks := node.Blocks()
+	assert.Len(t, blocks, 1)
+	contents := blocks[0].Operations[3][0].Contents
+	assert.Equal(t, rpc.REVEAL, contents[0].Kind)
+	assert.Equal(t, 101, contents[0].Counter)
+	assert.Equal(t, rpc.TRANSACTION, contents[1].Kind)
+	assert.Equal(t, "applied", contents[1].Metadata.OperationResults.Status)
+
+	assert.Equal(t, "edpkuHMDkMz46HdRXYwom3xRwqk3zQ5ihWX4j8dwo2R2h8o4gPcbN5", node.ManagerKey("tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo"))
+	assert.Equal(t, &tzkt.Reveal{
+		PublicKey:     "edpkuHMDkMz46HdRXYwom3xRwqk3zQ5ihWX4j8dwo2R2h8o4gPcbN5",
+		Fee:           1268,
+		OperationHash: blocks[0].Operations[3][0].Hash,
+	}, rewardsSplit.Reveal)
+}
+
+func Test_EndToEndCarryOver(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	dir, err := ioutil.TempDir("", "ledger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ledger.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"balances":{"KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC":[{"cycle":268,"amount":400000}]}}`), 0644))
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+
+	indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	cfg := endToEndConfig(node, indexer)
+	cfg.Baker.MinimumPayment = 32000000
+	cfg.Baker.CarryOver = true
+	cfg.Baker.CarryOverLedger = path
+
+	payout, err := New(cfg, 270, true, false)
+	assert.Nil(t, err)
+
+	rewardsSplit, err := payout.Execute()
+	assert.Nil(t, err)
+
+	// the rewards carried over from cycle 268 push the contract over the minimum payment
+	contract := rewardsSplit.Delegators[2]
+	assert.Equal(t, "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", contract.Address)
+	assert.Equal(t, tzkt.Paid, contract.Status)
+	assert.Equal(t, 400000, contract.CarriedOver)
+
+	liquidityProvider := rewardsSplit.Delegators[3].LiquidityProviders[0]
+	assert.Equal(t, tzkt.CarriedOver, liquidityProvider.Status)
+
+	var amount int64
+	for _, content := range node.Blocks()[0].Operations[3][0].Contents {
+		if content.Destination == contract.Address {
+			amount = content.Amount
+		}
+	}
+	assert.Equal(t, int64(contract.NetRewards+400000), amount)
+
+	saved, err := ledger.Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]ledger.Entry{
+		contract.Address:          {{Cycle: 268, Amount: 400000, PaidIn: 270}},
+		liquidityProvider.Address: {{Cycle: 270, Amount: liquidityProvider.NetRewards}},
+	}, saved.Balances)
+}
+
+func Test_EndToEndFailedTransfer(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	dir, err := ioutil.TempDir("", "ledger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+	node.Fail("KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC")
+	balance := node.Balance("KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC")
+
+	indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	path := filepath.Join(dir, "ledger.json")
+	cfg := endToEndConfig(node, indexer)
+	cfg.Baker.CarryOver = true
+	cfg.Baker.CarryOverLedger = path
+
+	payout, err := New(cfg, 270, true, false)
+	assert.Nil(t, err)
+
+	rewardsSplit, err := payout.Execute()
+	assert.Nil(t, err)
+
+	// the transfer failing fails the whole operation, the transfers before it are backtracked and the ones after skipped
+	assert.Equal(t, "backtracked", rewardsSplit.Delegators[0].Receipt.Status)
+	contract := rewardsSplit.Delegators[2]
+	assert.Equal(t, "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", contract.Address)
+	assert.Equal(t, &tzkt.Receipt{Status: "failed"}, contract.Receipt)
+	assert.Equal(t, "skipped", rewardsSplit.Delegators[3].LiquidityProviders[0].Receipt.Status)
+	assert.Equal(t, balance, node.Balance(contract.Address))
+
+	for _, delegator := range rewardsSplit.Delegators {
+		for _, liquidityProvider := range delegator.LiquidityProviders {
+			if liquidityProvider.OperationHash != "" {
+				assert.Equal(t, tzkt.Failed, liquidityProvider.Status)
+			}
+		}
+
+		if delegator.LiquidityProviders == nil {
+			assert.Equal(t, tzkt.Failed, delegator.Status)
+		}
+	}
+
+	// rewards that failed to be paid are still owed
+	saved, err := ledger.Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []ledger.Entry{{Cycle: 270, Amount: contract.NetRewards}}, saved.Balances[contract.Address])
+}
+
+func Test_EndToEndReorg(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+	node.Advance = true
+	node.Reorgs = 1
+
+	indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	cfg := endToEndConfig(node, indexer)
+	cfg.Operations.Confirmations = 2
+
+	balance := node.Balance("tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd")
+
+	payout, err := New(cfg, 270, true, false)
+	assert.Nil(t, err)
+
+	rewardsSplit, err := payout.Execute()
+	assert.Nil(t, err)
+
+	// the operation orphaned by the reorganization was injected again, and paid out once
+	blocks := node.Blocks()
+	assert.Len(t, blocks, 1)
+	hash := blocks[0].Operations[3][0].Hash
+	assert.Equal(t, []string{"https://tzkt.io/" + hash}, rewardsSplit.OperationLink)
+
+	delegator := rewardsSplit.Delegators[0]
+	assert.Equal(t, "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", delegator.Address)
+	assert.Equal(t, hash, delegator.OperationHash)
+	assert.Equal(t, balance+delegator.Amount(), node.Balance(delegator.Address))
+
+	// and confirmed by two blocks on top of the block it was included in
+	assert.Equal(t, blocks[0].Header.Level, payout.levels[hash])
+	assert.True(t, node.Level() >= blocks[0].Header.Level+2)
+}
+
+func Test_EndToEndReorgRequeued(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+	node.Advance = true
+	node.Reorgs = 1
+	node.Requeue = true
+
+	indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	cfg := endToEndConfig(node, indexer)
+	cfg.Operations.Confirmations = 2
+
+	balance := node.Balance("tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd")
+
+	payout, err := New(cfg, 270, true, false)
+	assert.Nil(t, err)
+
+	rewardsSplit, err := payout.Execute()
+	assert.Nil(t, err)
+
+	// injecting it again failed on its used counters, and the orphaned operation came back from the mempool
+	blocks := node.Blocks()
+	assert.Len(t, blocks, 1)
+	hash := blocks[0].Operations[3][0].Hash
+	assert.Equal(t, []string{"https://tzkt.io/" + hash}, rewardsSplit.OperationLink)
+
+	delegator := rewardsSplit.Delegators[0]
+	assert.Equal(t, hash, delegator.OperationHash)
+	assert.Equal(t, balance+delegator.Amount(), node.Balance(delegator.Address))
+	assert.Equal(t, blocks[0].Header.Level, payout.levels[hash])
+}
+
+func Test_EndToEndReorgUnsettled(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+	node.Advance = true
+	node.Reorgs = reinjections + 1
+
+	indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	cfg := endToEndConfig(node, indexer)
+	cfg.Operations.Confirmations = 2
+
+	balance := node.Balance("tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd")
+
+	payout, err := New(cfg, 270, true, false)
+	assert.Nil(t, err)
+
+	// every operation injected was dropped, any of them could still be included so the payout isn't retried
+	_, err = payout.Execute()
+	test.CheckErr(t, true, "was dropped by 4 reorganizations", err)
+	assert.True(t, IsUnsettled(err))
+	assert.Len(t, err.(*UnsettledError).Pending, reinjections+1)
+	assert.Empty(t, node.Blocks())
+	assert.Equal(t, balance, node.Balance("tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd"))
+}
+
+func Test_EndToEndExpected(t *testing.T) {
+	confirmationDurationInterval = time.Millisecond * 10
+	defer func() { confirmationDurationInterval = time.Second * 1 }()
+
+	dir, err := ioutil.TempDir("", "ledger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+
+	indexer, err := test.LoadTzkt(test.Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	path := filepath.Join(dir, "true-up.json")
+	cfg := endToEndConfig(node, indexer)
+	cfg.Baker.PayoutMode = config.ExpectedPayouts
+	cfg.Baker.TrueUpLedger = path
+
+	payout, err := New(cfg, 270, true, false)
+	assert.Nil(t, err)
+
+	rewardsSplit, err := payout.Execute()
+	assert.Nil(t, err)
+	assert.Len(t, node.Blocks(), 1)
+
+	// the payout is recorded to be trued up once the cycle is over
+	expectations, err := ledger.LoadExpectations(path)
+	assert.Nil(t, err)
+
+	expectation := expectations.Cycles[270]
+	assert.Equal(t, rewardsSplit.Accounting.Total, expectation.Rewards)
+	assert.Equal(t, rewardsSplit.Delegators[0].NetRewards, expectation.Expected[rewardsSplit.Delegators[0].Address])
+	assert.False(t, expectation.TruedUp())
+}
+
+func endToEndConfig(node *test.Node, indexer *test.Tzkt) config.Config {
+	return config.Config{
+		API: config.API{
+			TZKT:  indexer.URL,
+			Tezos: node.URL,
+		},
+		Baker: config.Baker{
+			Address:                  "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+			Fee:                      500,
+			MinimumPayment:           100,
+			DexterLiquidityContracts: []string{"KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv"},
+		},
+		Key: config.Key{
+			Esk:      "edesk1fddn27MaLcQVEdZpAYiyGQNm6UjtWiBfNP2ZenTy3CFsoSVJgeHM9pP9cvLJ2r5Xp2quQ5mYexW1LRKee2",
+			Password: "password12345##",
+		},
+		Operations: config.Operations{
+			NetworkFee:     2941,
+			GasLimit:       26283,
+			BatchSize:      125,
+			RevealFee:      1268,
+			RevealGasLimit: 10000,
+		},
+		Fiat: config.Fiat{
+			Currencies: []string{"usd", "eur"},
+		},
+	}
+}
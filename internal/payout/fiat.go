@@ -0,0 +1,97 @@
+package payout
+
+import (
+	"strconv"
+
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+/*
+valueInFiat values every line of the payout in the configured currencies at the end of the cycle and,
+once injected, at the level each operation was included at. The baker's summary is valued at the level
+of the last operation.
+*/
+func (p *Payout) valueInFiat(rewardsSplit *tzkt.RewardsSplit, operations []string) error {
+	if len(p.config.Fiat.Currencies) == 0 {
+		return nil
+	}
+
+	// the cycle's levels are read from tzkt, as the head's constants don't hold for cycles of earlier protocols
+	cycle, err := p.tzkt.GetCycle(p.cycle)
+	if err != nil {
+		return errors.Wrap(err, "failed to value payout in fiat")
+	}
+
+	cycleEnd, err := p.pricesAt(cycle.LastLevel)
+	if err != nil {
+		return errors.Wrap(err, "failed to value payout in fiat")
+	}
+
+	injection := map[string]tzkt.Fiat{}
+	var last string
+	for _, operation := range operations {
+		level, ok := p.levels[operation]
+		if !ok {
+			continue
+		}
+
+		if injection[operation], err = p.pricesAt(level); err != nil {
+			return errors.Wrap(err, "failed to value payout in fiat")
+		}
+		last = operation
+	}
+
+	value := func(mutez int, operation string) *tzkt.Valuation {
+		return &tzkt.Valuation{
+			CycleEnd:  cycleEnd.Value(mutez),
+			Injection: injection[operation].Value(mutez),
+		}
+	}
+
+	rewardsSplit.Quotes = &tzkt.Valuation{CycleEnd: cycleEnd, Injection: injection[last]}
+	rewardsSplit.BakerRewardsFiat = value(rewardsSplit.BakerRewards, last)
+	rewardsSplit.BakerCollectedFeesFiat = value(rewardsSplit.BakerCollectedFees, last)
+
+	for i := range rewardsSplit.Delegators {
+		delegator := &rewardsSplit.Delegators[i]
+		delegator.Fiat = value(delegator.Amount(), delegator.OperationHash)
+		for j := range delegator.LiquidityProviders {
+			liquidityProvider := &delegator.LiquidityProviders[j]
+			liquidityProvider.Fiat = value(liquidityProvider.Amount(), liquidityProvider.OperationHash)
+		}
+	}
+
+	if skipped := rewardsSplit.SkippedRewards; skipped != nil {
+		skipped.Fiat = value(skipped.Amount, skipped.OperationHash)
+	}
+
+	return nil
+}
+
+// pricesAt returns the price of a tez in the configured currencies at level
+func (p *Payout) pricesAt(level int) (tzkt.Fiat, error) {
+	quotes, err := p.tzkt.GetQuotes([]tzkt.URLParameters{
+		{
+			Key:   "level.le",
+			Value: strconv.Itoa(level),
+		},
+		{
+			Key:   "sort.desc",
+			Value: "level",
+		},
+		{
+			Key:   "limit",
+			Value: "1",
+		},
+	}...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get quotes at level %d", level)
+	}
+
+	if len(quotes) == 0 {
+		return nil, errors.Errorf("failed to get quotes at level %d: no quotes", level)
+	}
+
+	return quotes[0].Prices(p.config.Fiat.Currencies...), nil
+}
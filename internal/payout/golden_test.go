@@ -0,0 +1,103 @@
+package payout
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected.json of every golden case")
+
+/*
+Test_Golden runs a dry run payout for every directory in testdata/golden against a fake node and tzkt api,
+and compares the resulting rewards split with the directory's expected.json. The node.json, tzkt.json and
+config.json of a case are layered over the cycle-270 fixture and the ones in testdata/golden, so a case only
+holds what it changes. The payout is of the cycle of the only rewards split in the case's tzkt fixture.
+
+Run `go test ./internal/payout -run Test_Golden -update` to accept a change in the results.
+*/
+func Test_Golden(t *testing.T) {
+	root := filepath.Join("testdata", "golden")
+	files, err := ioutil.ReadDir(root)
+	assert.Nil(t, err)
+
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+
+		dirs := []string{test.Fixture("cycle-270"), root, filepath.Join(root, file.Name())}
+		t.Run(file.Name(), func(t *testing.T) {
+			var nodeFixture test.NodeFixture
+			if !assert.Nil(t, test.ReadFixture("node.json", &nodeFixture, dirs...)) {
+				return
+			}
+
+			var tzktFixture test.TzktFixture
+			if !assert.Nil(t, test.ReadFixture("tzkt.json", &tzktFixture, dirs...)) || !assert.Len(t, tzktFixture.RewardsSplits, 1) {
+				return
+			}
+
+			var cycle int
+			for key := range tzktFixture.RewardsSplits {
+				cycle, err = strconv.Atoi(key)
+				if !assert.Nil(t, err) {
+					return
+				}
+			}
+
+			var cfg config.Config
+			if !assert.Nil(t, test.ReadFixture("config.json", &cfg, dirs...)) {
+				return
+			}
+
+			node, err := test.NewNode(nodeFixture)
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer node.Close()
+
+			indexer := test.NewTzkt(tzktFixture, node)
+			defer indexer.Close()
+
+			cfg.API.Tezos = node.URL
+			cfg.API.TZKT = indexer.URL
+
+			// every case is a chain of its own, whose contracts may accept tez where another's don't
+			contracts = &contractCache{rejectsTez: map[string]bool{}}
+
+			payout, err := New(cfg, cycle, false, false)
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			rewardsSplit, err := payout.Execute()
+			if !assert.Nil(t, err) {
+				return
+			}
+
+			actual, err := json.MarshalIndent(rewardsSplit, "", "  ")
+			assert.Nil(t, err)
+			actual = append(actual, '\n')
+
+			path := filepath.Join(root, file.Name(), "expected.json")
+			if *update {
+				assert.Nil(t, ioutil.WriteFile(path, actual, 0644))
+				return
+			}
+
+			expected, err := ioutil.ReadFile(path)
+			if !assert.Nil(t, err) {
+				return
+			}
+			assert.Equal(t, string(expected), string(actual))
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package payout
+
+import (
+	"math/big"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+)
+
+/*
+mulDiv returns amount * numerator / denominator in mutez. The product is computed with big
+integers so that it can't overflow, and the result is always rounded down. Anything lost to
+rounding is reported as dust rather than paid out.
+*/
+func mulDiv(amount, numerator, denominator int) int {
+	if denominator == 0 {
+		return 0
+	}
+
+	out := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(int64(numerator)))
+	return int(out.Quo(out, big.NewInt(int64(denominator))).Int64())
+}
+
+// fee returns the baker's fee on gross rewards, rounded down in favor of the delegator
+func fee(gross int, fee config.BasisPoints) int {
+	return mulDiv(gross, int(fee), config.BasisPointsPerUnit)
+}
@@ -0,0 +1,55 @@
+package payout
+
+import (
+	"testing"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_mulDiv(t *testing.T) {
+	type input struct {
+		amount      int
+		numerator   int
+		denominator int
+	}
+
+	cases := []struct {
+		name  string
+		input input
+		want  int
+	}{
+		{
+			"is successful",
+			input{60545965782, 446351787, 740613513605},
+			36489747,
+		},
+		{
+			"rounds down",
+			input{10, 1, 3},
+			3,
+		},
+		{
+			"handles products larger than 64 bits",
+			input{740613513605, 446351787000, 740613513605},
+			446351787000,
+		},
+		{
+			"handles zero denominator",
+			input{10, 1, 0},
+			0,
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, mulDiv(tt.input.amount, tt.input.numerator, tt.input.denominator))
+		})
+	}
+}
+
+func Test_fee(t *testing.T) {
+	assert.Equal(t, 1824487, fee(36489747, config.BasisPoints(500)))
+	assert.Equal(t, 0, fee(19, config.BasisPoints(500)))
+	assert.Equal(t, 36489747, fee(36489747, config.BasisPoints(config.BasisPointsPerUnit)))
+}
@@ -3,12 +3,17 @@ package payout
 import (
 	"encoding/hex"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/goat-systems/go-tezos/v3/forge"
 	"github.com/goat-systems/go-tezos/v3/keys"
 	"github.com/goat-systems/go-tezos/v3/rpc"
 	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/failover"
+	"github.com/goat-systems/tzpay/v3/internal/ledger"
+	"github.com/goat-systems/tzpay/v3/internal/rewards"
 	"github.com/goat-systems/tzpay/v3/internal/tzkt"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -24,38 +29,111 @@ var (
 	confirmationTimoutInterval   = time.Minute * 2
 )
 
+// contracts is shared by every payout of the process, so a contract's entrypoints are looked up once rather than every cycle
+var contracts = &contractCache{rejectsTez: map[string]bool{}}
+
+// contractCache remembers which contracts reject plain transfers, which can't change once a contract is originated
+type contractCache struct {
+	mu         sync.Mutex
+	rejectsTez map[string]bool
+}
+
+func (c *contractCache) lookup(contract string) (bool, bool) {
+	if c == nil {
+		return false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rejectsTez, ok := c.rejectsTez[contract]
+	return rejectsTez, ok
+}
+
+func (c *contractCache) store(contract string, rejectsTez bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rejectsTez[contract] = rejectsTez
+}
+
+// reinjections is how many times an operation dropped by reorganizations is forged and injected again
+const reinjections = 3
+
 // Payout represents a payout and payout operations.
 type Payout struct {
 	config                            config.Config
 	rpc                               rpc.IFace
+	node                              tezosNode
 	tzkt                              tzkt.IFace
+	rewards                           rewards.Provider
 	key                               keys.Key
 	cycle                             int
 	inject                            bool
 	verbose                           bool
 	constructDexterContractPayoutFunc func(delegator tzkt.Delegator) (tzkt.Delegator, error)
-	applyFunc                         func(delegators tzkt.Delegators) ([]string, error)
+	applyFunc                         func(rewardsSplit tzkt.RewardsSplit) ([]string, error)
 	constructPayoutFunc               func() (tzkt.RewardsSplit, error)
+	levels                            map[string]int                // level each injected operation was included at
+	receipts                          map[string]failover.Operation // receipt each injected operation was included with
+	ledger                            *ledger.Ledger                // rewards carried over from earlier cycles, if enabled
+	expectations                      *ledger.Expectations          // payouts made from expected rewards, if paying on expectation
+	burn                              int                           // tez burnt to allocate an empty account, once fetched
+	balances                          map[string]int                // balances at the head, looked up in bulk for the payout
+	reveal                            *rpc.Content                  // reveal of the payout wallet's key the payout was applied with, if any
+	positions                         map[contentKey]position       // where every content the payout was applied with is in its operations
+	contracts                         *contractCache                // contracts known to accept or reject tez, if cached
+}
+
+// contentKey identifies a content of the operations a payout is applied with: the transfer to a delegator, or to a liquidity provider of a dexter contract, by index
+type contentKey struct {
+	delegator         int
+	liquidityProvider int
+}
+
+var (
+	// revealContent is the reveal of the payout wallet's key
+	revealContent = contentKey{-1, -2}
+	// skippedRewardsContent is the transfer of the skipped rewards sent to an address
+	skippedRewardsContent = contentKey{-1, -1}
+)
+
+// position is where a content is in the operations a payout is applied with: the index of its operation and its index in the operation
+type position struct {
+	operation int
+	content   int
+}
+
+// tezosNode reads the public key an account revealed and the receipts of included operations, which the tezos rpc client doesn't cover
+type tezosNode interface {
+	ManagerKey(blockhash, pkh string) (string, error)
+	ManagerOperations(level int) ([]failover.Operation, error)
 }
 
 // New returns a pointer to a new Baker
 func New(config config.Config, cycle int, inject, verbose bool) (*Payout, error) {
 	payout := &Payout{
-		config:  config,
-		tzkt:    tzkt.NewTZKT(config.API.TZKT),
-		cycle:   cycle,
-		inject:  inject,
-		verbose: verbose,
+		config:    config,
+		tzkt:      failover.NewTZKT(config.API),
+		cycle:     cycle,
+		inject:    inject,
+		verbose:   verbose,
+		levels:    map[string]int{},
+		receipts:  map[string]failover.Operation{},
+		contracts: contracts,
 	}
 	payout.constructDexterContractPayoutFunc = payout.constructDexterContractPayout
 	payout.constructPayoutFunc = payout.constructPayout
 	payout.applyFunc = payout.apply
 
 	var err error
-	payout.rpc, err = rpc.New(config.API.Tezos)
+	payout.rpc, payout.node, err = failover.NewNode(config.API)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initialize tezos rpc client")
 	}
+	payout.rewards = rewards.New(config.API, payout.rpc, payout.tzkt)
 
 	if inject {
 		payout.key, err = keys.NewKey(keys.NewKeyInput{
@@ -81,42 +159,268 @@ func (p *Payout) Execute() (tzkt.RewardsSplit, error) {
 		return payout, errors.Wrapf(err, "failed to execute payout for cycle %d", p.cycle)
 	}
 
+	var operations []string
 	if p.inject {
-		operations, err := p.applyFunc(payout.Delegators)
-		if err != nil {
-			return payout, errors.Wrapf(err, "failed to execute payout for cycle %d", p.cycle)
+		var applyErr error
+		operations, applyErr = p.applyFunc(payout)
+
+		// an operation that couldn't be confirmed may still be included
+		var pending []string
+		if pendingErr, ok := errors.Cause(applyErr).(*pendingError); ok {
+			pending = pendingErr.operations
+		}
+		if applyErr != nil && len(operations) == 0 && len(pending) == 0 {
+			return payout, errors.Wrapf(applyErr, "failed to execute payout for cycle %d", p.cycle)
 		}
 
 		for _, op := range operations {
 			payout.OperationLink = append(payout.OperationLink, fmt.Sprintf("https://tzkt.io/%s", op))
 		}
+
+		p.setOperationHashes(payout.Delegators, operations)
+		p.setReceipts(payout.Delegators)
+		if operation, ok := p.operationOf(revealContent, operations); ok && p.reveal != nil {
+			payout.Reveal = &tzkt.Reveal{PublicKey: p.reveal.PublicKey, Fee: int(p.reveal.Fee), OperationHash: operation}
+			logrus.WithFields(logrus.Fields{"cycle": p.cycle, "public_key": p.reveal.PublicKey, "operation": operation}).Info("Revealed the public key of the payout wallet.")
+		}
+		if operation, ok := p.operationOf(skippedRewardsContent, operations); ok && p.sendsSkippedRewards(payout) {
+			payout.SkippedRewards.OperationHash = operation
+		}
+
+		// the operations injected before a failure are on chain, what they paid mustn't be owed again
+		if applyErr != nil {
+			p.record(injected(payout))
+			return payout, &UnsettledError{Cycle: p.cycle, Operations: operations, Pending: pending, err: applyErr}
+		}
+
+		// the payout is on chain, failing it now would only get it retried
+		p.record(payout)
+	}
+
+	// a missing price shouldn't fail a payout that may already be on chain
+	if err := p.valueInFiat(&payout, operations); err != nil {
+		logrus.WithFields(logrus.Fields{"error": err.Error(), "cycle": p.cycle}).Warn("Failed to value payout in fiat.")
+	}
+
+	return payout, nil
+}
+
+/*
+UnsettledError is the failure of a payout that was or may have been paid in part: the operations injected before it
+failed are on chain, and Pending were injected but couldn't be confirmed, so may still be included. Executing the
+payout again could pay what they paid a second time, so it's left for the baker to settle.
+*/
+type UnsettledError struct {
+	Cycle      int
+	Operations []string
+	Pending    []string
+	err        error
+}
+
+func (u *UnsettledError) Error() string {
+	if len(u.Pending) > 0 {
+		return fmt.Sprintf("failed to execute payout for cycle %d after injecting %d operations, %s may still be included: %s", u.Cycle, len(u.Operations), strings.Join(u.Pending, ", "), u.err.Error())
+	}
+	return fmt.Sprintf("failed to execute payout for cycle %d after injecting %d operations: %s", u.Cycle, len(u.Operations), u.err.Error())
+}
+
+// IsUnsettled returns true if err is the failure of a payout that was or may have been paid in part, which mustn't be executed again
+func IsUnsettled(err error) bool {
+	_, ok := errors.Cause(err).(*UnsettledError)
+	return ok
+}
+
+// record saves what the payout paid and carried over to the ledger, and what it paid from expected rewards to the expectations
+func (p *Payout) record(payout tzkt.RewardsSplit) {
+	if p.ledger != nil {
+		p.ledger.Record(payout)
+		if err := p.ledger.Save(); err != nil {
+			logrus.WithFields(logrus.Fields{"error": err.Error(), "cycle": p.cycle}).Error("Failed to record carried over rewards.")
+		}
+	}
+
+	if p.expectations != nil {
+		p.expectations.Expect(payout)
+		if err := p.expectations.Save(); err != nil {
+			logrus.WithFields(logrus.Fields{"error": err.Error(), "cycle": p.cycle}).Error("Failed to record payout made from expected rewards.")
+		}
+	}
+}
+
+/*
+injected returns the payout without the delegators and liquidity providers that were to be paid by operations that
+were never injected. The ledger then neither settles what they're owed nor records them as paid.
+*/
+func injected(payout tzkt.RewardsSplit) tzkt.RewardsSplit {
+	var delegators tzkt.Delegators
+	for _, delegator := range payout.Delegators {
+		if delegator.LiquidityProviders == nil {
+			if delegator.Status.Skipped() || delegator.OperationHash != "" {
+				delegators = append(delegators, delegator)
+			}
+			continue
+		}
+
+		var liquidityProviders []tzkt.LiquidityProvider
+		for _, liquidityProvider := range delegator.LiquidityProviders {
+			if liquidityProvider.Status.Skipped() || liquidityProvider.OperationHash != "" {
+				liquidityProviders = append(liquidityProviders, liquidityProvider)
+			}
+		}
+
+		if len(liquidityProviders) > 0 {
+			delegator.LiquidityProviders = liquidityProviders
+			delegators = append(delegators, delegator)
+		}
+	}
+	payout.Delegators = delegators
+
+	return payout
+}
+
+/*
+setReceipts records the receipt of the transfer of every paid delegator and liquidity provider. The ones whose
+transfer wasn't applied, or triggered an internal operation that wasn't, are marked failed as their rewards are
+still owed.
+*/
+func (p *Payout) setReceipts(delegators tzkt.Delegators) {
+	for i := range delegators {
+		delegator := &delegators[i]
+		for k := range delegator.LiquidityProviders {
+			liquidityProvider := &delegator.LiquidityProviders[k]
+			if receipt, ok := p.receipt(liquidityProvider.OperationHash, contentKey{i, k}); ok {
+				liquidityProvider.Receipt = &receipt
+				if !receipt.Applied() {
+					liquidityProvider.Status = tzkt.Failed
+				}
+			}
+		}
+
+		if receipt, ok := p.receipt(delegator.OperationHash, contentKey{i, -1}); ok {
+			delegator.Receipt = &receipt
+			if !receipt.Applied() {
+				delegator.Status = tzkt.Failed
+			}
+		}
+	}
+}
+
+/*
+receipt returns the receipt of a transfer in the injected operation, if it was confirmed. The transfer is found by
+its position in the operation, as a payout may send more than one transfer to the same destination.
+*/
+func (p *Payout) receipt(operation string, transfer contentKey) (tzkt.Receipt, bool) {
+	position, ok := p.positions[transfer]
+	contents := p.receipts[operation].Contents
+	if !ok || position.content >= len(contents) || contents[position.content].Kind != string(rpc.TRANSACTION) {
+		return tzkt.Receipt{}, false
+	}
+
+	result := contents[position.content].Metadata.OperationResult
+	receipt := tzkt.Receipt{
+		Status:              result.Status,
+		ConsumedGas:         int(result.Gas()),
+		PaidStorageSizeDiff: int(result.PaidStorageSizeDiff),
+	}
+
+	for _, internal := range contents[position.content].Metadata.InternalOperationResults {
+		receipt.ConsumedGas += int(internal.Result.Gas())
+		receipt.PaidStorageSizeDiff += int(internal.Result.PaidStorageSizeDiff)
+		if receipt.Applied() && internal.Result.Status != failover.Applied {
+			receipt.Status = internal.Result.Status
+		}
 	}
 
-	return payout, err
+	return receipt, true
+}
+
+// setOperationHashes records the hash of the operation every paid delegator and liquidity provider was included in
+func (p *Payout) setOperationHashes(delegators tzkt.Delegators, operations []string) {
+	for i := range delegators {
+		delegator := &delegators[i]
+		for k := range delegator.LiquidityProviders {
+			if operation, ok := p.operationOf(contentKey{i, k}, operations); ok {
+				delegator.LiquidityProviders[k].OperationHash = operation
+			}
+		}
+
+		if operation, ok := p.operationOf(contentKey{i, -1}, operations); ok {
+			delegator.OperationHash = operation
+		}
+	}
+}
+
+// operationOf returns the hash of the operation content was injected with, if it was
+func (p *Payout) operationOf(content contentKey, operations []string) (string, bool) {
+	position, ok := p.positions[content]
+	if !ok || position.operation >= len(operations) {
+		return "", false
+	}
+
+	return operations[position.operation], true
 }
 
 func (p *Payout) constructPayout() (tzkt.RewardsSplit, error) {
-	rewardsSplit, err := p.tzkt.GetRewardsSplit(p.config.Baker.Address, p.cycle)
+	rewardsSplit, err := p.rewards.RewardsSplit(p.config.Baker.Address, p.cycle)
 	if err != nil {
 		return rewardsSplit, errors.Wrap(err, "failed to contruct payout")
 	}
 
-	totalRewards := p.calculateTotals(rewardsSplit)
+	// a split derived from the node has nothing to be checked against, nor has a split under the staking
+	// model, whose balances are only the delegated part of those the node knows of
+	if p.config.API.CrossValidate && p.config.API.RewardsProvider != config.NodeRewardsProvider && rewardsSplit.Model != rewards.StakingModel {
+		err = rewards.NewNode(p.rpc).Validate(rewardsSplit, p.config.Baker.Address, p.cycle, p.config.API.CrossValidateTolerance)
+		if err != nil {
+			return rewardsSplit, errors.Wrap(err, "failed to contruct payout")
+		}
+	}
+
+	if p.config.Baker.CarryOver {
+		if p.ledger, err = ledger.Load(p.config.Baker.CarryOverLedger); err != nil {
+			return rewardsSplit, errors.Wrap(err, "failed to contruct payout")
+		}
+	}
 
-	bakerBalance, err := p.rpc.Balance(rpc.BalanceInput{
-		Cycle:   p.cycle,
-		Address: p.config.Baker.Address,
-	})
+	if p.config.Baker.PayoutMode == config.ExpectedPayouts {
+		if p.expectations, err = ledger.LoadExpectations(p.config.Baker.TrueUpLedger); err != nil {
+			return rewardsSplit, errors.Wrap(err, "failed to contruct payout")
+		}
+	}
+
+	accounting := p.config.Baker.Accounting().Apply(rewardsSplit)
+	rewardsSplit.Accounting = &accounting
+	totalRewards := accounting.Total
+
+	bakerBalance, err := p.bakerBalance(rewardsSplit)
 	if err != nil {
 		return rewardsSplit, errors.Wrap(err, "failed to contruct payout")
 	}
 
 	rewardsSplit.BakerShare = float64(bakerBalance) / float64(rewardsSplit.StakingBalance)
-	rewardsSplit.BakerRewards = int(rewardsSplit.BakerShare * float64(totalRewards))
+	rewardsSplit.BakerRewards = mulDiv(bakerBalance, totalRewards, rewardsSplit.StakingBalance)
+
+	// whatever the rounded down shares of the baker and delegators leave undistributed
+	covered, distributed := bakerBalance, rewardsSplit.BakerRewards
+	for _, delegator := range rewardsSplit.Delegators {
+		covered += delegator.Balance
+		distributed += mulDiv(delegator.Balance, totalRewards, rewardsSplit.StakingBalance)
+	}
+	rewardsSplit.Dust = mulDiv(covered, totalRewards, rewardsSplit.StakingBalance) - distributed
 
 	delegations, dexterContracts := p.splitDelegationsAndDexterContracts(rewardsSplit)
 	rewardsSplit.Delegators = tzkt.Delegators{}
 
+	// balances are only needed to find empty accounts
+	if !p.config.Baker.DexterLiquidityContractsOnly && p.config.Baker.BurnFeePolicy() != config.PayBurnFees {
+		var addresses []string
+		for _, delegation := range delegations {
+			addresses = append(addresses, delegation.Address)
+		}
+		if err := p.lookupBalances(addresses); err != nil {
+			return rewardsSplit, errors.Wrap(err, "failed to contruct payout")
+		}
+	}
+
 	if !p.config.Baker.DexterLiquidityContractsOnly {
 		for _, delegation := range delegations {
 			delegation, err = p.constructDelegation(delegation, totalRewards, rewardsSplit.StakingBalance)
@@ -140,12 +444,131 @@ func (p *Payout) constructPayout() (tzkt.RewardsSplit, error) {
 			return tzkt.RewardsSplit{}, errors.Wrap(err, "failed to contrcut payout for dexter contract")
 		}
 
+		rewardsSplit.Dust += contract.Dust
 		rewardsSplit.Delegators = append(rewardsSplit.Delegators, contract)
 	}
 
+	p.applySkippedRewardsPolicy(&rewardsSplit)
+	if p.config.Operations.DeductNetworkFee {
+		p.deductNetworkFees(rewardsSplit.Delegators)
+	}
+
 	return rewardsSplit, nil
 }
 
+/*
+bakerBalance returns the baker's own balance sharing the rewards with its delegators, its balance at the cycle's roll
+snapshot. Under the staking model it's only its delegated balance, its staked funds being rewarded on chain.
+*/
+func (p *Payout) bakerBalance(rewardsSplit tzkt.RewardsSplit) (int, error) {
+	if rewardsSplit.Model == rewards.StakingModel {
+		return rewardsSplit.OwnDelegatedBalance, nil
+	}
+
+	return p.rpc.Balance(rpc.BalanceInput{
+		Cycle:   p.cycle,
+		Address: p.config.Baker.Address,
+	})
+}
+
+/*
+deductNetworkFees sets the network fee deducted from every paid delegator and liquidity provider, which is
+the fee of its transfer and its share of the overhead of the batch it's in. The overhead is shared evenly by
+the batch's transfers and what dividing it leaves is deducted from the first.
+*/
+func (p *Payout) deductNetworkFees(delegators tzkt.Delegators) {
+	var offset int
+	for _, batch := range p.batch(delegators) {
+		var fees []*int
+		for i := range batch {
+			delegator := &delegators[offset+i]
+			if delegator.LiquidityProviders == nil && !delegator.Status.Skipped() {
+				fees = append(fees, &delegator.NetworkFee)
+			}
+
+			for j := range delegator.LiquidityProviders {
+				if !delegator.LiquidityProviders[j].Status.Skipped() {
+					fees = append(fees, &delegator.LiquidityProviders[j].NetworkFee)
+				}
+			}
+		}
+		offset += len(batch)
+
+		for i, fee := range fees {
+			*fee = p.config.Operations.NetworkFee + p.config.Operations.BatchOverhead/len(fees)
+			if i == 0 {
+				*fee += p.config.Operations.BatchOverhead % len(fees)
+			}
+		}
+	}
+}
+
+/*
+applySkippedRewardsPolicy applies the baker's policy to the net rewards of the skipped delegators and liquidity
+providers. Redistributed rewards are shared pro rata to the net rewards of the paid delegators and liquidity
+providers, rounded down, and what rounding leaves is added to the dust. If nobody is paid the baker keeps them.
+*/
+func (p *Payout) applySkippedRewardsPolicy(rewardsSplit *tzkt.RewardsSplit) {
+	var skipped, paid int
+	lines(rewardsSplit.Delegators, func(netRewards int, status tzkt.Status, redistributed *int) {
+		if status.Skipped() && status != tzkt.CarriedOver {
+			skipped += netRewards
+		} else if !status.Skipped() {
+			paid += netRewards
+		}
+	})
+
+	if skipped == 0 {
+		return
+	}
+
+	rewardsSplit.SkippedRewards = &tzkt.Skipped{
+		Policy: config.KeepSkippedRewards,
+		Amount: skipped,
+	}
+
+	switch p.config.Baker.SkippedRewards {
+	case config.RedistributeSkippedRewards:
+		if paid == 0 {
+			return
+		}
+
+		rewardsSplit.SkippedRewards.Policy = config.RedistributeSkippedRewards
+		distributed := 0
+		lines(rewardsSplit.Delegators, func(netRewards int, status tzkt.Status, redistributed *int) {
+			if !status.Skipped() {
+				*redistributed = mulDiv(netRewards, skipped, paid)
+				distributed += *redistributed
+			}
+		})
+		rewardsSplit.Dust += skipped - distributed
+	case config.SendSkippedRewards:
+		rewardsSplit.SkippedRewards.Policy = config.SendSkippedRewards
+		rewardsSplit.SkippedRewards.Address = p.config.Baker.SkippedRewardsAddress
+	}
+}
+
+// sendsSkippedRewards returns true if the payout includes a transfer of the skipped rewards to an address
+func (p *Payout) sendsSkippedRewards(rewardsSplit tzkt.RewardsSplit) bool {
+	return rewardsSplit.SkippedRewards != nil && rewardsSplit.SkippedRewards.Policy == config.SendSkippedRewards
+}
+
+// lines calls fn for every delegator and every liquidity provider of a dexter contract, which are paid directly
+func lines(delegators tzkt.Delegators, fn func(netRewards int, status tzkt.Status, redistributed *int)) {
+	for i := range delegators {
+		delegator := &delegators[i]
+		if delegator.LiquidityProviders == nil {
+			fn(delegator.NetRewards, delegator.Status, &delegator.Redistributed)
+			continue
+		}
+
+		for j := range delegator.LiquidityProviders {
+			liquidityProvider := &delegator.LiquidityProviders[j]
+			fn(liquidityProvider.NetRewards, liquidityProvider.Status, &liquidityProvider.Redistributed)
+		}
+	}
+}
+
 func (p *Payout) splitDelegationsAndDexterContracts(rewardsSplit tzkt.RewardsSplit) (tzkt.Delegators, tzkt.Delegators) {
 	var delegations tzkt.Delegators
 	var dexterContracts tzkt.Delegators
@@ -162,136 +585,240 @@ func (p *Payout) splitDelegationsAndDexterContracts(rewardsSplit tzkt.RewardsSpl
 
 func (p *Payout) constructDelegation(delegator tzkt.Delegator, totalRewards, stakingBalance int) (tzkt.Delegator, error) {
 	delegator.Share = float64(delegator.Balance) / float64(stakingBalance)
-	if p.config.Baker.EarningsOnly {
-		delegator.GrossRewards = int(delegator.Share * float64(totalRewards))
-	} else {
-		delegator.GrossRewards = int(delegator.Share * float64(totalRewards))
+	delegator.GrossRewards = mulDiv(delegator.Balance, totalRewards, stakingBalance)
+	delegator.Fee = fee(delegator.GrossRewards, p.config.Baker.Fee)
+	delegator.NetRewards = delegator.GrossRewards - delegator.Fee
+	delegator.CarriedOver = p.carriedOver(delegator.Address)
+
+	status, burnFee, err := p.status(delegator.Address, delegator.Amount())
+	if err != nil {
+		return delegator, errors.Wrap(err, "failed to contruct delegation")
 	}
-	delegator.Fee = int(float64(delegator.GrossRewards) * p.config.Baker.Fee)
-	delegator.NetRewards = int(delegator.GrossRewards - delegator.Fee)
+	delegator.Status = status
+	delegator.BurnFee = burnFee
+
+	return delegator, nil
+}
 
-	if p.isInBlacklist(delegator.Address) {
-		delegator.BlackListed = true
+/*
+status returns whether address is paid amount or why it's skipped, and the burn fee deducted from amount
+if it's paid. An address in the blacklist is skipped first, then an empty account if the baker skips them
+or its rewards don't cover the burn deducted from them, a contract that can't receive tez and an amount
+(less the burn) below the minimum payment, which is carried over to the next cycle if the baker carries over.
+If network fees are deducted, what's left after the transfer's fee and, at worst, the whole batch overhead
+must be paid as well.
+*/
+func (p *Payout) status(address string, amount int) (tzkt.Status, int, error) {
+	if p.isInBlacklist(address) {
+		return tzkt.Blacklisted, 0, nil
 	}
 
-	if !p.config.Baker.BakerPaysBurnFees {
-		requiresBurnFee, err := p.requiresBurnFee(delegator.Address)
+	var burnFee int
+	if policy := p.config.Baker.BurnFeePolicy(); policy != config.PayBurnFees {
+		requiresBurnFee, err := p.requiresBurnFee(address)
 		if err != nil {
-			return delegator, errors.Wrap(err, "failed to contruct delegation")
+			return tzkt.Paid, 0, err
 		}
 		if requiresBurnFee {
-			delegator.BlackListed = true
+			if policy == config.SkipBurnFees {
+				return tzkt.NeedsBurn, 0, nil
+			}
+
+			if burnFee, err = p.allocationBurn(); err != nil {
+				return tzkt.Paid, 0, err
+			}
+			if amount <= burnFee {
+				return tzkt.NeedsBurn, 0, nil
+			}
 		}
 	}
 
-	if delegator.NetRewards < p.config.Baker.MinimumPayment {
-		delegator.BlackListed = true
+	if strings.HasPrefix(address, "KT1") && !p.isDexterContract(address) {
+		rejectsTez, err := p.rejectsTez(address)
+		if err != nil {
+			return tzkt.Paid, 0, err
+		}
+		if rejectsTez {
+			return tzkt.RejectsTez, 0, nil
+		}
 	}
 
-	return delegator, nil
+	remainder, minimum := amount-burnFee, p.config.Baker.MinimumPayment
+	if p.config.Operations.DeductNetworkFee {
+		remainder -= p.config.Operations.NetworkFee + p.config.Operations.BatchOverhead
+		if minimum < 1 {
+			minimum = 1
+		}
+	}
+
+	if remainder < minimum {
+		if p.config.Baker.CarryOver {
+			return tzkt.CarriedOver, 0, nil
+		}
+		return tzkt.BelowMinimum, 0, nil
+	}
+
+	return tzkt.Paid, burnFee, nil
 }
 
-func (p *Payout) calculateTotals(rewards tzkt.RewardsSplit) int {
-	if p.config.Baker.EarningsOnly {
-		return rewards.EndorsementRewards +
-			rewards.RevelationRewards +
-			rewards.OwnBlockFees +
-			rewards.OwnBlockRewards +
-			rewards.ExtraBlockFees +
-			rewards.ExtraBlockRewards
+// carriedOver returns the rewards carried over for address from the cycles before the payout's cycle
+func (p *Payout) carriedOver(address string) int {
+	if p.ledger == nil {
+		return 0
 	}
 
-	return rewards.EndorsementRewards +
-		rewards.MissedEndorsementRewards +
-		rewards.RevelationRewards +
-		rewards.OwnBlockFees +
-		rewards.MissedOwnBlockFees +
-		rewards.OwnBlockRewards +
-		rewards.MissedOwnBlockRewards +
-		rewards.ExtraBlockFees +
-		rewards.ExtraBlockRewards
+	return p.ledger.Pending(address, p.cycle)
 }
 
-func (p *Payout) apply(delegators tzkt.Delegators) ([]string, error) {
+func (p *Payout) apply(rewardsSplit tzkt.RewardsSplit) ([]string, error) {
 	head, err := p.rpc.Head()
 	if err != nil {
 		return []string{}, errors.Wrap(err, "failed to apply payout")
 	}
 
-	var operationStrings []string
-	transactionBatches, err := p.constructTransactionBatches(head.Hash, delegators)
+	transactionBatches, err := p.constructTransactionBatches(head.Hash, rewardsSplit)
 	if err != nil {
 		return []string{}, errors.Wrap(err, "failed to contruct batch transactions")
 	}
+
+	// forging them all first keeps a batch that can't be forged from leaving the payout half injected
 	for _, transactions := range transactionBatches {
-		if operation, err := forge.Encode(head.Hash, transactions...); err == nil {
-			operationStrings = append(operationStrings, operation)
-		} else {
+		if _, err := forge.Encode(head.Hash, transactions...); err != nil {
 			return []string{}, errors.Wrap(err, "failed to forge operation")
 		}
 	}
 
-	operationHashes, err := p.injectOperations(operationStrings)
+	// the operations injected before a failure are returned with it, as they're on chain
+	operationHashes, err := p.injectOperations(transactionBatches)
 	if err != nil {
-		return []string{}, errors.Wrap(err, "failed to forge operation")
+		return operationHashes, errors.Wrap(err, "failed to apply payout")
 	}
 
 	return operationHashes, nil
 }
 
-func (p *Payout) constructTransactionBatches(blockhash string, delegators tzkt.Delegators) ([]rpc.Contents, error) {
-	var transactionBatches []rpc.Contents
-
+/*
+constructTransactionBatches builds the transfers of the payout into batches, leaving out the batches without any, and
+records where every transfer is. If the payout wallet never revealed its public key, a reveal is prepended to the first
+batch, which the batch's transfers can't be applied without. Counters are assigned last, in the order the contents are
+injected.
+*/
+func (p *Payout) constructTransactionBatches(blockhash string, rewardsSplit tzkt.RewardsSplit) ([]rpc.Contents, error) {
 	counter, err := p.rpc.Counter(blockhash, p.key.PubKey.GetPublicKeyHash())
 	if err != nil {
 		return nil, err
 	}
 
-	var storageLimit int64
-	if p.config.Baker.BakerPaysBurnFees {
-		storageLimit = 257
+	p.reveal = nil
+	p.positions = map[contentKey]position{}
+	managerKey, err := p.node.ManagerKey(blockhash, p.key.PubKey.GetPublicKeyHash())
+	if err != nil {
+		return nil, err
+	}
+
+	transaction := func(destination string, amount, burnFee int) rpc.Content {
+		return rpc.Content{
+			Kind:         rpc.TRANSACTION,
+			Source:       p.key.PubKey.GetPublicKeyHash(),
+			Destination:  destination,
+			Amount:       int64(amount),
+			Fee:          int64(p.config.Operations.NetworkFee),
+			GasLimit:     int64(p.config.Operations.GasLimit),
+			StorageLimit: p.storageLimit(burnFee),
+		}
 	}
 
-	for _, batch := range p.batch(delegators) {
+	var transactionBatches []rpc.Contents
+	var contentBatches [][]contentKey
+	var offset int
+	for _, batch := range p.batch(rewardsSplit.Delegators) {
 		var transactions rpc.Contents
-		for _, delegation := range batch {
+		var contents []contentKey
+		for i, delegation := range batch {
 			if delegation.LiquidityProviders != nil {
-				for _, liquidityProvider := range delegation.LiquidityProviders {
-					if !liquidityProvider.BlackListed { // don't payout to rewards smaller than minimal payment or that are blacklisted
-						counter++
-						transactions = append(transactions, rpc.Content{
-							Kind:         rpc.TRANSACTION,
-							Source:       p.key.PubKey.GetPublicKeyHash(),
-							Destination:  liquidityProvider.Address,
-							Amount:       int64(liquidityProvider.NetRewards),
-							Fee:          int64(p.config.Operations.NetworkFee),
-							GasLimit:     int64(p.config.Operations.GasLimit),
-							Counter:      counter,
-							StorageLimit: storageLimit,
-						})
+				for k, liquidityProvider := range delegation.LiquidityProviders {
+					if !liquidityProvider.Status.Skipped() { // don't payout to skipped liquidity providers
+						transactions = append(transactions, transaction(liquidityProvider.Address, liquidityProvider.Amount(), liquidityProvider.BurnFee))
+						contents = append(contents, contentKey{offset + i, k})
 					}
 				}
 			} else {
-				if !delegation.BlackListed { // don't payout to rewards smaller than minimal payment or that are blacklisted
-					counter++
-					transactions = append(transactions, rpc.Content{
-						Kind:         rpc.TRANSACTION,
-						Source:       p.key.PubKey.GetPublicKeyHash(),
-						Destination:  delegation.Address,
-						Amount:       int64(delegation.NetRewards),
-						Fee:          int64(p.config.Operations.NetworkFee),
-						GasLimit:     int64(p.config.Operations.GasLimit),
-						Counter:      counter,
-						StorageLimit: storageLimit,
-					})
+				if !delegation.Status.Skipped() { // don't payout to skipped delegators or dexter contracts
+					transactions = append(transactions, transaction(delegation.Address, delegation.Amount(), delegation.BurnFee))
+					contents = append(contents, contentKey{offset + i, -1})
 				}
 			}
 		}
+		offset += len(batch)
 
 		transactionBatches = append(transactionBatches, transactions)
+		contentBatches = append(contentBatches, contents)
+	}
+
+	// the skipped rewards sent to an address go out with the last batch
+	if p.sendsSkippedRewards(rewardsSplit) {
+		if len(transactionBatches) == 0 {
+			transactionBatches = append(transactionBatches, rpc.Contents{})
+			contentBatches = append(contentBatches, nil)
+		}
+		last := len(transactionBatches) - 1
+		transactionBatches[last] = append(transactionBatches[last], transaction(rewardsSplit.SkippedRewards.Address, rewardsSplit.SkippedRewards.Amount, 0))
+		contentBatches[last] = append(contentBatches[last], skippedRewardsContent)
+	}
+
+	// batches whose delegators are all skipped aren't injected, and the first transfer of every other one pays its overhead
+	var batches []rpc.Contents
+	for i, transactions := range transactionBatches {
+		if len(transactions) == 0 {
+			continue
+		}
+
+		transactions[0].Fee += int64(p.config.Operations.BatchOverhead)
+		for k, content := range contentBatches[i] {
+			p.positions[content] = position{operation: len(batches), content: k}
+		}
+		batches = append(batches, transactions)
+	}
+
+	if managerKey == "" && len(batches) > 0 {
+		p.reveal = &rpc.Content{
+			Kind:      rpc.REVEAL,
+			Source:    p.key.PubKey.GetPublicKeyHash(),
+			Fee:       int64(p.config.Operations.RevealFee),
+			GasLimit:  int64(p.config.Operations.RevealGasLimit),
+			PublicKey: p.key.PubKey.GetPublicKey(),
+		}
+
+		batches[0] = append(rpc.Contents{*p.reveal}, batches[0]...)
+		for content, position := range p.positions {
+			if position.operation == 0 {
+				position.content++
+				p.positions[content] = position
+			}
+		}
+		p.positions[revealContent] = position{}
 	}
 
-	return transactionBatches, nil
+	for _, contents := range batches {
+		for i := range contents {
+			counter++
+			contents[i].Counter = counter
+		}
+	}
+	if p.reveal != nil {
+		p.reveal.Counter = batches[0][0].Counter
+	}
+
+	return batches, nil
+}
+
+// storageLimit returns the storage limit of a transfer, which covers allocating its destination if the baker pays or the burn is deducted
+func (p *Payout) storageLimit(burnFee int) int64 {
+	if p.config.Baker.BurnFeePolicy() == config.PayBurnFees || burnFee > 0 {
+		return 257
+	}
+
+	return 0
 }
 
 func (p *Payout) batch(delegators tzkt.Delegators) []tzkt.Delegators {
@@ -312,67 +839,283 @@ func (p *Payout) batch(delegators tzkt.Delegators) []tzkt.Delegators {
 	return batch
 }
 
-func (p *Payout) injectOperations(operations []string) ([]string, error) {
+/*
+injectOperations forges, signs, injects and confirms the batches one after the other. The receipt each operation
+was included with is kept, and operations that weren't applied are logged but don't stop the ones after them, as
+their counters were already used.
+*/
+func (p *Payout) injectOperations(batches []rpc.Contents) ([]string, error) {
 	ophashes := []string{}
-	for i, op := range operations {
-		signedop, err := p.key.Sign(keys.SignInput{
-			Message: op,
-		})
+	for i, contents := range batches {
+		ophash, operation, level, err := p.injectOperation(contents, fmt.Sprintf("%d/%d", (i+1), len(batches)))
 		if err != nil {
-			return ophashes, errors.Wrap(err, "failed to inject operation")
+			return ophashes, err
 		}
+		ophashes = append(ophashes, ophash)
 
-		ophash, err := p.rpc.InjectionOperation(rpc.InjectionOperationInput{
-			Operation: fmt.Sprintf("%s%s", op, hex.EncodeToString(signedop.Bytes)),
-		})
+		if p.levels == nil {
+			p.levels = map[string]int{}
+		}
+		p.levels[ophash] = level
+
+		if p.receipts == nil {
+			p.receipts = map[string]failover.Operation{}
+		}
+		p.receipts[ophash] = operation
+
+		for _, content := range operation.Contents {
+			if status := content.Metadata.OperationResult.Status; status != failover.Applied {
+				logrus.WithFields(logrus.Fields{
+					"hash":   ophash,
+					"block":  level,
+					"status": status,
+				}).Warn("Operation was included but not applied.")
+				break
+			}
+		}
+	}
+
+	return ophashes, nil
+}
+
+/*
+injectOperation forges contents on the head, injects them and waits for the operation to be confirmed by
+TZPAY_OPERATIONS_CONFIRMATIONS blocks. If a reorganization drops the operation before then, contents are forged on
+the new head and injected again, unless the dropped operation was included again since or its counters were used.
+Both operations use the same counters, so only one of them can ever be applied: an operation dropped by a
+reorganization may come back from the mempool and make injecting it again fail, so then the operations injected
+before are waited for. Once an operation is injected, failing to confirm it returns a pendingError, as it may still
+be included.
+*/
+func (p *Payout) injectOperation(contents rpc.Contents, progress string) (string, failover.Operation, int, error) {
+	var ophashes []string
+	var from int
+	fail := func(err error) (string, failover.Operation, int, error) {
+		if len(ophashes) > 0 {
+			return "", failover.Operation{}, 0, &pendingError{operations: ophashes, err: err}
+		}
+		return "", failover.Operation{}, 0, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		head, err := p.rpc.Head()
 		if err != nil {
-			return ophashes, errors.Wrap(err, "failed to inject operation")
+			return fail(errors.Wrap(err, "failed to inject operation"))
+		}
+		if attempt == 0 {
+			from = head.Header.Level
+		}
+
+		inject := true
+		if attempt > 0 {
+			if inject, err = p.replaceable(ophashes, contents, from, head); err != nil {
+				return fail(errors.Wrap(err, "failed to inject operation"))
+			}
+		}
+
+		var injectErr error
+		if inject {
+			op, err := forge.Encode(head.Hash, contents...)
+			if err != nil {
+				return fail(errors.Wrap(err, "failed to forge operation"))
+			}
+
+			signedop, err := p.key.Sign(keys.SignInput{
+				Message: op,
+			})
+			if err != nil {
+				return fail(errors.Wrap(err, "failed to inject operation"))
+			}
+
+			var ophash string
+			ophash, injectErr = p.rpc.InjectionOperation(rpc.InjectionOperationInput{
+				Operation: fmt.Sprintf("%s%s", op, hex.EncodeToString(signedop.Bytes)),
+			})
+			if injectErr != nil {
+				if len(ophashes) == 0 {
+					return fail(errors.Wrap(injectErr, "failed to inject operation"))
+				}
+
+				logrus.WithFields(logrus.Fields{
+					"error":     injectErr.Error(),
+					"hash":      ophashes[len(ophashes)-1],
+					"operation": progress,
+				}).Warn("Failed to inject operation again, waiting for the operation dropped by the reorganization.")
+			} else {
+				ophashes = append(ophashes, ophash)
+				if p.verbose {
+					logrus.WithFields(logrus.Fields{
+						"hash":      ophash,
+						"operation": progress,
+					}).Info("Confirming injection.")
+				}
+			}
+		}
+
+		operation, level, ok := p.confirmOperation(ophashes, from)
+		if !ok {
+			if injectErr != nil {
+				return fail(errors.Wrapf(injectErr, "failed to inject operation: none of %s was included", strings.Join(ophashes, ", ")))
+			}
+			return fail(errors.Errorf("failed to inject operation: failed to confirm operation %s", strings.Join(ophashes, ", ")))
 		}
-		ophashes = append(ophashes, ophash)
 
-		if p.verbose {
-			logrus.WithFields(logrus.Fields{
-				"hash":      ophash,
-				"operation": fmt.Sprintf("%d/%d", (i + 1), len(operations)),
-			}).Info("Confirming injection.")
+		if p.config.Operations.Confirmations > 0 {
+			operation, level, ok, err = p.settleOperation(ophashes, level)
+			if err != nil {
+				return fail(errors.Wrap(err, "failed to inject operation"))
+			}
 		}
 
-		if !p.confirmOperation(ophash) {
-			return ophashes, errors.Wrap(err, "failed to inject operation: failed to confirm operation")
+		if ok {
+			if p.verbose {
+				logrus.WithFields(logrus.Fields{
+					"hash":      operation.Hash,
+					"block":     level,
+					"operation": progress,
+				}).Info("Injection confirmed.")
+			}
+
+			return operation.Hash, operation, level, nil
 		}
 
-		if p.verbose {
-			logrus.WithFields(logrus.Fields{
-				"hash":      ophash,
-				"operation": fmt.Sprintf("%d/%d", (i + 1), len(operations)),
-			}).Info("Injection confirmed.")
+		if attempt == reinjections {
+			return fail(errors.Errorf("failed to inject operation: operation %s was dropped by %d reorganizations", ophashes[len(ophashes)-1], attempt+1))
 		}
+
+		// the operation may be included again from the block it was dropped from on
+		from = level
+
+		logrus.WithFields(logrus.Fields{
+			"hash":      ophashes[len(ophashes)-1],
+			"block":     level,
+			"operation": progress,
+		}).Warn("Operation was dropped by a reorganization, injecting it again.")
 	}
+}
 
-	return ophashes, nil
+/*
+replaceable returns true if contents whose operations were dropped by a reorganization can be injected again: none
+of operations was included from level on and the counters of contents weren't used. If one of them was included, it's
+waited for instead. Counters used by an operation that isn't any of them mean the outcome can't be told, so it fails.
+*/
+func (p *Payout) replaceable(operations []string, contents rpc.Contents, level int, head *rpc.Block) (bool, error) {
+	if _, _, ok := p.findOperation(operations, level, head.Header.Level); ok {
+		return false, nil
+	}
+
+	counter, err := p.rpc.Counter(head.Hash, contents[0].Source)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to check the counter of the dropped operation")
+	}
+
+	if counter >= contents[0].Counter {
+		return false, errors.Errorf("counter %d of the dropped operation was used by an operation that isn't any of %s", contents[0].Counter, strings.Join(operations, ", "))
+	}
+
+	return true, nil
+}
+
+// pendingError is the failure to confirm operations that were injected, any of which may still be included
+type pendingError struct {
+	operations []string
+	err        error
+}
+
+func (p *pendingError) Error() string {
+	return p.err.Error()
 }
 
-func (p *Payout) confirmOperation(operation string) bool {
-	timer := time.After(confirmationTimoutInterval)
-	ticker := time.Tick(confirmationDurationInterval)
+/*
+confirmOperation waits for one of operations to be included in a block from level on and returns it with its receipts
+and the level it was included at. Every block up to the head is looked into, so an operation isn't missed when more
+than one block is baked between two polls.
+*/
+func (p *Payout) confirmOperation(operations []string, level int) (failover.Operation, int, bool) {
+	timer := time.NewTimer(confirmationTimoutInterval)
+	defer timer.Stop()
+	ticker := time.NewTicker(confirmationDurationInterval)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-ticker:
-			if head, err := p.rpc.Head(); err == nil {
-				if ophashes, err := p.rpc.OperationHashes(head.Hash); err == nil {
-					for _, out := range ophashes {
-						for _, in := range out {
-							if in == operation {
-								return true
-							}
-						}
-					}
+		case <-ticker.C:
+			head, err := p.rpc.Head()
+			if err != nil {
+				continue
+			}
+
+			op, next, ok := p.findOperation(operations, level, head.Header.Level)
+			if ok {
+				return op, next, true
+			}
+			level = next
+		case <-timer.C:
+			return failover.Operation{}, 0, false
+		}
+	}
+}
+
+/*
+settleOperation waits for TZPAY_OPERATIONS_CONFIRMATIONS blocks to be baked on top of the block one of operations was
+included in at level, then returns it with its receipts and the level it's included at. If a reorganization took it
+out of that block, it's looked for in the later blocks of the new main chain and, if it isn't in any, false is returned.
+It fails if no block is baked for the confirmation timeout.
+*/
+func (p *Payout) settleOperation(operations []string, level int) (failover.Operation, int, bool, error) {
+	ticker := time.NewTicker(confirmationDurationInterval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(confirmationTimoutInterval)
+	var last int
+	for {
+		<-ticker.C
+		head, err := p.rpc.Head()
+		if err == nil && head.Header.Level != last {
+			last = head.Header.Level
+			deadline = time.Now().Add(confirmationTimoutInterval)
+		}
+
+		if time.Now().After(deadline) {
+			return failover.Operation{}, level, false, errors.Errorf("failed to settle operation %s: no block was baked for %s", strings.Join(operations, ", "), confirmationTimoutInterval)
+		}
+
+		if err != nil || last < level+p.config.Operations.Confirmations {
+			continue
+		}
+
+		op, next, ok := p.findOperation(operations, level, last)
+		switch {
+		case ok && next == level:
+			return op, level, true, nil
+		case ok: // included again in a later block, which must be confirmed in turn
+			level = next
+		case next > last:
+			return failover.Operation{}, level, false, nil
+		}
+	}
+}
+
+/*
+findOperation looks for one of operations in the blocks from level to the head at head, returning it with the level
+it's included at. If none is found, the level returned is the one to look from next, which isn't past a block that
+couldn't be read.
+*/
+func (p *Payout) findOperation(operations []string, level, head int) (failover.Operation, int, bool) {
+	for ; level <= head; level++ {
+		included, err := p.node.ManagerOperations(level)
+		if err != nil {
+			return failover.Operation{}, level, false
+		}
+
+		for _, op := range included {
+			for _, operation := range operations {
+				if op.Hash == operation {
+					return op, level, true
 				}
 			}
-		case <-timer:
-			return false
 		}
 	}
+
+	return failover.Operation{}, level, false
 }
 
 func (p *Payout) isInBlacklist(delegation string) bool {
@@ -385,21 +1128,86 @@ func (p *Payout) isInBlacklist(delegation string) bool {
 	return false
 }
 
-// checks if the account needs a burn fee - accounts that do will be skipped
+// checks if the contract has no default entrypoint taking unit - plain transfers to it would fail
+func (p *Payout) rejectsTez(contract string) (bool, error) {
+	if rejectsTez, ok := p.contracts.lookup(contract); ok {
+		return rejectsTez, nil
+	}
+
+	entrypoints, err := p.tzkt.GetEntrypoints(contract)
+	if err != nil {
+		return true, errors.Wrapf(err, "failed to check if contract '%s' accepts tez", contract)
+	}
+
+	rejectsTez := true
+	for _, entrypoint := range entrypoints {
+		if entrypoint.Name == "default" && entrypoint.MichelsonParameters == "unit" {
+			rejectsTez = false
+			break
+		}
+	}
+	p.contracts.store(contract, rejectsTez)
+
+	return rejectsTez, nil
+}
+
+// checks if the account needs a burn fee - accounts that do are skipped unless the burn is paid or deducted
 func (p *Payout) requiresBurnFee(delegation string) (bool, error) {
-	balance, err := p.rpc.Balance(rpc.BalanceInput{
-		Blockhash: "head",
-		Address:   delegation,
-	})
+	if _, ok := p.balances[delegation]; !ok {
+		if err := p.lookupBalances([]string{delegation}); err != nil {
+			return true, errors.Wrapf(err, "failed to check if delegation '%s' needs burn fee", delegation)
+		}
+	}
+
+	return p.balances[delegation] == 0, nil
+}
+
+/*
+lookupBalances looks up the balances at the head of the addresses that aren't known yet with as few requests
+to tzkt as possible, and keeps them for the rest of the payout. Accounts tzkt doesn't know are empty.
+*/
+func (p *Payout) lookupBalances(addresses []string) error {
+	if p.balances == nil {
+		p.balances = map[string]int{}
+	}
+
+	var unknown []string
+	for _, address := range addresses {
+		if _, ok := p.balances[address]; !ok {
+			unknown = append(unknown, address)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	accounts, err := p.tzkt.GetAccounts(unknown)
 	if err != nil {
-		return true, errors.Wrapf(err, "failed to check if delegation '%s' needs burn fee", delegation)
+		return errors.Wrap(err, "failed to look up balances")
+	}
+
+	for _, address := range unknown {
+		p.balances[address] = 0
+	}
+	for _, account := range accounts {
+		p.balances[account.Address] = account.Balance
 	}
 
-	if balance == 0 {
-		return true, nil
+	return nil
+}
+
+// allocationBurn returns the tez burnt to allocate an empty account, from the constants at the head
+func (p *Payout) allocationBurn() (int, error) {
+	if p.burn == 0 {
+		constants, err := p.rpc.Constants("head")
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to get allocation burn")
+		}
+		p.burn = constants.OriginationSize * constants.CostPerByte
 	}
 
-	return false, nil
+	return p.burn, nil
 }
 
 func (p *Payout) isDexterContract(address string) bool {
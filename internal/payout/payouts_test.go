@@ -2,12 +2,19 @@ package payout
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/goat-systems/go-tezos/v3/keys"
 	"github.com/goat-systems/go-tezos/v3/rpc"
 	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/failover"
+	"github.com/goat-systems/tzpay/v3/internal/ledger"
+	"github.com/goat-systems/tzpay/v3/internal/rewards"
 	"github.com/goat-systems/tzpay/v3/internal/test"
 	"github.com/goat-systems/tzpay/v3/internal/tzkt"
 	"github.com/stretchr/testify/assert"
@@ -16,7 +23,7 @@ import (
 func Test_Execute(t *testing.T) {
 	type input struct {
 		constructPayoutFunc func() (tzkt.RewardsSplit, error)
-		applyFunc           func(delegators tzkt.Delegators) ([]string, error)
+		applyFunc           func(rewardsSplit tzkt.RewardsSplit) ([]string, error)
 		inject              bool
 	}
 
@@ -50,7 +57,7 @@ func Test_Execute(t *testing.T) {
 				constructPayoutFunc: func() (tzkt.RewardsSplit, error) {
 					return tzkt.RewardsSplit{}, nil
 				},
-				applyFunc: func(delegators tzkt.Delegators) ([]string, error) {
+				applyFunc: func(rewardsSplit tzkt.RewardsSplit) ([]string, error) {
 					return []string{}, errors.New("failed to apply")
 				},
 				inject: true,
@@ -67,7 +74,7 @@ func Test_Execute(t *testing.T) {
 				constructPayoutFunc: func() (tzkt.RewardsSplit, error) {
 					return tzkt.RewardsSplit{}, nil
 				},
-				applyFunc: func(delegators tzkt.Delegators) ([]string, error) {
+				applyFunc: func(rewardsSplit tzkt.RewardsSplit) ([]string, error) {
 					return []string{}, nil
 				},
 				inject: true,
@@ -95,6 +102,112 @@ func Test_Execute(t *testing.T) {
 
 }
 
+func Test_Execute_pending(t *testing.T) {
+	payout := Payout{
+		cycle:  270,
+		inject: true,
+		constructPayoutFunc: func() (tzkt.RewardsSplit, error) {
+			return tzkt.RewardsSplit{Cycle: 270, Delegators: tzkt.Delegators{{Address: "tz1a", NetRewards: 5000, Status: tzkt.Paid}}}, nil
+		},
+		// the only operation was dropped by reorganizations and never confirmed
+		applyFunc: func(rewardsSplit tzkt.RewardsSplit) ([]string, error) {
+			return []string{}, &pendingError{operations: []string{"oo1", "oo2"}, err: errors.New("operation oo2 was dropped by 4 reorganizations")}
+		},
+	}
+
+	_, err := payout.Execute()
+	test.CheckErr(t, true, "after injecting 0 operations, oo1, oo2 may still be included: operation oo2 was dropped", err)
+	assert.True(t, IsUnsettled(err))
+	assert.Equal(t, []string{"oo1", "oo2"}, err.(*UnsettledError).Pending)
+}
+
+func Test_Execute_partiallyApplied(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ledger.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"balances":{"tz1a":[{"cycle":269,"amount":1000}],"tz1b":[{"cycle":269,"amount":2000}]}}`), 0644))
+
+	carryOver, err := ledger.Load(path)
+	assert.Nil(t, err)
+
+	payout := Payout{
+		cycle:  270,
+		inject: true,
+		ledger: carryOver,
+		constructPayoutFunc: func() (tzkt.RewardsSplit, error) {
+			return tzkt.RewardsSplit{
+				Cycle: 270,
+				Delegators: tzkt.Delegators{
+					{Address: "tz1a", NetRewards: 5000, Status: tzkt.Paid},
+					{Address: "tz1b", NetRewards: 6000, Status: tzkt.Paid},
+					{Address: "tz1c", NetRewards: 700, Status: tzkt.CarriedOver},
+				},
+			}, nil
+		},
+		// the second batch failed to be injected
+		applyFunc: func(rewardsSplit tzkt.RewardsSplit) ([]string, error) {
+			return []string{"oo1"}, errors.New("failed to inject operation")
+		},
+		positions: map[contentKey]position{
+			{0, -1}: {0, 0},
+			{1, -1}: {1, 0},
+		},
+	}
+
+	rewardsSplit, err := payout.Execute()
+	test.CheckErr(t, true, "after injecting 1 operations: failed to inject operation", err)
+	assert.True(t, IsUnsettled(err))
+	assert.Equal(t, "oo1", rewardsSplit.Delegators[0].OperationHash)
+	assert.Equal(t, "", rewardsSplit.Delegators[1].OperationHash)
+
+	saved, err := ledger.Load(path)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]ledger.Entry{
+		"tz1a": {{Cycle: 269, Amount: 1000, PaidIn: 270}},
+		"tz1b": {{Cycle: 269, Amount: 2000}},
+		"tz1c": {{Cycle: 270, Amount: 700}},
+	}, saved.Balances)
+}
+
+func Test_injected(t *testing.T) {
+	rewardsSplit := tzkt.RewardsSplit{
+		Cycle: 270,
+		Delegators: tzkt.Delegators{
+			{Address: "tz1a", Status: tzkt.Paid, OperationHash: "oo1"},
+			{Address: "tz1b", Status: tzkt.Paid},
+			{Address: "tz1c", Status: tzkt.BelowMinimum},
+			{
+				Address: "KT1a",
+				Status:  tzkt.Redirected,
+				LiquidityProviders: []tzkt.LiquidityProvider{
+					{Address: "tz1d", Status: tzkt.Paid},
+					{Address: "tz1e", Status: tzkt.Failed, OperationHash: "oo1"},
+				},
+			},
+			{
+				Address:            "KT1b",
+				Status:             tzkt.Redirected,
+				LiquidityProviders: []tzkt.LiquidityProvider{{Address: "tz1f", Status: tzkt.Paid}},
+			},
+		},
+	}
+
+	assert.Equal(t, tzkt.RewardsSplit{
+		Cycle: 270,
+		Delegators: tzkt.Delegators{
+			{Address: "tz1a", Status: tzkt.Paid, OperationHash: "oo1"},
+			{Address: "tz1c", Status: tzkt.BelowMinimum},
+			{
+				Address:            "KT1a",
+				Status:             tzkt.Redirected,
+				LiquidityProviders: []tzkt.LiquidityProvider{{Address: "tz1e", Status: tzkt.Failed, OperationHash: "oo1"}},
+			},
+		},
+	}, injected(rewardsSplit))
+}
+
 func Test_constructPayout(t *testing.T) {
 	type input struct {
 		rpcClient                         rpc.IFace
@@ -109,6 +222,13 @@ func Test_constructPayout(t *testing.T) {
 		rewardsSplit tzkt.RewardsSplit
 	}
 
+	accounting := &tzkt.Accounting{
+		Policy:  "rewards=missed block_fees=shared losses=baker accusations=kept",
+		Earned:  348797180,
+		Covered: 97554607,
+		Total:   446351787,
+	}
+
 	cases := []struct {
 		name  string
 		input input
@@ -185,6 +305,8 @@ func Test_constructPayout(t *testing.T) {
 							CurrentBalance: 176566401,
 						},
 					},
+					Accounting: accounting,
+					Model:      rewards.LegacyModel,
 				},
 			},
 		},
@@ -243,6 +365,7 @@ func Test_constructPayout(t *testing.T) {
 							GrossRewards:   36489747,
 							Share:          0.08175109509855863,
 							Fee:            1824487,
+							Status:         tzkt.Paid,
 						}, tzkt.Delegator{
 							Address:        "KT1FPyY6mAhnzyVGP8ApGvuRyF7SKcT9TDWy",
 							Balance:        60075572992,
@@ -251,6 +374,7 @@ func Test_constructPayout(t *testing.T) {
 							GrossRewards:   36206251,
 							Share:          0.08111595574266121,
 							Fee:            1810312,
+							Status:         tzkt.Paid,
 						}, tzkt.Delegator{
 							Address:        "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
 							Balance:        57461165021,
@@ -258,6 +382,7 @@ func Test_constructPayout(t *testing.T) {
 							GrossRewards: 34630604,
 							Share:        0.07758589867109342,
 							Fee:          1731530,
+							Status:       tzkt.Paid,
 						}, tzkt.Delegator{
 							Address:        "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC",
 							Balance:        55305195039,
@@ -266,11 +391,15 @@ func Test_constructPayout(t *testing.T) {
 							GrossRewards:   33331247,
 							Share:          0.07467483920161976,
 							Fee:            1666562,
+							Status:         tzkt.Paid,
 						},
 					},
 					BakerRewards:       3013,
 					BakerShare:         6.751159556435947e-06,
 					BakerCollectedFees: 7032891,
+					Dust:               3,
+					Accounting:         accounting,
+					Model:              rewards.LegacyModel,
 				},
 			},
 		},
@@ -314,6 +443,7 @@ func Test_constructPayout(t *testing.T) {
 							GrossRewards: 34630604,
 							Share:        0.07758589867109342,
 							Fee:          1731530,
+							Status:       tzkt.Paid,
 						}, tzkt.Delegator{
 							Address:        "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC",
 							Balance:        55305195039,
@@ -322,11 +452,15 @@ func Test_constructPayout(t *testing.T) {
 							GrossRewards:   33331247,
 							Share:          0.07467483920161976,
 							Fee:            1666562,
+							Status:         tzkt.Paid,
 						},
 					},
 					BakerRewards:       3013,
 					BakerShare:         6.751159556435947e-06,
 					BakerCollectedFees: 3398092,
+					Dust:               3,
+					Accounting:         accounting,
+					Model:              rewards.LegacyModel,
 				},
 			},
 		},
@@ -335,15 +469,16 @@ func Test_constructPayout(t *testing.T) {
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
 			payout := &Payout{
-				rpc:  tt.input.rpcClient,
-				tzkt: tt.input.tzktClient,
+				rpc:     tt.input.rpcClient,
+				tzkt:    tt.input.tzktClient,
+				rewards: rewards.NewTzkt(tt.input.tzktClient),
 				config: config.Config{
 					Baker: config.Baker{
 						DexterLiquidityContracts: []string{
 							"KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
 							"KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC",
 						},
-						Fee:                          0.05,
+						Fee:                          500,
 						DexterLiquidityContractsOnly: tt.input.dexterOnly,
 					},
 				},
@@ -356,6 +491,54 @@ func Test_constructPayout(t *testing.T) {
 	}
 }
 
+// staticRewards is a rewards.Provider of a fixed rewards split
+type staticRewards tzkt.RewardsSplit
+
+func (s staticRewards) RewardsSplit(delegate string, cycle int) (tzkt.RewardsSplit, error) {
+	return tzkt.RewardsSplit(s), nil
+}
+
+func Test_constructPayout_crossValidates(t *testing.T) {
+	// the delegations test.RPCMock has at any snapshot
+	delegators := tzkt.Delegators{
+		{Address: "KT1LinsZAnyxajEv4eNFWtwHMdyhbJsGfvp3", Balance: 5000000},
+		{Address: "KT1K4xei3yozp7UP5rHV5wuoDzWwBXqCGRBt", Balance: 5000000},
+		{Address: "KT1GcSsQaTtMB2HvUKU9b6WRFUnGpGx9JwGk", Balance: 5000000},
+	}
+
+	cases := []struct {
+		name           string
+		stakingBalance int
+		api            config.API
+		err            bool
+		contains       string
+	}{
+		{"is successful", 10000000000, config.API{CrossValidate: true}, false, ""},
+		{"is successful within tolerance", 10000000100, config.API{CrossValidate: true, CrossValidateTolerance: 100}, false, ""},
+		{"refuses diverging rewards split", 20000000000, config.API{CrossValidate: true}, true, "failed to contruct payout: rewards split for cycle 270 diverges from the tezos node: staking balance is 20000000000 but 10000000000 on the node"},
+		{"is successful without cross validation", 20000000000, config.API{}, false, ""},
+		{"is successful with the node's own rewards split", 20000000000, config.API{CrossValidate: true, RewardsProvider: config.NodeRewardsProvider}, false, ""},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			payout := &Payout{
+				rpc:     &test.RPCMock{},
+				tzkt:    &test.TzktMock{},
+				rewards: staticRewards{Cycle: 270, StakingBalance: tt.stakingBalance, DelegatedBalance: 15000000, Delegators: delegators},
+				cycle:   270,
+				config: config.Config{
+					API:   tt.api,
+					Baker: config.Baker{Address: "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", Fee: 500},
+				},
+			}
+
+			_, err := payout.constructPayout()
+			test.CheckErr(t, tt.err, tt.contains, err)
+		})
+	}
+}
+
 func Test_splitDelegationsAndDexterContracts(t *testing.T) {
 	type input struct {
 		cfg     config.Config
@@ -473,7 +656,7 @@ func Test_constructDelegation(t *testing.T) {
 					GrossRewards: 50000,
 					Share:        0.005,
 					Fee:          2500,
-					BlackListed:  true,
+					Status:       tzkt.Blacklisted,
 				},
 				false,
 				"",
@@ -500,7 +683,7 @@ func Test_constructDelegation(t *testing.T) {
 					GrossRewards: 50000,
 					Share:        0.005,
 					Fee:          2500,
-					BlackListed:  false,
+					Status:       tzkt.Paid,
 				},
 				false,
 				"",
@@ -516,10 +699,11 @@ func Test_constructDelegation(t *testing.T) {
 						Blacklist: []string{
 							"some_blacklisted_address",
 						},
-						Fee: 0.05,
+						Fee: 500,
 					},
 				},
-				rpc: tt.input.r,
+				rpc:  tt.input.r,
+				tzkt: &test.TzktMock{},
 			}
 			delegation, err := payout.constructDelegation(tt.input.delegator, tt.input.totalRewards, tt.input.stakingBalance)
 			test.CheckErr(t, tt.want.err, tt.want.errContains, err)
@@ -528,75 +712,9 @@ func Test_constructDelegation(t *testing.T) {
 	}
 }
 
-func Test_calculateTotals(t *testing.T) {
-	type input struct {
-		earningsOnly bool
-		rewardsSplit tzkt.RewardsSplit
-	}
-
-	cases := []struct {
-		name  string
-		input input
-		want  int
-	}{
-		{
-			"handles earnings only",
-			input{
-				true,
-				tzkt.RewardsSplit{
-					EndorsementRewards:       1000,
-					RevelationRewards:        1012,
-					OwnBlockFees:             213441,
-					OwnBlockRewards:          24124321,
-					ExtraBlockFees:           32321,
-					ExtraBlockRewards:        234123,
-					MissedEndorsementRewards: 2134423,
-					MissedOwnBlockFees:       21234,
-					MissedOwnBlockRewards:    3214312,
-				},
-			},
-			24606218,
-		},
-		{
-			"handles earnings only false",
-			input{
-				false,
-				tzkt.RewardsSplit{
-					EndorsementRewards:       1000,
-					RevelationRewards:        1012,
-					OwnBlockFees:             213441,
-					OwnBlockRewards:          24124321,
-					ExtraBlockFees:           32321,
-					ExtraBlockRewards:        234123,
-					MissedEndorsementRewards: 2134423,
-					MissedOwnBlockFees:       21234,
-					MissedOwnBlockRewards:    3214312,
-				},
-			},
-			29976187,
-		},
-	}
-
-	for _, tt := range cases {
-		t.Run(tt.name, func(t *testing.T) {
-			payout := Payout{
-				config: config.Config{
-					Baker: config.Baker{
-						EarningsOnly: tt.input.earningsOnly,
-					},
-				},
-			}
-
-			total := payout.calculateTotals(tt.input.rewardsSplit)
-			assert.Equal(t, tt.want, total)
-		})
-	}
-
-}
-
 func Test_apply(t *testing.T) {
 	type input struct {
-		rpcClient  rpc.IFace
+		rpcClient  *test.RPCMock
 		delegators tzkt.Delegators
 	}
 
@@ -773,7 +891,8 @@ func Test_apply(t *testing.T) {
 			assert.Nil(t, err)
 
 			payout := Payout{
-				rpc: tt.input.rpcClient,
+				rpc:  tt.input.rpcClient,
+				node: &nodeMock{RPCMock: tt.input.rpcClient},
 				config: config.Config{
 					Operations: config.Operations{
 						GasLimit:   10000,
@@ -784,7 +903,7 @@ func Test_apply(t *testing.T) {
 				key: key,
 			}
 
-			ops, err := payout.apply(tt.input.delegators)
+			ops, err := payout.apply(tzkt.RewardsSplit{Delegators: tt.input.delegators})
 			test.CheckErr(t, tt.want.err, tt.want.contains, err)
 			assert.Equal(t, tt.want.operations, ops)
 
@@ -795,8 +914,9 @@ func Test_apply(t *testing.T) {
 func Test_constructTransactionBatches(t *testing.T) {
 	type input struct {
 		counter    int
-		rpcClient  rpc.IFace
+		rpcClient  *test.RPCMock
 		delegators tzkt.Delegators
+		skipped    *tzkt.Skipped
 	}
 
 	type want struct {
@@ -818,6 +938,7 @@ func Test_constructTransactionBatches(t *testing.T) {
 					CounterErr: true,
 				},
 				tzkt.Delegators{},
+				nil,
 			},
 			want{
 				true,
@@ -861,6 +982,7 @@ func Test_constructTransactionBatches(t *testing.T) {
 						},
 					},
 				},
+				nil,
 			},
 			want{
 				false,
@@ -911,6 +1033,138 @@ func Test_constructTransactionBatches(t *testing.T) {
 				},
 			},
 		},
+		{
+			"is successful sending skipped rewards",
+			input{
+				100,
+				&test.RPCMock{},
+				tzkt.Delegators{
+					{
+						Address:    "somedelegation",
+						NetRewards: 900000,
+					},
+					{
+						Address:    "someblacklisteddelegation",
+						NetRewards: 950000,
+						Status:     tzkt.Blacklisted,
+					},
+				},
+				&tzkt.Skipped{Policy: config.SendSkippedRewards, Amount: 950000, Address: "somefund"},
+			},
+			want{
+				false,
+				"",
+				[]rpc.Contents{
+					{
+						{
+							Kind:        rpc.TRANSACTION,
+							Source:      "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo",
+							Counter:     101,
+							Amount:      900000,
+							Destination: "somedelegation",
+						},
+						{
+							Kind:        rpc.TRANSACTION,
+							Source:      "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo",
+							Counter:     102,
+							Amount:      950000,
+							Destination: "somefund",
+						},
+					},
+				},
+			},
+		},
+		{
+			"is successful deducting burn fees",
+			input{
+				100,
+				&test.RPCMock{},
+				tzkt.Delegators{
+					{
+						Address:    "somedelegation",
+						NetRewards: 900000,
+						BurnFee:    64250,
+					},
+					{
+						Address:    "someotherdelegation",
+						NetRewards: 950000,
+					},
+					{
+						Address: "delegation_dexter",
+						Status:  tzkt.Redirected,
+						LiquidityProviders: []tzkt.LiquidityProvider{
+							{
+								Address:    "liquidity_provider",
+								NetRewards: 950000,
+								BurnFee:    64250,
+							},
+						},
+					},
+				},
+				nil,
+			},
+			want{
+				false,
+				"",
+				[]rpc.Contents{
+					{
+						{
+							Kind:         rpc.TRANSACTION,
+							Source:       "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo",
+							Counter:      101,
+							StorageLimit: 257,
+							Amount:       835750,
+							Destination:  "somedelegation",
+						},
+						{
+							Kind:        rpc.TRANSACTION,
+							Source:      "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo",
+							Counter:     102,
+							Amount:      950000,
+							Destination: "someotherdelegation",
+						},
+						{
+							Kind:         rpc.TRANSACTION,
+							Source:       "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo",
+							Counter:      103,
+							StorageLimit: 257,
+							Amount:       885750,
+							Destination:  "liquidity_provider",
+						},
+					},
+				},
+			},
+		},
+		{
+			"is successful sending skipped rewards without payments",
+			input{
+				100,
+				&test.RPCMock{},
+				tzkt.Delegators{
+					{
+						Address:    "someblacklisteddelegation",
+						NetRewards: 950000,
+						Status:     tzkt.Blacklisted,
+					},
+				},
+				&tzkt.Skipped{Policy: config.SendSkippedRewards, Amount: 950000, Address: "somefund"},
+			},
+			want{
+				false,
+				"",
+				[]rpc.Contents{
+					{
+						{
+							Kind:        rpc.TRANSACTION,
+							Source:      "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo",
+							Counter:     101,
+							Amount:      950000,
+							Destination: "somefund",
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tt := range cases {
@@ -927,12 +1181,172 @@ func Test_constructTransactionBatches(t *testing.T) {
 						BatchSize: 100,
 					},
 				},
-				rpc: tt.input.rpcClient,
-				key: key,
+				rpc:  tt.input.rpcClient,
+				node: &nodeMock{RPCMock: tt.input.rpcClient},
+				key:  key,
+			}
+			contents, err := payout.constructTransactionBatches("some_hash", tzkt.RewardsSplit{Delegators: tt.input.delegators, SkippedRewards: tt.input.skipped})
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			assert.Equal(t, tt.want.contents, contents)
+		})
+	}
+}
+
+func Test_constructTransactionBatchesWithBatchOverhead(t *testing.T) {
+	key, err := keys.NewKey(keys.NewKeyInput{
+		Esk:      "edesk1fddn27MaLcQVEdZpAYiyGQNm6UjtWiBfNP2ZenTy3CFsoSVJgeHM9pP9cvLJ2r5Xp2quQ5mYexW1LRKee2",
+		Password: "password12345##",
+		Kind:     keys.Ed25519,
+	})
+	assert.Nil(t, err)
+
+	payout := &Payout{
+		config: config.Config{
+			Operations: config.Operations{
+				NetworkFee:    2941,
+				BatchOverhead: 1000,
+				BatchSize:     2,
+			},
+		},
+		rpc:  &test.RPCMock{},
+		node: &nodeMock{RPCMock: &test.RPCMock{}},
+		key:  key,
+	}
+
+	contents, err := payout.constructTransactionBatches("some_hash", tzkt.RewardsSplit{
+		Delegators: tzkt.Delegators{
+			{Address: "tz1a", NetRewards: 900000},
+			{Address: "tz1b", NetRewards: 900000},
+			{Address: "tz1c", NetRewards: 900000},
+		},
+	})
+	assert.Nil(t, err)
+
+	var fees [][]int64
+	for _, transactions := range contents {
+		var batch []int64
+		for _, transaction := range transactions {
+			batch = append(batch, transaction.Fee)
+		}
+		fees = append(fees, batch)
+	}
+	assert.Equal(t, [][]int64{{3941, 2941}, {3941}}, fees)
+}
+
+func Test_constructTransactionBatchesReveals(t *testing.T) {
+	key, err := keys.NewKey(keys.NewKeyInput{
+		Esk:      "edesk1fddn27MaLcQVEdZpAYiyGQNm6UjtWiBfNP2ZenTy3CFsoSVJgeHM9pP9cvLJ2r5Xp2quQ5mYexW1LRKee2",
+		Password: "password12345##",
+		Kind:     keys.Ed25519,
+	})
+	assert.Nil(t, err)
+
+	reveal := rpc.Content{
+		Kind:      rpc.REVEAL,
+		Source:    "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo",
+		Fee:       1268,
+		GasLimit:  10000,
+		Counter:   101,
+		PublicKey: "edpkuHMDkMz46HdRXYwom3xRwqk3zQ5ihWX4j8dwo2R2h8o4gPcbN5",
+	}
+
+	transfer := func(destination string, fee int64, counter int) rpc.Content {
+		return rpc.Content{
+			Kind:        rpc.TRANSACTION,
+			Source:      "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo",
+			Destination: destination,
+			Amount:      900000,
+			Fee:         fee,
+			Counter:     counter,
+		}
+	}
+
+	type want struct {
+		err       bool
+		contains  string
+		contents  []rpc.Contents
+		reveal    *rpc.Content
+		positions map[contentKey]position
+	}
+
+	cases := []struct {
+		name       string
+		rpcClient  *test.RPCMock
+		delegators tzkt.Delegators
+		want       want
+	}{
+		{
+			"prepends a reveal to the first batch",
+			&test.RPCMock{Unrevealed: true},
+			tzkt.Delegators{{Address: "tz1a", NetRewards: 900000}, {Address: "tz1b", NetRewards: 900000}, {Address: "tz1c", NetRewards: 900000}},
+			want{
+				false,
+				"",
+				[]rpc.Contents{{reveal, transfer("tz1a", 3941, 102), transfer("tz1b", 2941, 103)}, {transfer("tz1c", 3941, 104)}},
+				&reveal,
+				map[contentKey]position{revealContent: {0, 0}, {0, -1}: {0, 1}, {1, -1}: {0, 2}, {2, -1}: {1, 0}},
+			},
+		},
+		{
+			"doesn't reveal a revealed key",
+			&test.RPCMock{},
+			tzkt.Delegators{{Address: "tz1a", NetRewards: 900000}},
+			want{false, "", []rpc.Contents{{transfer("tz1a", 3941, 101)}}, nil, map[contentKey]position{{0, -1}: {0, 0}}},
+		},
+		{
+			"prepends a reveal to the first batch with transfers",
+			&test.RPCMock{Unrevealed: true},
+			tzkt.Delegators{
+				{Address: "tz1a", NetRewards: 900000, Status: tzkt.Blacklisted},
+				{Address: "tz1b", NetRewards: 900000, Status: tzkt.BelowMinimum},
+				{Address: "tz1c", NetRewards: 900000},
+			},
+			want{
+				false,
+				"",
+				[]rpc.Contents{{reveal, transfer("tz1c", 3941, 102)}},
+				&reveal,
+				map[contentKey]position{revealContent: {0, 0}, {2, -1}: {0, 1}},
+			},
+		},
+		{
+			"doesn't reveal without transfers",
+			&test.RPCMock{Unrevealed: true},
+			tzkt.Delegators{},
+			want{false, "", nil, nil, map[contentKey]position{}},
+		},
+		{
+			"handles failure to get manager key",
+			&test.RPCMock{ManagerKeyErr: true},
+			tzkt.Delegators{{Address: "tz1a", NetRewards: 900000}},
+			want{true, "failed to get manager key", nil, nil, nil},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			payout := &Payout{
+				config: config.Config{
+					Operations: config.Operations{
+						NetworkFee:     2941,
+						BatchOverhead:  1000,
+						BatchSize:      2,
+						RevealFee:      1268,
+						RevealGasLimit: 10000,
+					},
+				},
+				rpc:  tt.rpcClient,
+				node: &nodeMock{RPCMock: tt.rpcClient},
+				key:  key,
 			}
-			contents, err := payout.constructTransactionBatches("some_hash", tt.input.delegators)
+
+			contents, err := payout.constructTransactionBatches("some_hash", tzkt.RewardsSplit{Delegators: tt.delegators})
 			test.CheckErr(t, tt.want.err, tt.want.contains, err)
 			assert.Equal(t, tt.want.contents, contents)
+			assert.Equal(t, tt.want.reveal, payout.reveal)
+			if !tt.want.err {
+				assert.Equal(t, tt.want.positions, payout.positions)
+			}
 		})
 	}
 }
@@ -996,9 +1410,22 @@ func Test_batch(t *testing.T) {
 }
 
 func Test_injectOperations(t *testing.T) {
+	contents := rpc.Contents{
+		{
+			Kind:        rpc.TRANSACTION,
+			Source:      "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo",
+			Fee:         3000,
+			Counter:     101,
+			GasLimit:    10000,
+			Amount:      900000,
+			Destination: "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+		},
+	}
+
 	type input struct {
-		rpcClient  rpc.IFace
-		operations []string
+		rpcClient   *test.RPCMock
+		unconfirmed bool
+		batches     []rpc.Contents
 	}
 
 	type want struct {
@@ -1013,42 +1440,59 @@ func Test_injectOperations(t *testing.T) {
 		want  want
 	}{
 		{
-			"handles failure to sign",
+			"handles failure to get head",
 			input{
-				rpcClient: &rpc.Client{},
-				operations: []string{
-					"non_hex_string",
-				},
+				rpcClient: &test.RPCMock{HeadErr: true},
+				batches:   []rpc.Contents{contents},
 			},
 			want{
 				true,
-				"failed to hex decode message",
+				"failed to inject operation: failed to get block",
 				[]string{},
 			},
 		},
 		{
-			"handles failure to inject",
+			"handles failure to forge",
 			input{
-				rpcClient: &test.RPCMock{
-					InjectionOperationErr: true,
-				},
-				operations: []string{
-					"5aff622d53d32a8bae591627718c60a35b16737e301c57a13b6f1765483d88ff6c007fd82c06cf5a203f18faaf562447ed1efcc6c010830a07c350008090dfc04a0000a31e81ac3425310e3274a4698a793b2839dc0afa00",
-				},
+				rpcClient: &test.RPCMock{},
+				batches:   []rpc.Contents{{{Kind: rpc.TRANSACTION, Destination: "somedelegation"}}},
 			},
 			want{
 				true,
-				"failed to inject operation",
+				"failed to forge operation",
 				[]string{},
 			},
 		},
 		{
-			"is successful",
+			"handles failure to inject",
+			input{
+				rpcClient: &test.RPCMock{InjectionOperationErr: true},
+				batches:   []rpc.Contents{contents},
+			},
+			want{
+				true,
+				"failed to inject operation",
+				[]string{},
+			},
+		},
+		{
+			"handles failure to confirm",
+			input{
+				rpcClient:   &test.RPCMock{},
+				unconfirmed: true,
+				batches:     []rpc.Contents{contents},
+			},
+			want{
+				true,
+				"failed to inject operation: failed to confirm operation ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M",
+				[]string{},
+			},
+		},
+		{
+			"is successful",
 			input{
 				rpcClient: &test.RPCMock{},
-				operations: []string{
-					"5aff622d53d32a8bae591627718c60a35b16737e301c57a13b6f1765483d88ff6c007fd82c06cf5a203f18faaf562447ed1efcc6c010830a07c350008090dfc04a0000a31e81ac3425310e3274a4698a793b2839dc0afa00",
-				},
+				batches:   []rpc.Contents{contents},
 			},
 			want{
 				false,
@@ -1060,6 +1504,13 @@ func Test_injectOperations(t *testing.T) {
 
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
+			confirmationDurationInterval = time.Millisecond * 10
+			confirmationTimoutInterval = time.Millisecond * 100
+			defer func() {
+				confirmationDurationInterval = time.Second * 1
+				confirmationTimoutInterval = time.Minute * 2
+			}()
+
 			key, err := keys.NewKey(keys.NewKeyInput{
 				Esk:      "edesk1fddn27MaLcQVEdZpAYiyGQNm6UjtWiBfNP2ZenTy3CFsoSVJgeHM9pP9cvLJ2r5Xp2quQ5mYexW1LRKee2",
 				Password: "password12345##",
@@ -1068,43 +1519,180 @@ func Test_injectOperations(t *testing.T) {
 			assert.Nil(t, err)
 
 			payout := Payout{
-				rpc: tt.input.rpcClient,
-				key: key,
+				rpc:  tt.input.rpcClient,
+				node: &nodeMock{RPCMock: tt.input.rpcClient, err: tt.input.unconfirmed},
+				key:  key,
 			}
 
-			ophashes, err := payout.injectOperations(tt.input.operations)
+			ophashes, err := payout.injectOperations(tt.input.batches)
 			test.CheckErr(t, tt.want.err, tt.want.contains, err)
 			assert.Equal(t, tt.want.ophashes, ophashes)
 
+			// an operation injected but not confirmed may still be included
+			_, pending := err.(*pendingError)
+			assert.Equal(t, tt.input.unconfirmed, pending)
+		})
+	}
+}
+
+func Test_replaceable(t *testing.T) {
+	contents := rpc.Contents{{Kind: rpc.TRANSACTION, Source: "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo", Counter: 101}}
+	dropped := "ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M"
+
+	type want struct {
+		err         bool
+		contains    string
+		replaceable bool
+	}
+
+	cases := []struct {
+		name     string
+		node     *nodeMock
+		contents rpc.Contents
+		want     want
+	}{
+		{
+			"is replaceable when neither included nor its counters used",
+			&nodeMock{RPCMock: &test.RPCMock{}, head: 105, included: func(level int) []failover.Operation { return nil }},
+			contents,
+			want{false, "", true},
+		},
+		{
+			"waits for the dropped operation included again",
+			&nodeMock{RPCMock: &test.RPCMock{}, head: 105},
+			contents,
+			want{false, "", false},
+		},
+		{
+			"fails when its counters were used by another operation",
+			&nodeMock{RPCMock: &test.RPCMock{}, head: 105, included: func(level int) []failover.Operation { return nil }},
+			rpc.Contents{{Kind: rpc.TRANSACTION, Source: "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo", Counter: 100}},
+			want{true, "counter 100 of the dropped operation was used by an operation that isn't any of " + dropped, false},
+		},
+		{
+			"handles failure to get counter",
+			&nodeMock{RPCMock: &test.RPCMock{CounterErr: true}, head: 105, included: func(level int) []failover.Operation { return nil }},
+			contents,
+			want{true, "failed to check the counter of the dropped operation", false},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			payout := Payout{rpc: tt.node, node: tt.node}
+			head, err := tt.node.Head()
+			assert.Nil(t, err)
+
+			replaceable, err := payout.replaceable([]string{dropped}, tt.contents, 100, head)
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			assert.Equal(t, tt.want.replaceable, replaceable)
 		})
 	}
 }
 
+/*
+nodeMock mocks the reads of a tezos node the rpc client doesn't cover on top of an RPCMock, with the head at level
+head, baking a block every time it's read if advance is set. The operations included at a level are those of
+included, by default a transfer applied in the operation the RPCMock injects.
+*/
+type nodeMock struct {
+	*test.RPCMock
+	head     int
+	advance  bool
+	included func(level int) []failover.Operation
+	err      bool
+}
+
+func (n *nodeMock) Head() (*rpc.Block, error) {
+	head, err := n.RPCMock.Head()
+	if err != nil {
+		return head, err
+	}
+
+	if n.advance {
+		n.head++
+	}
+	head.Header.Level = n.head
+	return head, nil
+}
+
+func (n *nodeMock) ManagerOperations(level int) ([]failover.Operation, error) {
+	if n.err {
+		return nil, errors.New("failed to get manager operations")
+	}
+
+	if n.included != nil {
+		return n.included(level), nil
+	}
+
+	return []failover.Operation{
+		{
+			Hash:     "ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M",
+			Contents: []failover.Content{transfer("tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV", failover.Applied)},
+		},
+	}, nil
+}
+
+// transfer returns the content of a transfer to destination included with status
+func transfer(destination, status string, internal ...failover.InternalResult) failover.Content {
+	var content failover.Content
+	content.Kind = "transaction"
+	content.Destination = destination
+	content.Metadata.OperationResult = failover.Result{Status: status, ConsumedGas: 1427}
+	content.Metadata.InternalOperationResults = internal
+	return content
+}
+
 func Test_confirmOperation(t *testing.T) {
 	type input struct {
 		operation string
-		rpcClient rpc.IFace
+		level     int
+		node      *nodeMock
+	}
+
+	type want struct {
+		ok    bool
+		level int
 	}
+
+	included := func(level int) []failover.Operation {
+		if level != 102 {
+			return []failover.Operation{{Hash: "ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfFGD"}}
+		}
+		return []failover.Operation{{Hash: "ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M"}}
+	}
+
 	cases := []struct {
 		name  string
 		input input
-		want  bool
+		want  want
 	}{
 		{
 			"is successful",
 			input{
 				"ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M",
-				&test.RPCMock{},
+				0,
+				&nodeMock{RPCMock: &test.RPCMock{}},
 			},
-			true,
+			want{true, 0},
+		},
+		{
+			"finds operation in a block before the head",
+			input{
+				"ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M",
+				100,
+				&nodeMock{RPCMock: &test.RPCMock{}, head: 104, included: included},
+			},
+			want{true, 102},
 		},
 		{
 			"handles timeout",
 			input{
 				"ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2safdj",
-				&test.RPCMock{OperationHashesErr: true},
+				0,
+				&nodeMock{RPCMock: &test.RPCMock{}, err: true},
 			},
-			false,
+			want{false, 0},
 		},
 	}
 
@@ -1114,11 +1702,528 @@ func Test_confirmOperation(t *testing.T) {
 			confirmationTimoutInterval = time.Second * 1
 
 			payout := Payout{
-				rpc: tt.input.rpcClient,
+				rpc:  tt.input.node,
+				node: tt.input.node,
+			}
+
+			operation, level, ok := payout.confirmOperation([]string{tt.input.operation}, tt.input.level)
+			assert.Equal(t, tt.want.ok, ok)
+			assert.Equal(t, tt.want.level, level)
+			if ok {
+				assert.Equal(t, tt.input.operation, operation.Hash)
+			}
+		})
+	}
+}
+
+func Test_settleOperation(t *testing.T) {
+	at := func(levels ...int) func(level int) []failover.Operation {
+		return func(level int) []failover.Operation {
+			for _, l := range levels {
+				if l == level {
+					return []failover.Operation{{Hash: "ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M"}}
+				}
+			}
+			return nil
+		}
+	}
+
+	type want struct {
+		err      bool
+		contains string
+		ok       bool
+		level    int
+	}
+
+	cases := []struct {
+		name string
+		node *nodeMock
+		want want
+	}{
+		{
+			"is successful",
+			&nodeMock{RPCMock: &test.RPCMock{}, head: 100, advance: true, included: at(100)},
+			want{false, "", true, 100},
+		},
+		{
+			"follows operation included again in a later block",
+			&nodeMock{RPCMock: &test.RPCMock{}, head: 100, advance: true, included: at(101)},
+			want{false, "", true, 101},
+		},
+		{
+			"detects operation dropped by a reorganization",
+			&nodeMock{RPCMock: &test.RPCMock{}, head: 100, advance: true, included: at()},
+			want{false, "", false, 100},
+		},
+		{
+			"handles chain not advancing",
+			&nodeMock{RPCMock: &test.RPCMock{}, head: 100, included: at(100)},
+			want{true, "failed to settle operation ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M: no block was baked for", false, 100},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			confirmationDurationInterval = time.Millisecond * 10
+			confirmationTimoutInterval = time.Millisecond * 100
+			defer func() {
+				confirmationDurationInterval = time.Second * 1
+				confirmationTimoutInterval = time.Minute * 2
+			}()
+
+			payout := Payout{
+				config: config.Config{Operations: config.Operations{Confirmations: 2}},
+				rpc:    tt.node,
+				node:   tt.node,
+			}
+
+			operation, level, ok, err := payout.settleOperation([]string{"ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M"}, 100)
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			assert.Equal(t, tt.want.ok, ok)
+			assert.Equal(t, tt.want.level, level)
+			if ok {
+				assert.Equal(t, "ooYympR9wfV98X4MUHtE78NjXYRDeMTAD4ei7zEZDqoHv2rfb1M", operation.Hash)
+			}
+		})
+	}
+}
+
+func Test_setReceipts(t *testing.T) {
+	internal := func(status string) failover.InternalResult {
+		return failover.InternalResult{
+			Kind:        "transaction",
+			Destination: "tz1Ykmc29JfQvWnjWRPYTPUZBLW4gwa9YKUD",
+			Result:      failover.Result{Status: status, ConsumedMilligas: 1500100, PaidStorageSizeDiff: 67},
+		}
+	}
+
+	payout := Payout{
+		receipts: map[string]failover.Operation{
+			"oo1": {
+				Hash: "oo1",
+				Contents: []failover.Content{
+					transfer("tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", failover.Applied),
+					transfer("KT1FPyY6mAhnzyVGP8ApGvuRyF7SKcT9TDWy", failover.Applied, internal(failover.Applied)),
+					transfer("tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", failover.Backtracked),
+					transfer("KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", failover.Applied, internal(failover.Applied), internal(failover.Failed)),
+				},
+			},
+		},
+		positions: map[contentKey]position{
+			{0, -1}: {0, 0},
+			{1, -1}: {0, 1},
+			{3, -1}: {1, 0},
+			{4, 0}:  {0, 2},
+			{4, 1}:  {0, 3},
+		},
+	}
+
+	delegators := tzkt.Delegators{
+		{Address: "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", Status: tzkt.Paid, OperationHash: "oo1"},
+		{Address: "KT1FPyY6mAhnzyVGP8ApGvuRyF7SKcT9TDWy", Status: tzkt.Paid, OperationHash: "oo1"},
+		{Address: "tz1iZ9LkpAhN8X1L6RpBtfy3wxpEWzFrXz8j", Status: tzkt.BelowMinimum},
+		{Address: "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", Status: tzkt.Paid, OperationHash: "oo2"},
+		{
+			Address: "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
+			Status:  tzkt.Redirected,
+			LiquidityProviders: []tzkt.LiquidityProvider{
+				{Address: "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", Status: tzkt.Paid, OperationHash: "oo1"},
+				{Address: "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", Status: tzkt.Paid, OperationHash: "oo1"},
+			},
+		},
+	}
+	payout.setReceipts(delegators)
+
+	assert.Equal(t, tzkt.Delegators{
+		{Address: "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", Status: tzkt.Paid, OperationHash: "oo1", Receipt: &tzkt.Receipt{Status: "applied", ConsumedGas: 1427}},
+		{Address: "KT1FPyY6mAhnzyVGP8ApGvuRyF7SKcT9TDWy", Status: tzkt.Paid, OperationHash: "oo1", Receipt: &tzkt.Receipt{Status: "applied", ConsumedGas: 2928, PaidStorageSizeDiff: 67}},
+		{Address: "tz1iZ9LkpAhN8X1L6RpBtfy3wxpEWzFrXz8j", Status: tzkt.BelowMinimum},
+		{Address: "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", Status: tzkt.Paid, OperationHash: "oo2"},
+		{
+			Address: "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
+			Status:  tzkt.Redirected,
+			LiquidityProviders: []tzkt.LiquidityProvider{
+				{Address: "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", Status: tzkt.Failed, OperationHash: "oo1", Receipt: &tzkt.Receipt{Status: "backtracked", ConsumedGas: 1427}},
+				{Address: "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", Status: tzkt.Failed, OperationHash: "oo1", Receipt: &tzkt.Receipt{Status: "failed", ConsumedGas: 4429, PaidStorageSizeDiff: 134}},
+			},
+		},
+	}, delegators)
+}
+
+func Test_status(t *testing.T) {
+	type input struct {
+		address          string
+		netRewards       int
+		rpcClient        *test.RPCMock
+		tzktClient       *test.TzktMock
+		burnFees         string
+		carryOver        bool
+		deductNetworkFee bool
+	}
+
+	type want struct {
+		err      bool
+		contains string
+		status   tzkt.Status
+		burnFee  int
+	}
+
+	cases := []struct {
+		name  string
+		input input
+		want  want
+	}{
+		{
+			"is paid",
+			input{"tz1a", 100, &test.RPCMock{}, &test.TzktMock{}, "", false, false},
+			want{false, "", tzkt.Paid, 0},
+		},
+		{
+			"is paid to contract accepting tez",
+			input{"KT1a", 100, &test.RPCMock{}, &test.TzktMock{}, "", false, false},
+			want{false, "", tzkt.Paid, 0},
+		},
+		{
+			"is blacklisted",
+			input{"some_blacklisted_address", 100, &test.RPCMock{}, &test.TzktMock{AccountsEmpty: true}, "", false, false},
+			want{false, "", tzkt.Blacklisted, 0},
+		},
+		{
+			"needs burn",
+			input{"tz1a", 100, &test.RPCMock{}, &test.TzktMock{AccountsEmpty: true}, "", false, false},
+			want{false, "", tzkt.NeedsBurn, 0},
+		},
+		{
+			"is paid when baker pays burn",
+			input{"tz1a", 100, &test.RPCMock{}, &test.TzktMock{AccountsEmpty: true}, config.PayBurnFees, false, false},
+			want{false, "", tzkt.Paid, 0},
+		},
+		{
+			"is paid less burn when burn is deducted",
+			input{"tz1a", 64350, &test.RPCMock{}, &test.TzktMock{AccountsEmpty: true}, config.DeductBurnFees, false, false},
+			want{false, "", tzkt.Paid, 64250},
+		},
+		{
+			"needs burn when burn is deducted from less",
+			input{"tz1a", 64250, &test.RPCMock{}, &test.TzktMock{AccountsEmpty: true}, config.DeductBurnFees, false, false},
+			want{false, "", tzkt.NeedsBurn, 0},
+		},
+		{
+			"is below minimum less burn",
+			input{"tz1a", 64349, &test.RPCMock{}, &test.TzktMock{AccountsEmpty: true}, config.DeductBurnFees, false, false},
+			want{false, "", tzkt.BelowMinimum, 0},
+		},
+		{
+			"is paid in full when burn is deducted from funded account",
+			input{"tz1a", 100, &test.RPCMock{}, &test.TzktMock{}, config.DeductBurnFees, false, false},
+			want{false, "", tzkt.Paid, 0},
+		},
+		{
+			"is paid less network fee when network fee is deducted",
+			input{"tz1a", 115, &test.RPCMock{}, &test.TzktMock{}, "", false, true},
+			want{false, "", tzkt.Paid, 0},
+		},
+		{
+			"is below minimum less network fee",
+			input{"tz1a", 114, &test.RPCMock{}, &test.TzktMock{}, "", false, true},
+			want{false, "", tzkt.BelowMinimum, 0},
+		},
+		{
+			"rejects tez",
+			input{"KT1a", 100, &test.RPCMock{}, &test.TzktMock{RejectsTez: true}, "", false, false},
+			want{false, "", tzkt.RejectsTez, 0},
+		},
+		{
+			"is below minimum",
+			input{"tz1a", 99, &test.RPCMock{}, &test.TzktMock{}, "", false, false},
+			want{false, "", tzkt.BelowMinimum, 0},
+		},
+		{
+			"is carried over",
+			input{"tz1a", 99, &test.RPCMock{}, &test.TzktMock{}, "", true, false},
+			want{false, "", tzkt.CarriedOver, 0},
+		},
+		{
+			"is paid with carry over",
+			input{"tz1a", 100, &test.RPCMock{}, &test.TzktMock{}, "", true, false},
+			want{false, "", tzkt.Paid, 0},
+		},
+		{
+			"handles failure to get balance",
+			input{"tz1a", 100, &test.RPCMock{}, &test.TzktMock{AccountsErr: true}, "", false, false},
+			want{true, "failed to check if delegation 'tz1a' needs burn fee", tzkt.Paid, 0},
+		},
+		{
+			"handles failure to get entrypoints",
+			input{"KT1a", 100, &test.RPCMock{}, &test.TzktMock{EntrypointsErr: true}, "", false, false},
+			want{true, "failed to check if contract 'KT1a' accepts tez", tzkt.Paid, 0},
+		},
+		{
+			"handles failure to get constants",
+			input{"tz1a", 64350, &test.RPCMock{ConstantsErr: true}, &test.TzktMock{AccountsEmpty: true}, config.DeductBurnFees, false, false},
+			want{true, "failed to get allocation burn", tzkt.Paid, 0},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			payout := &Payout{
+				config: config.Config{
+					Baker: config.Baker{
+						Blacklist:      []string{"some_blacklisted_address"},
+						MinimumPayment: 100,
+						BurnFees:       tt.input.burnFees,
+						CarryOver:      tt.input.carryOver,
+					},
+					Operations: config.Operations{
+						NetworkFee:       10,
+						BatchOverhead:    5,
+						DeductNetworkFee: tt.input.deductNetworkFee,
+					},
+				},
+				rpc:  tt.input.rpcClient,
+				tzkt: tt.input.tzktClient,
+			}
+
+			status, burnFee, err := payout.status(tt.input.address, tt.input.netRewards)
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			assert.Equal(t, tt.want.status, status)
+			assert.Equal(t, tt.want.burnFee, burnFee)
+		})
+	}
+}
+
+type accountsMock struct {
+	test.TzktMock
+	lookups [][]string
+}
+
+func (a *accountsMock) GetAccounts(addresses []string, options ...tzkt.URLParameters) ([]tzkt.Account, error) {
+	a.lookups = append(a.lookups, addresses)
+	return []tzkt.Account{{Type: "user", Address: "tz1a", Balance: 100}}, nil
+}
+
+type entrypointsMock struct {
+	test.TzktMock
+	lookups []string
+}
+
+func (e *entrypointsMock) GetEntrypoints(address string, options ...tzkt.URLParameters) ([]tzkt.Entrypoint, error) {
+	e.lookups = append(e.lookups, address)
+	return e.TzktMock.GetEntrypoints(address, options...)
+}
+
+func Test_rejectsTez(t *testing.T) {
+	cache := &contractCache{rejectsTez: map[string]bool{}}
+
+	// payouts of later cycles share what earlier ones looked up
+	first := &entrypointsMock{TzktMock: test.TzktMock{RejectsTez: true}}
+	payout := &Payout{tzkt: first, contracts: cache}
+	for _, contract := range []string{"KT1a", "KT1a", "KT1b"} {
+		rejectsTez, err := payout.rejectsTez(contract)
+		assert.Nil(t, err)
+		assert.True(t, rejectsTez)
+	}
+	assert.Equal(t, []string{"KT1a", "KT1b"}, first.lookups)
+
+	second := &entrypointsMock{}
+	payout = &Payout{tzkt: second, contracts: cache}
+	for _, contract := range []string{"KT1a", "KT1c"} {
+		rejectsTez, err := payout.rejectsTez(contract)
+		assert.Nil(t, err)
+		assert.Equal(t, contract == "KT1a", rejectsTez)
+	}
+	assert.Equal(t, []string{"KT1c"}, second.lookups)
+
+	// failures to look up entrypoints aren't remembered
+	payout = &Payout{tzkt: &test.TzktMock{EntrypointsErr: true}, contracts: cache}
+	_, err := payout.rejectsTez("KT1d")
+	test.CheckErr(t, true, "failed to check if contract 'KT1d' accepts tez", err)
+	_, ok := cache.lookup("KT1d")
+	assert.False(t, ok)
+}
+
+func Test_lookupBalances(t *testing.T) {
+	accounts := &accountsMock{}
+	payout := &Payout{tzkt: accounts}
+
+	assert.Nil(t, payout.lookupBalances([]string{"tz1a", "tz1b"}))
+	assert.Nil(t, payout.lookupBalances([]string{"tz1a", "tz1c"}))
+	assert.Equal(t, map[string]int{"tz1a": 100, "tz1b": 0, "tz1c": 0}, payout.balances)
+
+	requiresBurnFee, err := payout.requiresBurnFee("tz1b")
+	assert.Nil(t, err)
+	assert.True(t, requiresBurnFee)
+
+	requiresBurnFee, err = payout.requiresBurnFee("tz1a")
+	assert.Nil(t, err)
+	assert.False(t, requiresBurnFee)
+
+	_, err = payout.requiresBurnFee("tz1d")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"tz1a", "tz1b"}, {"tz1c"}, {"tz1d"}}, accounts.lookups)
+
+	payout = &Payout{tzkt: &test.TzktMock{AccountsErr: true}}
+	test.CheckErr(t, true, "failed to look up balances: failed to get accounts", payout.lookupBalances([]string{"tz1a"}))
+
+	// addresses are looked up in pages
+	var fixture test.TzktFixture
+	var addresses []string
+	for i := 0; i < 250; i++ {
+		address := fmt.Sprintf("tz1%d", i)
+		addresses = append(addresses, address)
+		if i%2 == 0 {
+			fixture.Accounts = append(fixture.Accounts, tzkt.Account{Type: "user", Address: address, Balance: i + 1})
+		}
+	}
+
+	indexer := test.NewTzkt(fixture, nil)
+	defer indexer.Close()
+
+	payout = &Payout{tzkt: tzkt.NewTZKTWithOptions(indexer.URL, tzkt.Options{})}
+	assert.Nil(t, payout.lookupBalances(addresses))
+	assert.Len(t, payout.balances, 250)
+	assert.Equal(t, 249, payout.balances["tz1248"])
+	assert.Equal(t, 0, payout.balances["tz1249"])
+}
+
+func Test_deductNetworkFees(t *testing.T) {
+	delegators := tzkt.Delegators{
+		{Address: "tz1a", NetRewards: 1000},
+		{Address: "tz1b", NetRewards: 1000, Status: tzkt.BelowMinimum},
+		{
+			Address: "KT1a",
+			Status:  tzkt.Redirected,
+			LiquidityProviders: []tzkt.LiquidityProvider{
+				{Address: "tz1c", NetRewards: 1000},
+				{Address: "tz1d", NetRewards: 1000, Status: tzkt.Blacklisted},
+			},
+		},
+		{Address: "tz1e", NetRewards: 1000},
+	}
+
+	payout := &Payout{
+		config: config.Config{
+			Operations: config.Operations{
+				NetworkFee:    100,
+				BatchOverhead: 25,
+				BatchSize:     3,
+			},
+		},
+	}
+	payout.deductNetworkFees(delegators)
+
+	assert.Equal(t, tzkt.Delegators{
+		{Address: "tz1a", NetRewards: 1000, NetworkFee: 113},
+		{Address: "tz1b", NetRewards: 1000, Status: tzkt.BelowMinimum},
+		{
+			Address: "KT1a",
+			Status:  tzkt.Redirected,
+			LiquidityProviders: []tzkt.LiquidityProvider{
+				{Address: "tz1c", NetRewards: 1000, NetworkFee: 112},
+				{Address: "tz1d", NetRewards: 1000, Status: tzkt.Blacklisted},
+			},
+		},
+		{Address: "tz1e", NetRewards: 1000, NetworkFee: 125},
+	}, delegators)
+	assert.Equal(t, 887, delegators[0].Amount())
+}
+
+func Test_applySkippedRewardsPolicy(t *testing.T) {
+	delegators := func() tzkt.Delegators {
+		return tzkt.Delegators{
+			{Address: "tz1a", NetRewards: 3000, Status: tzkt.Paid},
+			{Address: "tz1b", NetRewards: 700, Status: tzkt.Blacklisted},
+			{Address: "tz1c", NetRewards: 50, Status: tzkt.CarriedOver},
+			{
+				Address: "KT1a",
+				Status:  tzkt.Redirected,
+				LiquidityProviders: []tzkt.LiquidityProvider{
+					{Address: "tz1d", NetRewards: 1000, Status: tzkt.Paid},
+					{Address: "tz1e", NetRewards: 300, Status: tzkt.BelowMinimum},
+				},
+			},
+		}
+	}
+
+	type want struct {
+		skipped       *tzkt.Skipped
+		redistributed []int // tz1a then tz1d
+		dust          int
+	}
+
+	cases := []struct {
+		name       string
+		policy     string
+		delegators tzkt.Delegators
+		want       want
+	}{
+		{
+			"is successful with keep",
+			config.KeepSkippedRewards,
+			delegators(),
+			want{&tzkt.Skipped{Policy: config.KeepSkippedRewards, Amount: 1000}, []int{0, 0}, 0},
+		},
+		{
+			"is successful with redistribute",
+			config.RedistributeSkippedRewards,
+			delegators(),
+			want{&tzkt.Skipped{Policy: config.RedistributeSkippedRewards, Amount: 1000}, []int{750, 250}, 0},
+		},
+		{
+			"is successful with send",
+			config.SendSkippedRewards,
+			delegators(),
+			want{&tzkt.Skipped{Policy: config.SendSkippedRewards, Amount: 1000, Address: "tz1fund"}, []int{0, 0}, 0},
+		},
+		{
+			"adds rounding to dust",
+			config.RedistributeSkippedRewards,
+			tzkt.Delegators{
+				{Address: "tz1a", NetRewards: 1, Status: tzkt.Paid},
+				{Address: "tz1b", NetRewards: 10, Status: tzkt.Blacklisted},
+				{Address: "KT1a", LiquidityProviders: []tzkt.LiquidityProvider{{Address: "tz1d", NetRewards: 2, Status: tzkt.Paid}}},
+			},
+			want{&tzkt.Skipped{Policy: config.RedistributeSkippedRewards, Amount: 10}, []int{3, 6}, 1},
+		},
+		{
+			"keeps when nobody is paid",
+			config.RedistributeSkippedRewards,
+			tzkt.Delegators{
+				{Address: "tz1a", NetRewards: 10, Status: tzkt.BelowMinimum},
+				{Address: "KT1a", LiquidityProviders: []tzkt.LiquidityProvider{{Address: "tz1d", NetRewards: 10, Status: tzkt.NeedsBurn}}},
+			},
+			want{&tzkt.Skipped{Policy: config.KeepSkippedRewards, Amount: 20}, []int{0, 0}, 0},
+		},
+		{
+			"is successful with nothing skipped",
+			config.SendSkippedRewards,
+			tzkt.Delegators{
+				{Address: "tz1a", NetRewards: 10, Status: tzkt.Paid},
+				{Address: "KT1a", LiquidityProviders: []tzkt.LiquidityProvider{{Address: "tz1d", NetRewards: 10, Status: tzkt.Paid}}},
+			},
+			want{nil, []int{0, 0}, 0},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			payout := &Payout{
+				config: config.Config{
+					Baker: config.Baker{
+						SkippedRewards:        tt.policy,
+						SkippedRewardsAddress: "tz1fund",
+					},
+				},
 			}
 
-			ok := payout.confirmOperation(tt.input.operation)
-			assert.Equal(t, tt.want, ok)
+			rewardsSplit := tzkt.RewardsSplit{Delegators: tt.delegators}
+			payout.applySkippedRewardsPolicy(&rewardsSplit)
+
+			contract := rewardsSplit.Delegators[len(rewardsSplit.Delegators)-1]
+			assert.Equal(t, tt.want.skipped, rewardsSplit.SkippedRewards)
+			assert.Equal(t, tt.want.redistributed, []int{rewardsSplit.Delegators[0].Redistributed, contract.LiquidityProviders[0].Redistributed})
+			assert.Equal(t, tt.want.dust, rewardsSplit.Dust)
 		})
 	}
 }
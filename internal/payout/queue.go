@@ -78,7 +78,17 @@ func (q *Queue) Start() {
 				q.logger.WithFields(logrus.Fields{"error": err.Error(), "payout-cycle": payout.cycle}).Error("Failed to dequeue payout in queue.")
 				continue
 			}
+			// a payout that was or may have been paid in part isn't retried, executing it again could pay twice
 			rewardsSplit, err := payout.Execute()
+			if IsUnsettled(err) {
+				q.logger.WithFields(logrus.Fields{"error": err.Error(), "payout-cycle": payout.cycle}).Error("Payout was or may have been paid in part, it must be settled by hand.")
+				if q.notifier != nil {
+					if err := q.notifier.Notify(notifier.UnsettledMessage(payout.cycle, rewardsSplit)); err != nil {
+						q.logger.WithField("error", err.Error()).Error("Failed to notify.")
+					}
+				}
+				continue
+			}
 			if err != nil {
 				q.logger.WithFields(logrus.Fields{"error": err.Error(), "payout-cycle": payout.cycle}).Error("Failed to execute payout in queue.")
 				q.logger.WithField("payout-cycle", payout.cycle).Info("Adding payout back in queue.")
@@ -89,7 +99,7 @@ func (q *Queue) Start() {
 			q.logger.WithField("payout-cycle", payout.cycle).Info("Payout successfully executed.")
 
 			if q.notifier != nil {
-				err = q.notifier.Notify(fmt.Sprintf("[TZPAY] payout for cycle %d: \n%s\n #tezos #blockchain", payout.cycle, rewardsSplit.OperationLink))
+				err = q.notifier.Notify(notifier.PayoutMessage(payout.cycle, rewardsSplit))
 				if err != nil {
 					q.logger.WithField("error", err.Error()).Error("Failed to notify.")
 				}
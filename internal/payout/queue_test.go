@@ -57,7 +57,7 @@ func Test_Start(t *testing.T) {
 						constructPayoutFunc: func() (tzkt.RewardsSplit, error) {
 							return tzkt.RewardsSplit{Cycle: 10}, nil
 						},
-						applyFunc: func(delegators tzkt.Delegators) ([]string, error) {
+						applyFunc: func(rewardsSplit tzkt.RewardsSplit) ([]string, error) {
 							return []string{}, nil
 						},
 					},
@@ -66,7 +66,7 @@ func Test_Start(t *testing.T) {
 						constructPayoutFunc: func() (tzkt.RewardsSplit, error) {
 							return tzkt.RewardsSplit{Cycle: 11}, nil
 						},
-						applyFunc: func(delegators tzkt.Delegators) ([]string, error) {
+						applyFunc: func(rewardsSplit tzkt.RewardsSplit) ([]string, error) {
 							return []string{}, nil
 						},
 					},
@@ -75,7 +75,7 @@ func Test_Start(t *testing.T) {
 						constructPayoutFunc: func() (tzkt.RewardsSplit, error) {
 							return tzkt.RewardsSplit{Cycle: 12}, nil
 						},
-						applyFunc: func(delegators tzkt.Delegators) ([]string, error) {
+						applyFunc: func(rewardsSplit tzkt.RewardsSplit) ([]string, error) {
 							return []string{}, nil
 						},
 					},
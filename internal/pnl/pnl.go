@@ -0,0 +1,271 @@
+package pnl
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/failover"
+	"github.com/goat-systems/tzpay/v3/internal/reconcile"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+/*
+Statement is the baker's profit and loss for a cycle in mutez. Income is the baker's rewards for its own
+stake, the fees collected from delegators, the rewards of skipped delegators that the baker keeps (those
+carried over are still owed), the accusation rewards, block fees and dust that aren't shared and the losses
+deducted from what's shared. Expenses are the missed and uncovered rewards the baker covers for delegators,
+the network fees and burns of the payout transfers (less those deducted from delegators' rewards) and the
+losses of double baking, double endorsing and missed revelations.
+*/
+type Statement struct {
+	Cycle                 int  `json:"cycle"`
+	OwnStakeRewards       int  `json:"own_stake_rewards"`
+	CollectedFees         int  `json:"collected_fees"`
+	RetainedRewards       int  `json:"retained_rewards"`
+	AccusationRewards     int  `json:"accusation_rewards"`
+	KeptBlockFees         int  `json:"kept_block_fees"`
+	DeductedLosses        int  `json:"deducted_losses"`
+	Dust                  int  `json:"dust"`
+	CoveredMissedRewards  int  `json:"covered_missed_rewards"`
+	NetworkFees           int  `json:"network_fees"`
+	BurnFees              int  `json:"burn_fees"`
+	DoubleBakingLosses    int  `json:"double_baking_losses"`
+	DoubleEndorsingLosses int  `json:"double_endorsing_losses"`
+	RevelationLosses      int  `json:"revelation_losses"`
+	NetProfit             int  `json:"net_profit"`
+	Estimated             bool `json:"estimated,omitempty"`
+}
+
+// Report is the baker's profit and loss for a range of cycles under its accounting policy
+type Report struct {
+	Baker      string      `json:"baker"`
+	Accounting string      `json:"accounting"`
+	FromCycle  int         `json:"from_cycle"`
+	ToCycle    int         `json:"to_cycle"`
+	Statements []Statement `json:"statements"`
+	Total      Statement   `json:"total"`
+}
+
+// NewPayout returns the payout tzpay computes for cycle, which must not inject
+type NewPayout func(cycle int) (reconcile.Payout, error)
+
+// PnL computes the baker's profit and loss for a range of cycles
+type PnL struct {
+	config    config.Config
+	rpc       rpc.IFace
+	tzkt      tzkt.IFace
+	fromCycle int
+	toCycle   int
+	newPayout NewPayout
+}
+
+// New returns a pointer to a new PnL for the cycles from fromCycle to toCycle
+func New(config config.Config, fromCycle, toCycle int, newPayout NewPayout) (*PnL, error) {
+	if fromCycle > toCycle {
+		return nil, errors.Errorf("invalid cycle range: %d is after %d", fromCycle, toCycle)
+	}
+
+	r, err := failover.NewRPC(config.API)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize tezos rpc client")
+	}
+
+	return &PnL{
+		config:    config,
+		rpc:       r,
+		tzkt:      failover.NewTZKT(config.API),
+		fromCycle: fromCycle,
+		toCycle:   toCycle,
+		newPayout: newPayout,
+	}, nil
+}
+
+// ParseCycles parses a cycle (e.g. 270) or an inclusive range of cycles (e.g. 270-275)
+func ParseCycles(arg string) (int, int, error) {
+	bounds := strings.SplitN(arg, "-", 2)
+
+	fromCycle, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to parse cycles '%s'", arg)
+	}
+
+	if len(bounds) == 1 {
+		return fromCycle, fromCycle, nil
+	}
+
+	toCycle, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to parse cycles '%s'", arg)
+	}
+
+	if fromCycle > toCycle {
+		return 0, 0, errors.Errorf("failed to parse cycles '%s': %d is after %d", arg, fromCycle, toCycle)
+	}
+
+	return fromCycle, toCycle, nil
+}
+
+/*
+Execute computes the payout of every cycle in the range and matches it with the transfers made on chain
+during the cycle it's paid out in to find the network fees and burns spent. If a payout isn't found on chain
+its network fees are estimated from the configured network fee.
+*/
+func (p *PnL) Execute() (Report, error) {
+	report := Report{
+		Baker:      p.config.Baker.Address,
+		Accounting: p.config.Baker.Accounting().String(),
+		FromCycle:  p.fromCycle,
+		ToCycle:    p.toCycle,
+	}
+
+	head, err := p.rpc.Head()
+	if err != nil {
+		return report, errors.Wrap(err, "failed to compute profit and loss")
+	}
+
+	constants, err := p.rpc.Constants(head.Hash)
+	if err != nil {
+		return report, errors.Wrap(err, "failed to compute profit and loss")
+	}
+
+	for cycle := p.fromCycle; cycle <= p.toCycle; cycle++ {
+		payout, err := p.newPayout(cycle)
+		if err != nil {
+			return report, errors.Wrapf(err, "failed to compute profit and loss for cycle %d", cycle)
+		}
+
+		rewardsSplit, err := payout.Execute()
+		if err != nil {
+			return report, errors.Wrapf(err, "failed to compute profit and loss for cycle %d", cycle)
+		}
+
+		fromLevel, toLevel, err := reconcile.Window(p.config, constants, p.tzkt, cycle)
+		if err != nil {
+			return report, errors.Wrapf(err, "failed to compute profit and loss for cycle %d", cycle)
+		}
+
+		reconciler, err := reconcile.New(p.config, cycle, &reconcile.StaticPayout{RewardsSplit: rewardsSplit}, fromLevel, toLevel)
+		if err != nil {
+			return report, errors.Wrapf(err, "failed to compute profit and loss for cycle %d", cycle)
+		}
+
+		reconciliation, err := reconciler.Execute()
+		if err != nil {
+			return report, errors.Wrapf(err, "failed to compute profit and loss for cycle %d", cycle)
+		}
+
+		statement := p.statement(rewardsSplit, reconciliation)
+		report.Statements = append(report.Statements, statement)
+		report.Total = add(report.Total, statement)
+	}
+
+	return report, nil
+}
+
+func (p *PnL) statement(rewardsSplit tzkt.RewardsSplit, reconciliation reconcile.Report) Statement {
+	accounting := p.config.Baker.Accounting().Apply(rewardsSplit)
+
+	statement := Statement{
+		Cycle:                 rewardsSplit.Cycle,
+		OwnStakeRewards:       rewardsSplit.BakerRewards,
+		CollectedFees:         rewardsSplit.BakerCollectedFees,
+		AccusationRewards:     rewardsSplit.DoubleBakingRewards + rewardsSplit.DoubleEndorsingRewards - accounting.Accusations,
+		KeptBlockFees:         accounting.KeptFees,
+		DeductedLosses:        accounting.Deducted,
+		Dust:                  rewardsSplit.Dust,
+		CoveredMissedRewards:  accounting.Covered,
+		DoubleBakingLosses:    rewardsSplit.DoubleBakingLostDeposits + rewardsSplit.DoubleBakingLostRewards + rewardsSplit.DoubleBakingLostFees,
+		DoubleEndorsingLosses: rewardsSplit.DoubleEndorsingLostDeposits + rewardsSplit.DoubleEndorsingLostRewards + rewardsSplit.DoubleEndorsingLostFees,
+		RevelationLosses:      rewardsSplit.RevelationLostRewards + rewardsSplit.RevelationLostFees,
+	}
+
+	var payments, deductedBurnFees, deductedNetworkFees int
+	count := func(netRewards, burnFee, networkFee int, status tzkt.Status) {
+		switch {
+		case status.Owed(): // still owed to the delegator
+		case status.Skipped():
+			statement.RetainedRewards += netRewards
+		case networkFee > 0: // the delegator pays the transfer's fee
+			deductedBurnFees += burnFee
+			deductedNetworkFees += networkFee
+		default:
+			payments++
+			deductedBurnFees += burnFee
+		}
+	}
+
+	for _, delegator := range rewardsSplit.Delegators {
+		if delegator.LiquidityProviders == nil {
+			count(delegator.NetRewards, delegator.BurnFee, delegator.NetworkFee, delegator.Status)
+			continue
+		}
+
+		for _, liquidityProvider := range delegator.LiquidityProviders {
+			count(liquidityProvider.NetRewards, liquidityProvider.BurnFee, liquidityProvider.NetworkFee, liquidityProvider.Status)
+		}
+	}
+
+	if skipped := rewardsSplit.SkippedRewards; skipped != nil && skipped.Policy != config.KeepSkippedRewards {
+		statement.RetainedRewards -= skipped.Amount
+		if skipped.Policy == config.SendSkippedRewards {
+			payments++
+		}
+	}
+
+	var transfers int
+	for _, entry := range reconciliation.Entries {
+		for _, transfer := range entry.Transfers {
+			statement.NetworkFees += transfer.Fee
+			statement.BurnFees += transfer.Burn
+			transfers++
+		}
+	}
+
+	if transfers == 0 && payments > 0 {
+		statement.NetworkFees = payments * p.config.Operations.NetworkFee
+		statement.Estimated = true
+	} else {
+		// burns and network fees deducted from the payments were paid by the delegators
+		statement.BurnFees -= deductedBurnFees
+		statement.NetworkFees -= deductedNetworkFees
+	}
+
+	statement.NetProfit = statement.OwnStakeRewards +
+		statement.CollectedFees +
+		statement.RetainedRewards +
+		statement.AccusationRewards +
+		statement.KeptBlockFees +
+		statement.DeductedLosses +
+		statement.Dust -
+		statement.CoveredMissedRewards -
+		statement.NetworkFees -
+		statement.BurnFees -
+		statement.DoubleBakingLosses -
+		statement.DoubleEndorsingLosses -
+		statement.RevelationLosses
+
+	return statement
+}
+
+func add(total, statement Statement) Statement {
+	total.OwnStakeRewards += statement.OwnStakeRewards
+	total.CollectedFees += statement.CollectedFees
+	total.RetainedRewards += statement.RetainedRewards
+	total.AccusationRewards += statement.AccusationRewards
+	total.KeptBlockFees += statement.KeptBlockFees
+	total.DeductedLosses += statement.DeductedLosses
+	total.Dust += statement.Dust
+	total.CoveredMissedRewards += statement.CoveredMissedRewards
+	total.NetworkFees += statement.NetworkFees
+	total.BurnFees += statement.BurnFees
+	total.DoubleBakingLosses += statement.DoubleBakingLosses
+	total.DoubleEndorsingLosses += statement.DoubleEndorsingLosses
+	total.RevelationLosses += statement.RevelationLosses
+	total.NetProfit += statement.NetProfit
+	total.Estimated = total.Estimated || statement.Estimated
+
+	return total
+}
@@ -0,0 +1,257 @@
+package pnl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/reconcile"
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseCycles(t *testing.T) {
+	type want struct {
+		err       bool
+		contains  string
+		fromCycle int
+		toCycle   int
+	}
+
+	cases := []struct {
+		name  string
+		input string
+		want  want
+	}{
+		{"is successful with cycle", "270", want{false, "", 270, 270}},
+		{"is successful with range", "270-275", want{false, "", 270, 275}},
+		{"is successful with spaces", "270 - 275", want{false, "", 270, 275}},
+		{"handles bad cycle", "abc", want{true, "failed to parse cycles 'abc'", 0, 0}},
+		{"handles bad range", "270-", want{true, "failed to parse cycles '270-'", 0, 0}},
+		{"handles reversed range", "275-270", want{true, "275 is after 270", 0, 0}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			fromCycle, toCycle, err := ParseCycles(tt.input)
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			assert.Equal(t, tt.want.fromCycle, fromCycle)
+			assert.Equal(t, tt.want.toCycle, toCycle)
+		})
+	}
+}
+
+type payoutMock struct {
+	rewardsSplit tzkt.RewardsSplit
+	err          error
+}
+
+func (p *payoutMock) Execute() (tzkt.RewardsSplit, error) {
+	return p.rewardsSplit, p.err
+}
+
+func Test_Execute(t *testing.T) {
+	const source = "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo"
+
+	transfer := func(id int, target string, amount, fee, burn int) tzkt.Transaction {
+		var transaction tzkt.Transaction
+		transaction.ID = id
+		transaction.Level = 1110100
+		transaction.Hash = "oo1"
+		transaction.Sender.Address = source
+		transaction.Target.Address = target
+		transaction.Amount = amount
+		transaction.BakerFee = fee
+		transaction.AllocationFee = burn
+		transaction.Status = "applied"
+		return transaction
+	}
+
+	rewardsSplit := func(cycle int) tzkt.RewardsSplit {
+		return tzkt.RewardsSplit{
+			Cycle:                    cycle,
+			MissedEndorsementRewards: 1250000,
+			DoubleBakingRewards:      500000,
+			DoubleBakingLostDeposits: 2000000,
+			RevelationLostRewards:    125000,
+			BakerRewards:             111605791,
+			BakerCollectedFees:       7032891,
+			Dust:                     3,
+			Delegators: tzkt.Delegators{
+				{Address: "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", NetRewards: 34665260},
+				{Address: "KT1FPyY6mAhnzyVGP8ApGvuRyF7SKcT9TDWy", NetRewards: 34395939, Status: tzkt.Blacklisted},
+				{Address: "tz1PB27kbPL64MWYoNZAfQAEmzCZFi9EvgBw", NetRewards: 103, Status: tzkt.CarriedOver}, // still owed, not retained
+				{
+					Address: "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
+					LiquidityProviders: []tzkt.LiquidityProvider{
+						{Address: "tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV", NetRewards: 20938916},
+						{Address: "tz1Ykmc29JfQvWnjWRPYTPUZBLW4gwa9YKUD", NetRewards: 11960158, Status: tzkt.Blacklisted},
+					},
+				},
+			},
+		}
+	}
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+
+	// only cycle 270 was paid out, during cycle 271
+	indexer := test.NewTzkt(test.TzktFixture{
+		Transactions: []tzkt.Transaction{
+			transfer(1, "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", 34665260, 2941, 0),
+			transfer(2, "tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV", 20938916, 2941, 257000),
+		},
+	}, node)
+	defer indexer.Close()
+
+	cfg := config.Config{
+		API: config.API{
+			TZKT:  indexer.URL,
+			Tezos: node.URL,
+		},
+		Baker: config.Baker{
+			Address: "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+		},
+		Key: config.Key{
+			Esk:      "edesk1fddn27MaLcQVEdZpAYiyGQNm6UjtWiBfNP2ZenTy3CFsoSVJgeHM9pP9cvLJ2r5Xp2quQ5mYexW1LRKee2",
+			Password: "password12345##",
+		},
+		Operations: config.Operations{
+			NetworkFee: 3000,
+		},
+	}
+
+	statement := func(cycle, networkFees, burnFees int, estimated bool) Statement {
+		s := Statement{
+			Cycle:                cycle,
+			OwnStakeRewards:      111605791,
+			CollectedFees:        7032891,
+			RetainedRewards:      34395939 + 11960158,
+			AccusationRewards:    500000,
+			Dust:                 3,
+			CoveredMissedRewards: 1250000,
+			NetworkFees:          networkFees,
+			BurnFees:             burnFees,
+			DoubleBakingLosses:   2000000,
+			RevelationLosses:     125000,
+			Estimated:            estimated,
+		}
+		s.NetProfit = 111605791 + 7032891 + 34395939 + 11960158 + 500000 + 3 - 1250000 - networkFees - burnFees - 2000000 - 125000
+		return s
+	}
+
+	p, err := New(cfg, 270, 271, func(cycle int) (reconcile.Payout, error) {
+		return &payoutMock{rewardsSplit: rewardsSplit(cycle)}, nil
+	})
+	assert.Nil(t, err)
+
+	report, err := p.Execute()
+	assert.Nil(t, err)
+
+	paid, estimated := statement(270, 2941*2, 257000, false), statement(271, 3000*2, 0, true)
+	assert.Equal(t, Report{
+		Baker:      "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+		Accounting: "rewards=missed block_fees=shared losses=baker accusations=kept",
+		FromCycle:  270,
+		ToCycle:    271,
+		Statements: []Statement{paid, estimated},
+		Total: Statement{
+			OwnStakeRewards:      111605791 * 2,
+			CollectedFees:        7032891 * 2,
+			RetainedRewards:      (34395939 + 11960158) * 2,
+			AccusationRewards:    500000 * 2,
+			Dust:                 3 * 2,
+			CoveredMissedRewards: 1250000 * 2,
+			NetworkFees:          2941*2 + 3000*2,
+			BurnFees:             257000,
+			DoubleBakingLosses:   2000000 * 2,
+			RevelationLosses:     125000 * 2,
+			NetProfit:            paid.NetProfit + estimated.NetProfit,
+			Estimated:            true,
+		},
+	}, report)
+
+	cfg.Baker.EarningsOnly = true
+	p, err = New(cfg, 270, 270, func(cycle int) (reconcile.Payout, error) {
+		return &payoutMock{rewardsSplit: rewardsSplit(cycle)}, nil
+	})
+	assert.Nil(t, err)
+
+	report, err = p.Execute()
+	assert.Nil(t, err)
+	assert.Zero(t, report.Total.CoveredMissedRewards)
+	assert.Equal(t, paid.NetProfit+1250000, report.Total.NetProfit)
+
+	// what the accounting policy doesn't share or deducts from what's shared is the baker's
+	policy := cfg
+	policy.Baker.Rewards = config.IdealRewards
+	policy.Baker.KeepBlockFees = true
+	policy.Baker.Losses = config.DeductLostRewards
+	policy.Baker.ShareAccusations = true
+	p, err = New(policy, 270, 270, func(cycle int) (reconcile.Payout, error) {
+		split := rewardsSplit(cycle)
+		split.OwnBlockFees = 40000
+		split.UncoveredOwnBlockRewards = 40000000
+		return &payoutMock{rewardsSplit: split}, nil
+	})
+	assert.Nil(t, err)
+
+	report, err = p.Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, "rewards=ideal block_fees=kept losses=rewards accusations=shared", report.Accounting)
+	assert.Zero(t, report.Total.AccusationRewards)
+	assert.Equal(t, 40000, report.Total.KeptBlockFees)
+	assert.Equal(t, 125000, report.Total.DeductedLosses)
+	assert.Equal(t, 1250000+40000000, report.Total.CoveredMissedRewards)
+	assert.Equal(t, paid.NetProfit-500000+40000+125000-40000000, report.Total.NetProfit)
+
+	// burns deducted from delegators' rewards aren't the baker's expense
+	p, err = New(cfg, 270, 270, func(cycle int) (reconcile.Payout, error) {
+		split := rewardsSplit(cycle)
+		split.Delegators[3].LiquidityProviders[0].BurnFee = 257000
+		return &payoutMock{rewardsSplit: split}, nil
+	})
+	assert.Nil(t, err)
+
+	report, err = p.Execute()
+	assert.Nil(t, err)
+	assert.Zero(t, report.Total.BurnFees)
+
+	// network fees deducted from delegators' rewards aren't the baker's expense either
+	p, err = New(cfg, 270, 270, func(cycle int) (reconcile.Payout, error) {
+		split := rewardsSplit(cycle)
+		split.Delegators[0].NetworkFee = 2941
+		split.Delegators[3].LiquidityProviders[0].NetworkFee = 2941
+		return &payoutMock{rewardsSplit: split}, nil
+	})
+	assert.Nil(t, err)
+
+	report, err = p.Execute()
+	assert.Nil(t, err)
+	assert.Zero(t, report.Total.NetworkFees)
+
+	// skipped rewards redistributed to delegators aren't retained
+	p, err = New(cfg, 270, 270, func(cycle int) (reconcile.Payout, error) {
+		split := rewardsSplit(cycle)
+		split.SkippedRewards = &tzkt.Skipped{Policy: config.RedistributeSkippedRewards, Amount: 34395939 + 11960158}
+		return &payoutMock{rewardsSplit: split}, nil
+	})
+	assert.Nil(t, err)
+
+	report, err = p.Execute()
+	assert.Nil(t, err)
+	assert.Zero(t, report.Total.RetainedRewards)
+
+	p, err = New(cfg, 270, 270, func(cycle int) (reconcile.Payout, error) {
+		return &payoutMock{err: errors.New("failed to construct")}, nil
+	})
+	assert.Nil(t, err)
+
+	_, err = p.Execute()
+	test.CheckErr(t, true, "failed to compute profit and loss for cycle 270: failed to construct", err)
+
+	_, err = New(cfg, 271, 270, nil)
+	test.CheckErr(t, true, "invalid cycle range", err)
+}
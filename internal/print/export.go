@@ -0,0 +1,476 @@
+package print
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+// Export formats
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+)
+
+// Row kinds
+const (
+	DelegatorRow         = "delegator"
+	LiquidityProviderRow = "liquidity_provider"
+	SkippedRewardsRow    = "skipped_rewards"
+	SummaryRow           = "summary"
+)
+
+// Row statuses of paid lines, skipped lines have the reason they were skipped (e.g. below_minimum) as status
+const (
+	PaidStatus    = "paid"
+	PendingStatus = "pending"
+)
+
+// Row is a single line of an accounting export
+type Row struct {
+	Cycle         int       `json:"cycle"`
+	Kind          string    `json:"kind"`
+	Address       string    `json:"address"`
+	Contract      string    `json:"contract,omitempty"`
+	Balance       int       `json:"balance"`
+	Share         float64   `json:"share"`
+	Gross         int       `json:"gross"`
+	Fee           int       `json:"fee"`
+	Net           int       `json:"net"`
+	CarriedOver   int       `json:"carried_over,omitempty"`
+	Redistributed int       `json:"redistributed,omitempty"`
+	BurnFee       int       `json:"burn_fee,omitempty"`
+	NetworkFee    int       `json:"network_fee,omitempty"`
+	Pending       int       `json:"pending,omitempty"`
+	Status        string    `json:"status"`
+	OperationHash string    `json:"operation_hash,omitempty"`
+	Accounting    string    `json:"accounting,omitempty"`
+	CycleEnd      tzkt.Fiat `json:"fiat_cycle_end,omitempty"`
+	Injection     tzkt.Fiat `json:"fiat_injection,omitempty"`
+}
+
+var header = []string{"cycle", "kind", "address", "contract", "balance", "share", "gross", "fee", "net", "carried_over", "redistributed", "burn_fee", "network_fee", "pending", "status", "operation_hash", "accounting"}
+
+/*
+Rows turns a payout into a row per delegator and liquidity provider, a row for the skipped rewards if they're
+sent to an address, and a summary row for the baker. The summary holds the staking balance, the baker's share
+and rewards, the fees collected, as net, the total paid out including rewards carried over from earlier cycles
+and redistributed from skipped delegators less the burns and network fees deducted and, as pending, the total
+still owed, and the accounting policy the payout was computed under. Lines are valued in fiat as the payout was.
+*/
+func Rows(delegate string, rewards tzkt.RewardsSplit) []Row {
+	var rows []Row
+	summary := Row{
+		Cycle:   rewards.Cycle,
+		Kind:    SummaryRow,
+		Address: delegate,
+		Balance: rewards.StakingBalance,
+		Share:   rewards.BakerShare,
+		Gross:   rewards.BakerRewards,
+		Fee:     rewards.BakerCollectedFees,
+		Status:  PendingStatus,
+	}
+	if rewards.Accounting != nil {
+		summary.Accounting = rewards.Accounting.Policy
+	}
+
+	line := func(row Row, status tzkt.Status, valuation *tzkt.Valuation) {
+		row.Cycle = rewards.Cycle
+		switch {
+		case status.Skipped():
+			row.Status = string(status)
+		case row.OperationHash != "":
+			row.Status = PaidStatus
+		default:
+			row.Status = PendingStatus
+		}
+
+		if !status.Skipped() {
+			if valuation != nil {
+				row.CycleEnd, row.Injection = valuation.CycleEnd, valuation.Injection
+			}
+			summary.Net += row.Net + row.CarriedOver + row.Redistributed - row.BurnFee - row.NetworkFee
+		}
+		summary.Pending += row.Pending
+		rows = append(rows, row)
+	}
+
+	for _, delegator := range rewards.Delegators {
+		if delegator.LiquidityProviders != nil {
+			for _, lp := range delegator.LiquidityProviders {
+				line(Row{
+					Kind:          LiquidityProviderRow,
+					Address:       lp.Address,
+					Contract:      delegator.Address,
+					Balance:       lp.Balance,
+					Share:         lp.Share,
+					Gross:         lp.GrossRewards,
+					Fee:           lp.Fee,
+					Net:           lp.NetRewards,
+					CarriedOver:   lp.CarriedOver,
+					Redistributed: lp.Redistributed,
+					BurnFee:       lp.BurnFee,
+					NetworkFee:    lp.NetworkFee,
+					Pending:       lp.Pending(),
+					OperationHash: lp.OperationHash,
+				}, lp.Status, lp.Fiat)
+			}
+			continue
+		}
+
+		line(Row{
+			Kind:          DelegatorRow,
+			Address:       delegator.Address,
+			Balance:       delegator.Balance,
+			Share:         delegator.Share,
+			Gross:         delegator.GrossRewards,
+			Fee:           delegator.Fee,
+			Net:           delegator.NetRewards,
+			CarriedOver:   delegator.CarriedOver,
+			Redistributed: delegator.Redistributed,
+			BurnFee:       delegator.BurnFee,
+			NetworkFee:    delegator.NetworkFee,
+			Pending:       delegator.Pending(),
+			OperationHash: delegator.OperationHash,
+		}, delegator.Status, delegator.Fiat)
+	}
+
+	if skipped := rewards.SkippedRewards; skipped != nil && skipped.Address != "" {
+		line(Row{
+			Kind:          SkippedRewardsRow,
+			Address:       skipped.Address,
+			Net:           skipped.Amount,
+			OperationHash: skipped.OperationHash,
+		}, tzkt.Paid, skipped.Fiat)
+	}
+
+	var operations []string
+	for _, link := range rewards.OperationLink {
+		operations = append(operations, strings.TrimPrefix(link, "https://tzkt.io/"))
+	}
+	if len(operations) > 0 {
+		summary.Status = PaidStatus
+		summary.OperationHash = strings.Join(operations, " ")
+	}
+	if rewards.Quotes != nil {
+		summary.CycleEnd = rewards.Quotes.CycleEnd.Value(summary.Net)
+		summary.Injection = rewards.Quotes.Injection.Value(summary.Net)
+	}
+
+	return append(rows, summary)
+}
+
+// Export writes rows to path in format, or in the format matching path's extension if format is empty
+func Export(path, format string, rows []Row) error {
+	format, err := ValidateExport(path, format)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to export to '%s'", path)
+	}
+	defer file.Close()
+
+	switch format {
+	case CSV:
+		err = WriteCSV(file, rows)
+	case JSONL:
+		err = WriteJSONL(file, rows)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to export to '%s'", path)
+	}
+
+	return file.Close()
+}
+
+/*
+ValidateExport returns the format an export to path is written in, format or the one matching path's extension
+if format is empty, and an error if the format isn't supported or path's directory doesn't exist. It creates
+nothing so an export can be checked before a payout is made.
+*/
+func ValidateExport(path, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	if format != CSV && format != JSONL {
+		return format, errors.Errorf("failed to export to '%s': unsupported format '%s': must be %s or %s", path, format, CSV, JSONL)
+	}
+
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		return format, errors.Wrapf(err, "failed to export to '%s'", path)
+	}
+	if !info.IsDir() {
+		return format, errors.Errorf("failed to export to '%s': '%s' is not a directory", path, filepath.Dir(path))
+	}
+
+	return format, nil
+}
+
+// WriteCSV writes rows as csv with a header line and a column per currency rows are valued in
+func WriteCSV(w io.Writer, rows []Row) error {
+	currencies := currencies(rows)
+	columns := append([]string{}, header...)
+	for _, currency := range currencies {
+		columns = append(columns, currency+"_cycle_end", currency+"_injection")
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return errors.Wrap(err, "failed to write csv")
+	}
+
+	for _, row := range rows {
+		record := []string{
+			strconv.Itoa(row.Cycle),
+			row.Kind,
+			row.Address,
+			row.Contract,
+			strconv.Itoa(row.Balance),
+			strconv.FormatFloat(row.Share, 'f', -1, 64),
+			strconv.Itoa(row.Gross),
+			strconv.Itoa(row.Fee),
+			strconv.Itoa(row.Net),
+			strconv.Itoa(row.CarriedOver),
+			strconv.Itoa(row.Redistributed),
+			strconv.Itoa(row.BurnFee),
+			strconv.Itoa(row.NetworkFee),
+			strconv.Itoa(row.Pending),
+			row.Status,
+			row.OperationHash,
+			row.Accounting,
+		}
+		for _, currency := range currencies {
+			record = append(record, amount(row.CycleEnd, currency), amount(row.Injection, currency))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return errors.Wrap(err, "failed to write csv")
+		}
+	}
+
+	writer.Flush()
+	return errors.Wrap(writer.Error(), "failed to write csv")
+}
+
+// WriteJSONL writes rows as json lines
+func WriteJSONL(w io.Writer, rows []Row) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return errors.Wrap(err, "failed to write json lines")
+		}
+	}
+
+	return nil
+}
+
+// ReadExport reads the rows of an export at path in format, or in the format matching path's extension if format is empty
+func ReadExport(path, format string) ([]Row, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read export '%s'", path)
+	}
+	defer file.Close()
+
+	var rows []Row
+	switch format {
+	case CSV:
+		rows, err = ReadCSV(file)
+	case JSONL:
+		rows, err = ReadJSONL(file)
+	default:
+		err = fmt.Errorf("unsupported format '%s': must be %s or %s", format, CSV, JSONL)
+	}
+
+	return rows, errors.Wrapf(err, "failed to read export '%s'", path)
+}
+
+// ReadCSV reads rows written by WriteCSV. The fiat values aren't read back.
+func ReadCSV(r io.Reader) ([]Row, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read csv")
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, column := range records[0] {
+		columns[column] = i
+	}
+
+	for _, column := range header {
+		if _, ok := columns[column]; !ok {
+			return nil, errors.Errorf("failed to read csv: missing column '%s'", column)
+		}
+	}
+
+	var rows []Row
+	for _, record := range records[1:] {
+		field := func(column string) string {
+			return record[columns[column]]
+		}
+
+		var numbers []int
+		for _, column := range []string{"cycle", "balance", "gross", "fee", "net", "carried_over", "redistributed", "burn_fee", "network_fee", "pending"} {
+			number, err := strconv.Atoi(field(column))
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read csv: invalid %s", column)
+			}
+			numbers = append(numbers, number)
+		}
+
+		share, err := strconv.ParseFloat(field("share"), 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read csv: invalid share")
+		}
+
+		rows = append(rows, Row{
+			Cycle:         numbers[0],
+			Kind:          field("kind"),
+			Address:       field("address"),
+			Contract:      field("contract"),
+			Balance:       numbers[1],
+			Share:         share,
+			Gross:         numbers[2],
+			Fee:           numbers[3],
+			Net:           numbers[4],
+			CarriedOver:   numbers[5],
+			Redistributed: numbers[6],
+			BurnFee:       numbers[7],
+			NetworkFee:    numbers[8],
+			Pending:       numbers[9],
+			Status:        field("status"),
+			OperationHash: field("operation_hash"),
+			Accounting:    field("accounting"),
+		})
+	}
+
+	return rows, nil
+}
+
+// ReadJSONL reads rows written by WriteJSONL
+func ReadJSONL(r io.Reader) ([]Row, error) {
+	var rows []Row
+	decoder := json.NewDecoder(r)
+	for {
+		var row Row
+		if err := decoder.Decode(&row); err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to read json lines")
+		}
+		rows = append(rows, row)
+	}
+}
+
+/*
+RewardsSplit rebuilds the lines of the payout rows were exported from, with what every delegator, liquidity provider
+and the address sent the skipped rewards were paid or skipped for, for a payout to be reconciled against what was
+recorded when it was made. Liquidity providers are grouped under their dexter contract.
+*/
+func RewardsSplit(rows []Row) tzkt.RewardsSplit {
+	var rewardsSplit tzkt.RewardsSplit
+	contracts := map[string]int{}
+	status := func(row Row) tzkt.Status {
+		if row.Status == PaidStatus || row.Status == PendingStatus {
+			return tzkt.Paid
+		}
+		return tzkt.Status(row.Status)
+	}
+
+	for _, row := range rows {
+		rewardsSplit.Cycle = row.Cycle
+		switch row.Kind {
+		case DelegatorRow:
+			rewardsSplit.Delegators = append(rewardsSplit.Delegators, tzkt.Delegator{
+				Address:       row.Address,
+				NetRewards:    row.Net,
+				CarriedOver:   row.CarriedOver,
+				Redistributed: row.Redistributed,
+				BurnFee:       row.BurnFee,
+				NetworkFee:    row.NetworkFee,
+				Status:        status(row),
+				OperationHash: row.OperationHash,
+			})
+		case LiquidityProviderRow:
+			i, ok := contracts[row.Contract]
+			if !ok {
+				i = len(rewardsSplit.Delegators)
+				contracts[row.Contract] = i
+				rewardsSplit.Delegators = append(rewardsSplit.Delegators, tzkt.Delegator{Address: row.Contract, Status: tzkt.Redirected})
+			}
+
+			rewardsSplit.Delegators[i].LiquidityProviders = append(rewardsSplit.Delegators[i].LiquidityProviders, tzkt.LiquidityProvider{
+				Address:       row.Address,
+				NetRewards:    row.Net,
+				CarriedOver:   row.CarriedOver,
+				Redistributed: row.Redistributed,
+				BurnFee:       row.BurnFee,
+				NetworkFee:    row.NetworkFee,
+				Status:        status(row),
+				OperationHash: row.OperationHash,
+			})
+		case SkippedRewardsRow:
+			rewardsSplit.SkippedRewards = &tzkt.Skipped{
+				Policy:        config.SendSkippedRewards,
+				Amount:        row.Net,
+				Address:       row.Address,
+				OperationHash: row.OperationHash,
+			}
+		}
+	}
+
+	return rewardsSplit
+}
+
+// currencies returns the sorted currencies rows are valued in
+func currencies(rows []Row) []string {
+	seen := map[string]struct{}{}
+	for _, row := range rows {
+		for currency := range row.CycleEnd {
+			seen[currency] = struct{}{}
+		}
+		for currency := range row.Injection {
+			seen[currency] = struct{}{}
+		}
+	}
+
+	var currencies []string
+	for currency := range seen {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	return currencies
+}
+
+func amount(fiat tzkt.Fiat, currency string) string {
+	value, ok := fiat[currency]
+	if !ok {
+		return ""
+	}
+
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
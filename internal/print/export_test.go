@@ -0,0 +1,312 @@
+package print
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+var rewardsSplit = tzkt.RewardsSplit{
+	Cycle:              270,
+	StakingBalance:     740613513605,
+	BakerShare:         0.25,
+	BakerRewards:       111605791,
+	BakerCollectedFees: 3556017,
+	OperationLink:      []string{"https://tzkt.io/oo1"},
+	Accounting:         &tzkt.Accounting{Policy: "rewards=missed block_fees=shared losses=baker accusations=kept"},
+	Quotes:             &tzkt.Valuation{CycleEnd: tzkt.Fiat{"usd": 2}, Injection: tzkt.Fiat{"usd": 2.5}},
+	SkippedRewards: &tzkt.Skipped{
+		Policy:        "send",
+		Amount:        2000000,
+		Address:       "tz1fund",
+		OperationHash: "oo1",
+		Fiat:          &tzkt.Valuation{CycleEnd: tzkt.Fiat{"usd": 4}, Injection: tzkt.Fiat{"usd": 5}},
+	},
+	Delegators: tzkt.Delegators{
+		{
+			Address:       "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd",
+			Balance:       60545965782,
+			Share:         0.08175109509855863,
+			GrossRewards:  36489747,
+			Fee:           1824487,
+			NetRewards:    34665260,
+			CarriedOver:   12345,
+			NetworkFee:    2941,
+			OperationHash: "oo1",
+			Fiat:          &tzkt.Valuation{CycleEnd: tzkt.Fiat{"usd": 69.33}, Injection: tzkt.Fiat{"usd": 86.66}},
+		},
+		{
+			Address:      "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
+			Balance:      57461165021,
+			GrossRewards: 34630604,
+			Fee:          1731530,
+			NetRewards:   32899074,
+			LiquidityProviders: []tzkt.LiquidityProvider{
+				{
+					Address:       "tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV",
+					Balance:       15000000,
+					Share:         0.5,
+					GrossRewards:  22040664,
+					Fee:           1102033,
+					NetRewards:    20938631,
+					Redistributed: 1000,
+					BurnFee:       257000,
+					Fiat:          &tzkt.Valuation{CycleEnd: tzkt.Fiat{"usd": 41.88}},
+				},
+				{
+					Address:      "tz1Ykmc29JfQvWnjWRPYTPUZBLW4gwa9YKUD",
+					Balance:      8567891,
+					Share:        0.5,
+					GrossRewards: 12589940,
+					Fee:          629497,
+					NetRewards:   11960443,
+					Status:       tzkt.CarriedOver,
+					CarriedOver:  5000,
+					Fiat:         &tzkt.Valuation{CycleEnd: tzkt.Fiat{"usd": 23.92}},
+				},
+			},
+		},
+	},
+}
+
+func Test_Rows(t *testing.T) {
+	rows := Rows("tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", rewardsSplit)
+
+	assert.Equal(t, []Row{
+		{
+			Cycle:         270,
+			Kind:          DelegatorRow,
+			Address:       "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd",
+			Balance:       60545965782,
+			Share:         0.08175109509855863,
+			Gross:         36489747,
+			Fee:           1824487,
+			Net:           34665260,
+			CarriedOver:   12345,
+			NetworkFee:    2941,
+			Status:        PaidStatus,
+			OperationHash: "oo1",
+			CycleEnd:      tzkt.Fiat{"usd": 69.33},
+			Injection:     tzkt.Fiat{"usd": 86.66},
+		},
+		{
+			Cycle:         270,
+			Kind:          LiquidityProviderRow,
+			Address:       "tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV",
+			Contract:      "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
+			Balance:       15000000,
+			Share:         0.5,
+			Gross:         22040664,
+			Fee:           1102033,
+			Net:           20938631,
+			Redistributed: 1000,
+			BurnFee:       257000,
+			Status:        PendingStatus,
+			CycleEnd:      tzkt.Fiat{"usd": 41.88},
+		},
+		{
+			Cycle:       270,
+			Kind:        LiquidityProviderRow,
+			Address:     "tz1Ykmc29JfQvWnjWRPYTPUZBLW4gwa9YKUD",
+			Contract:    "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
+			Balance:     8567891,
+			Share:       0.5,
+			Gross:       12589940,
+			Fee:         629497,
+			Net:         11960443,
+			CarriedOver: 5000,
+			Pending:     11960443 + 5000,
+			Status:      string(tzkt.CarriedOver),
+		},
+		{
+			Cycle:         270,
+			Kind:          SkippedRewardsRow,
+			Address:       "tz1fund",
+			Net:           2000000,
+			Status:        PaidStatus,
+			OperationHash: "oo1",
+			CycleEnd:      tzkt.Fiat{"usd": 4},
+			Injection:     tzkt.Fiat{"usd": 5},
+		},
+		{
+			Cycle:         270,
+			Kind:          SummaryRow,
+			Address:       "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc",
+			Balance:       740613513605,
+			Share:         0.25,
+			Gross:         111605791,
+			Fee:           3556017,
+			Net:           34665260 + 12345 - 2941 + 20938631 + 1000 - 257000 + 2000000,
+			Pending:       11960443 + 5000,
+			Status:        PaidStatus,
+			OperationHash: "oo1",
+			Accounting:    "rewards=missed block_fees=shared losses=baker accusations=kept",
+			CycleEnd:      tzkt.Fiat{"usd": 114.71},
+			Injection:     tzkt.Fiat{"usd": 143.39},
+		},
+	}, rows)
+}
+
+func Test_WriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	rows := Rows("tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", rewardsSplit)
+	rows[0].CycleEnd = tzkt.Fiat{"eur": 58.94, "usd": 69.33}
+	err := WriteCSV(&buf, rows)
+	assert.Nil(t, err)
+	assert.Equal(t, `cycle,kind,address,contract,balance,share,gross,fee,net,carried_over,redistributed,burn_fee,network_fee,pending,status,operation_hash,accounting,eur_cycle_end,eur_injection,usd_cycle_end,usd_injection
+270,delegator,tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd,,60545965782,0.08175109509855863,36489747,1824487,34665260,12345,0,0,2941,0,paid,oo1,,58.94,,69.33,86.66
+270,liquidity_provider,tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV,KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv,15000000,0.5,22040664,1102033,20938631,0,1000,257000,0,0,pending,,,,,41.88,
+270,liquidity_provider,tz1Ykmc29JfQvWnjWRPYTPUZBLW4gwa9YKUD,KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv,8567891,0.5,12589940,629497,11960443,5000,0,0,0,11965443,carried_over,,,,,,
+270,skipped_rewards,tz1fund,,0,0,0,0,2000000,0,0,0,0,0,paid,oo1,,,,4.00,5.00
+270,summary,tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc,,740613513605,0.25,111605791,3556017,57357295,0,0,0,0,11965443,paid,oo1,rewards=missed block_fees=shared losses=baker accusations=kept,,,114.71,143.39
+`, buf.String())
+}
+
+func Test_WriteJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteJSONL(&buf, []Row{
+		{Cycle: 270, Kind: DelegatorRow, Address: "tz1a", Net: 1000000, Status: PaidStatus, OperationHash: "oo1", CycleEnd: tzkt.Fiat{"usd": 2}, Injection: tzkt.Fiat{"usd": 2.5}},
+		{Cycle: 270, Kind: SummaryRow, Address: "tz1b", Net: 1000000, Status: PaidStatus},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"cycle":270,"kind":"delegator","address":"tz1a","balance":0,"share":0,"gross":0,"fee":0,"net":1000000,"status":"paid","operation_hash":"oo1","fiat_cycle_end":{"usd":2},"fiat_injection":{"usd":2.5}}
+{"cycle":270,"kind":"summary","address":"tz1b","balance":0,"share":0,"gross":0,"fee":0,"net":1000000,"status":"paid"}
+`, buf.String())
+}
+
+func Test_Export(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tzpay")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name     string
+		path     string
+		format   string
+		err      bool
+		contains string
+	}{
+		{"is successful with csv extension", filepath.Join(dir, "payout.csv"), "", false, ""},
+		{"is successful with jsonl extension", filepath.Join(dir, "payout.jsonl"), "", false, ""},
+		{"is successful with explicit format", filepath.Join(dir, "payout.txt"), JSONL, false, ""},
+		{"handles unsupported format", filepath.Join(dir, "payout.ofx"), "", true, "unsupported format 'ofx'"},
+		{"handles bad path", filepath.Join(dir, "missing", "payout.csv"), "", true, "failed to export"},
+	}
+
+	rows := Rows("tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", rewardsSplit)
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Export(tt.path, tt.format, rows)
+			test.CheckErr(t, tt.err, tt.contains, err)
+			if !tt.err {
+				byts, err := ioutil.ReadFile(tt.path)
+				assert.Nil(t, err)
+				assert.Contains(t, string(byts), "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd")
+			} else {
+				_, err := os.Stat(tt.path)
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+func Test_ValidateExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tzpay")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+	assert.Nil(t, ioutil.WriteFile(file, []byte{}, 0644))
+
+	cases := []struct {
+		name     string
+		path     string
+		format   string
+		want     string
+		err      bool
+		contains string
+	}{
+		{"is successful with extension", filepath.Join(dir, "payout.csv"), "", CSV, false, ""},
+		{"is successful with explicit format", filepath.Join(dir, "payout.txt"), JSONL, JSONL, false, ""},
+		{"handles unsupported format", filepath.Join(dir, "payout.csv"), "ofx", "ofx", true, "unsupported format 'ofx'"},
+		{"handles missing directory", filepath.Join(dir, "missing", "payout.csv"), "", CSV, true, "failed to export"},
+		{"handles file as directory", filepath.Join(file, "payout.csv"), "", CSV, true, "is not a directory"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := ValidateExport(tt.path, tt.format)
+			test.CheckErr(t, tt.err, tt.contains, err)
+			assert.Equal(t, tt.want, format)
+			_, err = os.Stat(tt.path)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func Test_ReadExport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tzpay")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	rows := Rows("tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", rewardsSplit)
+	for _, path := range []string{filepath.Join(dir, "payout.csv"), filepath.Join(dir, "payout.jsonl")} {
+		assert.Nil(t, Export(path, "", rows))
+	}
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "bad.csv"), []byte("cycle,kind,address\n270,delegator,tz1a\n"), 0644))
+
+	cases := []struct {
+		name     string
+		path     string
+		err      bool
+		contains string
+	}{
+		{"is successful with csv", filepath.Join(dir, "payout.csv"), false, ""},
+		{"is successful with jsonl", filepath.Join(dir, "payout.jsonl"), false, ""},
+		{"handles missing columns", filepath.Join(dir, "bad.csv"), true, "missing column 'contract'"},
+		{"handles missing file", filepath.Join(dir, "missing.csv"), true, "failed to read export"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			read, err := ReadExport(tt.path, "")
+			test.CheckErr(t, tt.err, tt.contains, err)
+			if !tt.err {
+				assert.Len(t, read, len(rows))
+				assert.Equal(t, tzkt.RewardsSplit{
+					Cycle: 270,
+					SkippedRewards: &tzkt.Skipped{
+						Policy:        "send",
+						Amount:        2000000,
+						Address:       "tz1fund",
+						OperationHash: "oo1",
+					},
+					Delegators: tzkt.Delegators{
+						{
+							Address:       "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd",
+							NetRewards:    34665260,
+							CarriedOver:   12345,
+							NetworkFee:    2941,
+							Status:        tzkt.Paid,
+							OperationHash: "oo1",
+						},
+						{
+							Address: "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
+							Status:  tzkt.Redirected,
+							LiquidityProviders: []tzkt.LiquidityProvider{
+								{Address: "tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV", NetRewards: 20938631, Redistributed: 1000, BurnFee: 257000, Status: tzkt.Paid},
+								{Address: "tz1Ykmc29JfQvWnjWRPYTPUZBLW4gwa9YKUD", NetRewards: 11960443, CarriedOver: 5000, Status: tzkt.CarriedOver},
+							},
+						},
+					},
+				}, RewardsSplit(read))
+			}
+		})
+	}
+}
@@ -4,51 +4,119 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
 	gotezos "github.com/goat-systems/go-tezos/v2"
+	"github.com/goat-systems/tzpay/v3/internal/pnl"
+	"github.com/goat-systems/tzpay/v3/internal/reconcile"
+	"github.com/goat-systems/tzpay/v3/internal/trueup"
 	"github.com/goat-systems/tzpay/v3/internal/tzkt"
 	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-// Table prints a payout in table format
+/*
+Table prints a payout in table format. Totals are also shown in every currency the payout is valued in,
+at the price when it was paid or, if it hasn't been, at the end of the cycle. If rewards are carried over,
+the rewards carried over from earlier cycles and the balance still owed are shown for every delegation, and
+if the rewards of skipped delegations are redistributed, the rewards redistributed to it. Burns for allocating
+empty accounts and network fees deducted from payments are shown as well, as is what the accounting policy
+distributed.
+*/
 func Table(cycle int, delegate string, rewards tzkt.RewardsSplit) {
+	currencies := fiatCurrencies(rewards.Quotes)
+
+	var total *tzkt.Valuation
+	if rewards.BakerRewardsFiat != nil && rewards.BakerCollectedFeesFiat != nil {
+		total = &tzkt.Valuation{
+			CycleEnd:  sum(rewards.BakerRewardsFiat.CycleEnd, rewards.BakerCollectedFeesFiat.CycleEnd),
+			Injection: sum(rewards.BakerRewardsFiat.Injection, rewards.BakerCollectedFeesFiat.Injection),
+		}
+	}
+
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Cylce", "Baker", "Share", "Rewards", "Fees", "Total", "Operations"})
-	table.Append([]string{
+	table.SetHeader(append(append([]string{"Cylce", "Baker", "Share", "Rewards", "Fees", "Total"}, fiatHeader("Total", currencies)...), "Operations"))
+	table.Append(append(append([]string{
 		strconv.Itoa(cycle),
 		delegate,
 		fmt.Sprintf("%.6f", rewards.BakerShare),
 		fmt.Sprintf("%.6f", float64(rewards.BakerRewards)/float64(gotezos.MUTEZ)),
 		fmt.Sprintf("%.6f", float64(rewards.BakerCollectedFees)/float64(gotezos.MUTEZ)),
 		fmt.Sprintf("%.6f", float64(rewards.BakerRewards+rewards.BakerCollectedFees)/float64(gotezos.MUTEZ)),
-		groomOperations(rewards.OperationLink...),
-	})
+	}, fiatValues(preferred(total), currencies)...), groomOperations(rewards.OperationLink...)))
 
 	table.Render()
 
+	if a := rewards.Accounting; a != nil {
+		table = tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Model", "Accounting", "Earned", "Covered", "Accusations", "Deducted", "Kept Fees", "Distributed"})
+		table.Append([]string{rewards.Model, a.Policy, tez(a.Earned), tez(a.Covered), tez(a.Accusations), tez(-a.Deducted), tez(a.KeptFees), tez(a.Total)})
+		table.Render()
+	}
+
+	carryOver := carriesOver(rewards)
+	redistribution := rewards.SkippedRewards != nil && rewards.SkippedRewards.Policy == "redistribute"
+	burnFees, networkFees := deductions(rewards)
+	adjustmentHeader := func() []string {
+		var header []string
+		if carryOver {
+			header = append(header, "Carried Over", "Pending")
+		}
+		if redistribution {
+			header = append(header, "Redistributed")
+		}
+		if burnFees {
+			header = append(header, "Burn Fee")
+		}
+		if networkFees {
+			header = append(header, "Network Fee")
+		}
+		return header
+	}
+	adjustments := func(a adjustment) []string {
+		var columns []string
+		if carryOver {
+			columns = append(columns, fmt.Sprintf("%.6f", float64(a.carriedOver)/float64(gotezos.MUTEZ)), fmt.Sprintf("%.6f", float64(a.pending)/float64(gotezos.MUTEZ)))
+		}
+		if redistribution {
+			columns = append(columns, fmt.Sprintf("%.6f", float64(a.redistributed)/float64(gotezos.MUTEZ)))
+		}
+		if burnFees {
+			columns = append(columns, fmt.Sprintf("%.6f", float64(a.burnFee)/float64(gotezos.MUTEZ)))
+		}
+		if networkFees {
+			columns = append(columns, fmt.Sprintf("%.6f", float64(a.networkFee)/float64(gotezos.MUTEZ)))
+		}
+		return columns
+	}
+
 	table = tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Delegation", "Blacklisted", "Share", "Gross", "Net", "Fee"})
+	table.SetHeader(append(append([]string{"Delegation", "Status", "Share", "Gross", "Net", "Fee"}, adjustmentHeader()...), fiatHeader("Net", currencies)...))
 
 	liquidityProviderTable := tablewriter.NewWriter(os.Stdout)
-	liquidityProviderTable.SetHeader([]string{"Liquidiy Provider", "Contract", "Blacklisted", "Share", "Gross", "Net", "Fee"})
+	liquidityProviderTable.SetHeader(append(append([]string{"Liquidiy Provider", "Contract", "Status", "Share", "Gross", "Net", "Fee"}, adjustmentHeader()...), fiatHeader("Net", currencies)...))
 
 	var net, fee, liquidityNet, liquidityFee, gross, share float64
+	var adjusted, liquidityAdjusted adjustment
+	var netFiat, liquidityNetFiat tzkt.Fiat
 
 	for _, delegation := range rewards.Delegators {
 		for _, lp := range delegation.LiquidityProviders {
-			liquidityProviderTable.Append([]string{
+			lpAdjustment := adjustment{lp.CarriedOver, lp.Pending(), lp.Redistributed, lp.BurnFee, lp.NetworkFee}
+			liquidityProviderTable.Append(append(append([]string{
 				lp.Address,
 				delegation.Address,
-				fmt.Sprintf("%v", lp.BlackListed),
+				status(lp.Status),
 				fmt.Sprintf("%.6f", lp.Share),
 				fmt.Sprintf("%.6f", float64(lp.GrossRewards)/float64(gotezos.MUTEZ)),
 				fmt.Sprintf("%.6f", float64(lp.NetRewards)/float64(gotezos.MUTEZ)),
 				fmt.Sprintf("%.6f", float64(lp.Fee)/float64(gotezos.MUTEZ)),
-			})
+			}, adjustments(lpAdjustment)...), fiatValues(preferred(lp.Fiat), currencies)...))
+			liquidityNetFiat = sum(liquidityNetFiat, preferred(lp.Fiat))
+			liquidityAdjusted = liquidityAdjusted.add(lpAdjustment)
 
 			liquidityNet += float64(lp.NetRewards) / float64(gotezos.MUTEZ)
 			liquidityFee += float64(lp.Fee) / float64(gotezos.MUTEZ)
@@ -56,26 +124,150 @@ func Table(cycle int, delegate string, rewards tzkt.RewardsSplit) {
 			share += lp.Share
 		}
 
-		table.Append([]string{
+		delegationAdjustment := adjustment{delegation.CarriedOver, delegation.Pending(), delegation.Redistributed, delegation.BurnFee, delegation.NetworkFee}
+		table.Append(append(append([]string{
 			delegation.Address,
-			fmt.Sprintf("%v", delegation.BlackListed),
+			status(delegation.Status),
 			fmt.Sprintf("%.6f", delegation.Share),
 			fmt.Sprintf("%.6f", float64(delegation.GrossRewards)/float64(gotezos.MUTEZ)),
 			fmt.Sprintf("%.6f", float64(delegation.NetRewards)/float64(gotezos.MUTEZ)),
 			fmt.Sprintf("%.6f", float64(delegation.Fee)/float64(gotezos.MUTEZ)),
-		})
+		}, adjustments(delegationAdjustment)...), fiatValues(preferred(delegation.Fiat), currencies)...))
+		netFiat = sum(netFiat, preferred(delegation.Fiat))
+		adjusted = adjusted.add(delegationAdjustment)
 		net += float64(delegation.NetRewards) / float64(gotezos.MUTEZ)
 		fee += float64(delegation.Fee) / float64(gotezos.MUTEZ)
 	}
 
-	table.SetFooter([]string{"", "", "", "TOTAL", fmt.Sprintf("%.6f", net), fmt.Sprintf("%.6f", fee)}) // Add Footer
-	liquidityProviderTable.SetFooter([]string{"", "", "TOTAL", fmt.Sprintf("%.6f", share), fmt.Sprintf("%.6f", gross), fmt.Sprintf("%.6f", liquidityNet), fmt.Sprintf("%.6f", liquidityFee)})
+	table.SetFooter(append(append([]string{"", "", "", "TOTAL", fmt.Sprintf("%.6f", net), fmt.Sprintf("%.6f", fee)}, adjustments(adjusted)...), fiatValues(netFiat, currencies)...)) // Add Footer
+	liquidityProviderTable.SetFooter(append(append([]string{"", "", "TOTAL", fmt.Sprintf("%.6f", share), fmt.Sprintf("%.6f", gross), fmt.Sprintf("%.6f", liquidityNet), fmt.Sprintf("%.6f", liquidityFee)}, adjustments(liquidityAdjusted)...), fiatValues(liquidityNetFiat, currencies)...))
 
 	table.Render()
 
 	if liquidityProviderTable.NumLines() > 0 {
 		liquidityProviderTable.Render()
 	}
+
+	if skipped := rewards.SkippedRewards; skipped != nil {
+		skippedTable := tablewriter.NewWriter(os.Stdout)
+		skippedTable.SetHeader(append(append([]string{"Skipped Rewards", "Policy", "Recipient"}, fiatHeader("Skipped", currencies)...), "Operation"))
+		skippedTable.Append(append(append([]string{
+			fmt.Sprintf("%.6f", float64(skipped.Amount)/float64(gotezos.MUTEZ)),
+			skipped.Policy,
+			skipped.Address,
+		}, fiatValues(preferred(skipped.Fiat), currencies)...), skipped.OperationHash))
+		skippedTable.Render()
+	}
+}
+
+// carriesOver returns true if any rewards of the payout are carried over, to or from other cycles
+func carriesOver(rewards tzkt.RewardsSplit) bool {
+	for _, delegation := range rewards.Delegators {
+		if delegation.CarriedOver > 0 || delegation.Status == tzkt.CarriedOver {
+			return true
+		}
+
+		for _, lp := range delegation.LiquidityProviders {
+			if lp.CarriedOver > 0 || lp.Status == tzkt.CarriedOver {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// adjustment is what's added to or deducted from the net rewards of a line
+type adjustment struct {
+	carriedOver   int
+	pending       int
+	redistributed int
+	burnFee       int
+	networkFee    int
+}
+
+func (a adjustment) add(b adjustment) adjustment {
+	return adjustment{
+		carriedOver:   a.carriedOver + b.carriedOver,
+		pending:       a.pending + b.pending,
+		redistributed: a.redistributed + b.redistributed,
+		burnFee:       a.burnFee + b.burnFee,
+		networkFee:    a.networkFee + b.networkFee,
+	}
+}
+
+// deductions returns whether burns for allocating accounts and network fees are deducted from any payment
+func deductions(rewards tzkt.RewardsSplit) (bool, bool) {
+	var burnFees, networkFees bool
+	for _, delegation := range rewards.Delegators {
+		burnFees = burnFees || delegation.BurnFee > 0
+		networkFees = networkFees || delegation.NetworkFee > 0
+
+		for _, lp := range delegation.LiquidityProviders {
+			burnFees = burnFees || lp.BurnFee > 0
+			networkFees = networkFees || lp.NetworkFee > 0
+		}
+	}
+
+	return burnFees, networkFees
+}
+
+// fiatCurrencies returns the sorted currencies a payout is valued in
+func fiatCurrencies(quotes *tzkt.Valuation) []string {
+	var currencies []string
+	for currency := range preferred(quotes) {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	return currencies
+}
+
+func fiatHeader(column string, currencies []string) []string {
+	var header []string
+	for _, currency := range currencies {
+		header = append(header, fmt.Sprintf("%s (%s)", column, strings.ToUpper(currency)))
+	}
+
+	return header
+}
+
+func fiatValues(values tzkt.Fiat, currencies []string) []string {
+	var columns []string
+	for _, currency := range currencies {
+		columns = append(columns, fmt.Sprintf("%.2f", values[currency]))
+	}
+
+	return columns
+}
+
+// preferred returns the value at injection or, if the payout hasn't been injected, at the end of the cycle
+func preferred(valuation *tzkt.Valuation) tzkt.Fiat {
+	if valuation == nil {
+		return nil
+	}
+
+	if len(valuation.Injection) > 0 {
+		return valuation.Injection
+	}
+
+	return valuation.CycleEnd
+}
+
+func sum(a, b tzkt.Fiat) tzkt.Fiat {
+	if a == nil && b == nil {
+		return nil
+	}
+
+	total := tzkt.Fiat{}
+	for currency, value := range a {
+		total[currency] += value
+	}
+	for currency, value := range b {
+		total[currency] += value
+	}
+
+	return total
 }
 
 // JSON prints a payout to json
@@ -89,6 +281,147 @@ func JSON(rewards tzkt.RewardsSplit) error {
 	return nil
 }
 
+// ReconciliationTable prints a reconciliation report in table format
+func ReconciliationTable(report reconcile.Report) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Cylce", "Payout Address", "Levels", "Expected", "Received"})
+	table.Append([]string{
+		strconv.Itoa(report.Cycle),
+		report.Source,
+		fmt.Sprintf("%d-%d", report.FromLevel, report.ToLevel),
+		fmt.Sprintf("%.6f", float64(report.Expected)/float64(gotezos.MUTEZ)),
+		fmt.Sprintf("%.6f", float64(report.Received)/float64(gotezos.MUTEZ)),
+	})
+
+	table.Render()
+
+	table = tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Address", "Status", "Expected", "Received", "Operations"})
+	for _, entry := range report.Entries {
+		var operations []string
+		for _, transfer := range entry.Transfers {
+			operations = append(operations, transfer.Hash)
+		}
+
+		entryStatus := string(entry.Status)
+		if entry.Reason != "" {
+			entryStatus = fmt.Sprintf("%s (%s)", entryStatus, status(entry.Reason))
+		}
+
+		table.Append([]string{
+			entry.Address,
+			entryStatus,
+			fmt.Sprintf("%.6f", float64(entry.Expected)/float64(gotezos.MUTEZ)),
+			fmt.Sprintf("%.6f", float64(entry.Received)/float64(gotezos.MUTEZ)),
+			groomOperations(operations...),
+		})
+	}
+
+	table.Render()
+}
+
+// ReconciliationJSON prints a reconciliation report to json
+func ReconciliationJSON(report reconcile.Report) error {
+	prettyJSON, err := json.Marshal(report)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse reconciliation report into json")
+	}
+
+	log.WithField("reconciliation", string(prettyJSON)).Info("Reconciliation for cycle complete.")
+	return nil
+}
+
+// PnLTable prints a profit and loss report in table format, in tez, under the accounting policy it was computed with
+func PnLTable(report pnl.Report) {
+	fmt.Printf("Accounting: %s\n", report.Accounting)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Cylce", "Own Stake", "Fees", "Retained", "Accusations", "Kept Fees", "Deducted Losses", "Dust", "Covered Missed", "Network Fees", "Burns", "Double Baking", "Double Endorsing", "Revelations", "Net Profit"})
+
+	statement := func(cycle string, statement pnl.Statement) []string {
+		if statement.Estimated {
+			cycle += " (estimated)"
+		}
+
+		return []string{
+			cycle,
+			tez(statement.OwnStakeRewards),
+			tez(statement.CollectedFees),
+			tez(statement.RetainedRewards),
+			tez(statement.AccusationRewards),
+			tez(statement.KeptBlockFees),
+			tez(statement.DeductedLosses),
+			tez(statement.Dust),
+			tez(-statement.CoveredMissedRewards),
+			tez(-statement.NetworkFees),
+			tez(-statement.BurnFees),
+			tez(-statement.DoubleBakingLosses),
+			tez(-statement.DoubleEndorsingLosses),
+			tez(-statement.RevelationLosses),
+			tez(statement.NetProfit),
+		}
+	}
+
+	for _, s := range report.Statements {
+		table.Append(statement(strconv.Itoa(s.Cycle), s))
+	}
+	table.SetFooter(statement("TOTAL", report.Total))
+
+	table.Render()
+}
+
+// PnLJSON prints a profit and loss report to json
+func PnLJSON(report pnl.Report) error {
+	prettyJSON, err := json.Marshal(report)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse profit and loss report into json")
+	}
+
+	log.WithField("pnl", string(prettyJSON)).Info("Profit and loss complete.")
+	return nil
+}
+
+// TrueUpTable prints a true up report in table format, in tez
+func TrueUpTable(report trueup.Report) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Cylce", "Expected Rewards", "Actual Rewards"})
+	table.Append([]string{strconv.Itoa(report.Cycle), tez(report.ExpectedRewards), tez(report.ActualRewards)})
+	table.Render()
+
+	table = tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Address", "Expected", "Actual", "Difference"})
+	for _, entry := range report.Entries {
+		table.Append([]string{entry.Address, tez(entry.Expected), tez(entry.Actual), tez(entry.Difference)})
+	}
+	table.SetFooter([]string{"TOTAL", tez(report.Expected), tez(report.Actual), tez(report.Difference)})
+
+	table.Render()
+}
+
+// TrueUpJSON prints a true up report to json
+func TrueUpJSON(report trueup.Report) error {
+	prettyJSON, err := json.Marshal(report)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse true up report into json")
+	}
+
+	log.WithField("trueup", string(prettyJSON)).Info("True up for cycle complete.")
+	return nil
+}
+
+// status returns a readable status, e.g. below minimum for below_minimum
+func status(status tzkt.Status) string {
+	if status == "" {
+		status = tzkt.Paid
+	}
+
+	return strings.Replace(string(status), "_", " ", -1)
+}
+
+func tez(mutez int) string {
+	return fmt.Sprintf("%.6f", float64(mutez)/float64(gotezos.MUTEZ))
+}
+
 func groomOperations(operations ...string) string {
 	var operation string
 	if operations == nil {
@@ -0,0 +1,363 @@
+package reconcile
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/goat-systems/go-tezos/v3/keys"
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/failover"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+// Status is the outcome of reconciling the payout of a single address
+type Status string
+
+const (
+	// Paid means the address received exactly what was computed
+	Paid Status = "paid"
+	// Underpaid means the address received less than what was computed
+	Underpaid Status = "underpaid"
+	// Overpaid means the address received more than what was computed, or was paid when it shouldn't have been
+	Overpaid Status = "overpaid"
+	// PaidTwice means the address received more transfers than expected
+	PaidTwice Status = "paid_twice"
+	// Missing means the address was owed a payment but received nothing
+	Missing Status = "missing"
+	// Skipped means the address wasn't owed a payment and received nothing
+	Skipped Status = "skipped"
+)
+
+// addressesPerRequest limits the number of targets in a single tzkt query to keep urls short
+const addressesPerRequest = 100
+
+// Transfer is an on chain transaction from the payout address. Burn is the allocation and storage burned by it.
+type Transfer struct {
+	Hash   string    `json:"hash"`
+	Level  int       `json:"level"`
+	Amount int       `json:"amount"`
+	Fee    int       `json:"fee,omitempty"`
+	Burn   int       `json:"burn,omitempty"`
+	Quotes tzkt.Fiat `json:"quotes,omitempty"`
+}
+
+// Entry is the reconciliation of a single address
+type Entry struct {
+	Address   string      `json:"address"`
+	Expected  int         `json:"expected"`
+	Received  int         `json:"received"`
+	Status    Status      `json:"status"`
+	Reason    tzkt.Status `json:"reason,omitempty"`
+	Transfers []Transfer  `json:"transfers,omitempty"`
+	payments  int
+}
+
+// Report is the reconciliation of a cycle's computed payout against on chain transfers
+type Report struct {
+	Cycle     int     `json:"cycle"`
+	Source    string  `json:"source"`
+	FromLevel int     `json:"from_level"`
+	ToLevel   int     `json:"to_level"`
+	Expected  int     `json:"expected"`
+	Received  int     `json:"received"`
+	Entries   []Entry `json:"entries"`
+}
+
+// Payout computes the rewards split a cycle should have been paid out with
+type Payout interface {
+	Execute() (tzkt.RewardsSplit, error)
+}
+
+// StaticPayout hands an already computed rewards split to a reconciler
+type StaticPayout struct {
+	RewardsSplit tzkt.RewardsSplit
+}
+
+// Execute returns the rewards split
+func (s *StaticPayout) Execute() (tzkt.RewardsSplit, error) {
+	return s.RewardsSplit, nil
+}
+
+// Reconciler compares a cycle's computed payout with the transfers made from the payout address
+type Reconciler struct {
+	payout    Payout
+	rpc       rpc.IFace
+	tzkt      tzkt.IFace
+	config    config.Config
+	cycle     int
+	source    string
+	fromLevel int
+	toLevel   int
+}
+
+/*
+New returns a pointer to a new Reconciler checking the transfers made for payout, which must not inject.
+Transfers are searched for between fromLevel and toLevel. If they're zero, the cycle during which the
+payout for cycle would have been made by tzpay serv is used. They're searched for from
+TZPAY_OPERATIONS_PAYOUT_ADDRESS, or if it isn't set from the address of the wallet key.
+*/
+func New(config config.Config, cycle int, payout Payout, fromLevel, toLevel int) (*Reconciler, error) {
+	source := config.Operations.PayoutAddress
+	if source == "" {
+		key, err := keys.NewKey(keys.NewKeyInput{
+			Kind:     keys.Ed25519,
+			Esk:      config.Key.Esk,
+			Password: config.Key.Password,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize import key")
+		}
+		source = key.PubKey.GetPublicKeyHash()
+	}
+
+	config.Key.Esk = ""
+	config.Key.Password = ""
+
+	r, err := failover.NewRPC(config.API)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize tezos rpc client")
+	}
+
+	return &Reconciler{
+		payout:    payout,
+		rpc:       r,
+		tzkt:      failover.NewTZKT(config.API),
+		config:    config,
+		cycle:     cycle,
+		source:    source,
+		fromLevel: fromLevel,
+		toLevel:   toLevel,
+	}, nil
+}
+
+/*
+Execute reconciles the payout against on chain transfers. The payout should be the one recorded when the cycle was
+paid out: one computed again only matches what was paid if nothing it depends on, such as the configuration or the
+ledgers, changed since.
+*/
+func (r *Reconciler) Execute() (Report, error) {
+	rewardsSplit, err := r.payout.Execute()
+	if err != nil {
+		return Report{}, errors.Wrapf(err, "failed to reconcile cycle %d", r.cycle)
+	}
+
+	report := Report{
+		Cycle:     r.cycle,
+		Source:    r.source,
+		FromLevel: r.fromLevel,
+		ToLevel:   r.toLevel,
+	}
+
+	if report.FromLevel == 0 || report.ToLevel == 0 {
+		if err := r.defaultLevels(&report); err != nil {
+			return report, errors.Wrapf(err, "failed to reconcile cycle %d", r.cycle)
+		}
+	}
+
+	entries := expectedEntries(rewardsSplit)
+
+	var addresses []string
+	for _, entry := range entries {
+		addresses = append(addresses, entry.Address)
+	}
+
+	transfers, err := r.getTransfers(addresses, report.FromLevel, report.ToLevel)
+	if err != nil {
+		return report, errors.Wrapf(err, "failed to reconcile cycle %d", r.cycle)
+	}
+
+	for i := range entries {
+		entries[i] = reconcile(entries[i], transfers[entries[i].Address])
+		report.Expected += entries[i].Expected
+		report.Received += entries[i].Received
+	}
+	report.Entries = entries
+
+	return report, nil
+}
+
+func (r *Reconciler) defaultLevels(report *Report) error {
+	head, err := r.rpc.Head()
+	if err != nil {
+		return errors.Wrap(err, "failed to get payout levels")
+	}
+
+	constants, err := r.rpc.Constants(head.Hash)
+	if err != nil {
+		return errors.Wrap(err, "failed to get payout levels")
+	}
+
+	fromLevel, toLevel, err := Window(r.config, constants, r.tzkt, r.cycle)
+	if err != nil {
+		return errors.Wrap(err, "failed to get payout levels")
+	}
+
+	if report.FromLevel == 0 {
+		report.FromLevel = fromLevel
+	}
+
+	if report.ToLevel == 0 {
+		report.ToLevel = toLevel
+	}
+
+	return nil
+}
+
+/*
+Window returns the first and last levels of the cycle during which tzpay serv pays cycle out: the cycle itself when
+paying on expected rewards, the cycle its rewards are unfrozen in with TZPAY_REWARDS_UNFROZEN_WAIT, and the next cycle
+otherwise. The levels are read from tzkt, as the head's constants don't hold for cycles of earlier protocols.
+*/
+func Window(cfg config.Config, constants rpc.Constants, indexer tzkt.IFace, cycle int) (int, int, error) {
+	payoutCycle := cycle + 1
+	switch {
+	case cfg.Baker.PayoutMode == config.ExpectedPayouts:
+		payoutCycle = cycle
+	case cfg.Baker.PayoutWhenRewardsUnfrozen:
+		payoutCycle = cycle + constants.PreservedCycles
+	}
+
+	window, err := indexer.GetCycle(payoutCycle)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to get the levels of cycle %d", payoutCycle)
+	}
+
+	return window.FirstLevel, window.LastLevel, nil
+}
+
+// getTransfers returns the applied transfers from the payout address to addresses, keyed by target
+func (r *Reconciler) getTransfers(addresses []string, fromLevel, toLevel int) (map[string][]Transfer, error) {
+	transfers := map[string][]Transfer{}
+	for len(addresses) > 0 {
+		batch := addresses
+		if len(batch) > addressesPerRequest {
+			batch = batch[:addressesPerRequest]
+		}
+		addresses = addresses[len(batch):]
+
+		options := []tzkt.URLParameters{
+			{
+				Key:   "sender",
+				Value: r.source,
+			},
+			{
+				Key:   "target.in",
+				Value: strings.Join(batch, ","),
+			},
+			{
+				Key:   "level.ge",
+				Value: strconv.Itoa(fromLevel),
+			},
+			{
+				Key:   "level.le",
+				Value: strconv.Itoa(toLevel),
+			},
+		}
+		if len(r.config.Fiat.Currencies) > 0 {
+			options = append(options, tzkt.URLParameters{
+				Key:   "quote",
+				Value: strings.Join(r.config.Fiat.Currencies, ","),
+			})
+		}
+
+		err := r.tzkt.PageTransactions(func(transactions []tzkt.Transaction) error {
+			for _, transaction := range transactions {
+				if transaction.Status != "applied" {
+					continue
+				}
+
+				transfers[transaction.Target.Address] = append(transfers[transaction.Target.Address], Transfer{
+					Hash:   transaction.Hash,
+					Level:  transaction.Level,
+					Amount: transaction.Amount,
+					Fee:    transaction.BakerFee,
+					Burn:   transaction.AllocationFee + transaction.StorageFee,
+					Quotes: transaction.Quote.Prices(r.config.Fiat.Currencies...),
+				})
+			}
+			return nil
+		}, options...)
+		if err != nil {
+			return transfers, errors.Wrap(err, "failed to get transfers from payout address")
+		}
+	}
+
+	return transfers, nil
+}
+
+// expectedEntries lists every address in the payout with what it is owed, merging addresses paid more than once
+func expectedEntries(rewardsSplit tzkt.RewardsSplit) []Entry {
+	var entries []Entry
+	index := map[string]int{}
+
+	add := func(address string, amount int, status tzkt.Status) {
+		i, ok := index[address]
+		if !ok {
+			i = len(entries)
+			index[address] = i
+			entries = append(entries, Entry{Address: address})
+		}
+
+		if status.Skipped() {
+			if entries[i].Reason == "" {
+				entries[i].Reason = status
+			}
+			return
+		}
+
+		entries[i].Expected += amount
+		entries[i].payments++
+	}
+
+	for _, delegator := range rewardsSplit.Delegators {
+		if delegator.LiquidityProviders != nil {
+			for _, liquidityProvider := range delegator.LiquidityProviders {
+				add(liquidityProvider.Address, liquidityProvider.Amount(), liquidityProvider.Status)
+			}
+		} else {
+			add(delegator.Address, delegator.Amount(), delegator.Status)
+		}
+	}
+
+	// skipped rewards sent to an address are owed to it like rewards to a delegator
+	if skipped := rewardsSplit.SkippedRewards; skipped != nil && skipped.Policy == config.SendSkippedRewards {
+		add(skipped.Address, skipped.Amount, tzkt.Paid)
+	}
+
+	// an address owed a payment isn't skipped, whatever its other lines were skipped for
+	for i := range entries {
+		if entries[i].payments > 0 {
+			entries[i].Reason = ""
+		}
+	}
+
+	return entries
+}
+
+func reconcile(entry Entry, transfers []Transfer) Entry {
+	entry.Transfers = transfers
+	for _, transfer := range transfers {
+		entry.Received += transfer.Amount
+	}
+
+	switch {
+	case entry.payments == 0 && len(transfers) == 0:
+		entry.Status = Skipped
+	case entry.payments == 0:
+		entry.Status = Overpaid
+	case len(transfers) == 0:
+		entry.Status = Missing
+	case len(transfers) > entry.payments:
+		entry.Status = PaidTwice
+	case entry.Received < entry.Expected:
+		entry.Status = Underpaid
+	case entry.Received > entry.Expected:
+		entry.Status = Overpaid
+	default:
+		entry.Status = Paid
+	}
+
+	return entry
+}
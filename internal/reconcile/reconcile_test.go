@@ -0,0 +1,298 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_expectedEntries(t *testing.T) {
+	rewardsSplit := tzkt.RewardsSplit{
+		Delegators: tzkt.Delegators{
+			{Address: "tz1a", NetRewards: 100, CarriedOver: 5, Redistributed: 20},
+			{Address: "tz1b", NetRewards: 50, Status: tzkt.Blacklisted},
+			{
+				Address:    "KT1a",
+				NetRewards: 1000,
+				LiquidityProviders: []tzkt.LiquidityProvider{
+					{Address: "tz1a", NetRewards: 10},
+					{Address: "tz1c", NetRewards: 1, Status: tzkt.Blacklisted},
+				},
+			},
+		},
+		SkippedRewards: &tzkt.Skipped{Policy: config.SendSkippedRewards, Amount: 51, Address: "tz1fund"},
+	}
+
+	assert.Equal(t, []Entry{
+		{Address: "tz1a", Expected: 135, payments: 2},
+		{Address: "tz1b", Reason: tzkt.Blacklisted},
+		{Address: "tz1c", Reason: tzkt.Blacklisted},
+		{Address: "tz1fund", Expected: 51, payments: 1},
+	}, expectedEntries(rewardsSplit))
+}
+
+func Test_reconcile(t *testing.T) {
+	type input struct {
+		entry     Entry
+		transfers []Transfer
+	}
+
+	type want struct {
+		status   Status
+		received int
+	}
+
+	cases := []struct {
+		name  string
+		input input
+		want  want
+	}{
+		{
+			"is paid",
+			input{Entry{Expected: 100, payments: 1}, []Transfer{{Hash: "oo1", Amount: 100}}},
+			want{Paid, 100},
+		},
+		{
+			"is paid with several expected transfers",
+			input{Entry{Expected: 110, payments: 2}, []Transfer{{Hash: "oo1", Amount: 100}, {Hash: "oo2", Amount: 10}}},
+			want{Paid, 110},
+		},
+		{
+			"is underpaid",
+			input{Entry{Expected: 100, payments: 1}, []Transfer{{Hash: "oo1", Amount: 99}}},
+			want{Underpaid, 99},
+		},
+		{
+			"is overpaid",
+			input{Entry{Expected: 100, payments: 1}, []Transfer{{Hash: "oo1", Amount: 101}}},
+			want{Overpaid, 101},
+		},
+		{
+			"is overpaid when nothing was owed",
+			input{Entry{}, []Transfer{{Hash: "oo1", Amount: 1}}},
+			want{Overpaid, 1},
+		},
+		{
+			"is paid twice",
+			input{Entry{Expected: 100, payments: 1}, []Transfer{{Hash: "oo1", Amount: 100}, {Hash: "oo2", Amount: 100}}},
+			want{PaidTwice, 200},
+		},
+		{
+			"is missing",
+			input{Entry{Expected: 100, payments: 1}, nil},
+			want{Missing, 0},
+		},
+		{
+			"is skipped",
+			input{Entry{}, nil},
+			want{Skipped, 0},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := reconcile(tt.input.entry, tt.input.transfers)
+			assert.Equal(t, tt.want.status, entry.Status)
+			assert.Equal(t, tt.want.received, entry.Received)
+			assert.Equal(t, tt.input.transfers, entry.Transfers)
+		})
+	}
+}
+
+type payoutMock struct {
+	rewardsSplit tzkt.RewardsSplit
+	err          error
+}
+
+func (p *payoutMock) Execute() (tzkt.RewardsSplit, error) {
+	return p.rewardsSplit, p.err
+}
+
+func Test_Execute(t *testing.T) {
+	const source = "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo"
+
+	transfer := func(id, level int, target string, amount int, status string) tzkt.Transaction {
+		var transaction tzkt.Transaction
+		transaction.ID = id
+		transaction.Level = level
+		transaction.Hash = fmt.Sprintf("oo%d", id)
+		transaction.Sender.Address = source
+		transaction.Target.Address = target
+		transaction.Amount = amount
+		transaction.Status = status
+		transaction.Quote.Usd = 2
+		transaction.Quote.Eur = 1.8
+		return transaction
+	}
+
+	rewardsSplit := tzkt.RewardsSplit{
+		Delegators: tzkt.Delegators{
+			{Address: "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", NetRewards: 34665260},
+			{Address: "KT1FPyY6mAhnzyVGP8ApGvuRyF7SKcT9TDWy", NetRewards: 34395939},
+			{Address: "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", NetRewards: 31664685},
+			{
+				Address: "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv",
+				LiquidityProviders: []tzkt.LiquidityProvider{
+					{Address: "tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV", NetRewards: 20938916},
+					{Address: "tz1Ykmc29JfQvWnjWRPYTPUZBLW4gwa9YKUD", NetRewards: 11960158, Status: tzkt.Blacklisted},
+				},
+			},
+		},
+	}
+
+	node, err := test.LoadNode(test.Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+
+	// cycle 270 is paid out during cycle 271, from level 1110017 to 1114112
+	indexer := test.NewTzkt(test.TzktFixture{
+		Transactions: []tzkt.Transaction{
+			transfer(1, 1110100, "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", 34665260, "applied"),
+			transfer(2, 1110100, "KT1FPyY6mAhnzyVGP8ApGvuRyF7SKcT9TDWy", 34000000, "applied"),
+			transfer(3, 1110100, "tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV", 20938916, "applied"),
+			transfer(4, 1110200, "tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV", 20938916, "applied"),
+			transfer(5, 1110100, "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", 31664685, "backtracked"),
+			transfer(6, 1120000, "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", 31664685, "applied"),
+		},
+	}, node)
+	defer indexer.Close()
+
+	cfg := config.Config{
+		API: config.API{
+			TZKT:  indexer.URL,
+			Tezos: node.URL,
+		},
+		Key: config.Key{
+			Esk:      "edesk1fddn27MaLcQVEdZpAYiyGQNm6UjtWiBfNP2ZenTy3CFsoSVJgeHM9pP9cvLJ2r5Xp2quQ5mYexW1LRKee2",
+			Password: "password12345##",
+		},
+		Fiat: config.Fiat{
+			Currencies: []string{"usd"},
+		},
+	}
+
+	reconciler, err := New(cfg, 270, &payoutMock{rewardsSplit: rewardsSplit}, 0, 0)
+	assert.Nil(t, err)
+
+	report, err := reconciler.Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, Report{
+		Cycle:     270,
+		Source:    source,
+		FromLevel: 1110017,
+		ToLevel:   1114112,
+		Expected:  34665260 + 34395939 + 31664685 + 20938916,
+		Received:  34665260 + 34000000 + 20938916*2,
+		Entries: []Entry{
+			{
+				Address:   "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd",
+				Expected:  34665260,
+				Received:  34665260,
+				Status:    Paid,
+				Transfers: []Transfer{{Hash: "oo1", Level: 1110100, Amount: 34665260, Quotes: tzkt.Fiat{"usd": 2}}},
+				payments:  1,
+			},
+			{
+				Address:   "KT1FPyY6mAhnzyVGP8ApGvuRyF7SKcT9TDWy",
+				Expected:  34395939,
+				Received:  34000000,
+				Status:    Underpaid,
+				Transfers: []Transfer{{Hash: "oo2", Level: 1110100, Amount: 34000000, Quotes: tzkt.Fiat{"usd": 2}}},
+				payments:  1,
+			},
+			{
+				Address:  "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC",
+				Expected: 31664685,
+				Status:   Missing,
+				payments: 1,
+			},
+			{
+				Address:  "tz1S82rGFZK8cVbNDpP1Hf9VhTUa4W8oc2WV",
+				Expected: 20938916,
+				Received: 20938916 * 2,
+				Status:   PaidTwice,
+				Transfers: []Transfer{
+					{Hash: "oo3", Level: 1110100, Amount: 20938916, Quotes: tzkt.Fiat{"usd": 2}},
+					{Hash: "oo4", Level: 1110200, Amount: 20938916, Quotes: tzkt.Fiat{"usd": 2}},
+				},
+				payments: 1,
+			},
+			{
+				Address: "tz1Ykmc29JfQvWnjWRPYTPUZBLW4gwa9YKUD",
+				Status:  Skipped,
+				Reason:  tzkt.Blacklisted,
+			},
+		},
+	}, report)
+
+	// the payout address is read from the configuration rather than from the wallet key
+	keyless := cfg
+	keyless.Key = config.Key{}
+	keyless.Operations.PayoutAddress = source
+	reconciler, err = New(keyless, 270, &payoutMock{rewardsSplit: rewardsSplit}, 0, 0)
+	assert.Nil(t, err)
+
+	keylessReport, err := reconciler.Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, report, keylessReport)
+
+	reconciler, err = New(cfg, 270, &payoutMock{err: errors.New("failed to construct")}, 0, 0)
+	assert.Nil(t, err)
+
+	_, err = reconciler.Execute()
+	test.CheckErr(t, true, "failed to construct", err)
+}
+
+type cyclesMock struct {
+	test.TzktMock
+	cycles map[int]tzkt.Cycle
+}
+
+func (c *cyclesMock) GetCycle(cycle int, options ...tzkt.URLParameters) (tzkt.Cycle, error) {
+	window, ok := c.cycles[cycle]
+	if !ok {
+		return tzkt.Cycle{}, errors.New("failed to get cycle")
+	}
+
+	return window, nil
+}
+
+func Test_Window(t *testing.T) {
+	// the head's blocks per cycle differ from the ones the cycles were run with
+	constants := rpc.Constants{BlocksPerCycle: 8192, PreservedCycles: 5}
+	indexer := &cyclesMock{cycles: map[int]tzkt.Cycle{
+		270: {Index: 270, FirstLevel: 1105921, LastLevel: 1110016},
+		271: {Index: 271, FirstLevel: 1110017, LastLevel: 1114112},
+		275: {Index: 275, FirstLevel: 1126401, LastLevel: 1130496},
+	}}
+
+	cases := []struct {
+		name     string
+		baker    config.Baker
+		cycle    int
+		from     int
+		to       int
+		err      bool
+		contains string
+	}{
+		{"is paid out during the next cycle", config.Baker{}, 270, 1110017, 1114112, false, ""},
+		{"is paid out once rewards are unfrozen", config.Baker{PayoutWhenRewardsUnfrozen: true}, 270, 1126401, 1130496, false, ""},
+		{"is paid out during the cycle on expected rewards", config.Baker{PayoutMode: config.ExpectedPayouts, PayoutWhenRewardsUnfrozen: true}, 270, 1105921, 1110016, false, ""},
+		{"handles failure to get cycle", config.Baker{}, 271, 0, 0, true, "failed to get the levels of cycle 272"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := Window(config.Config{Baker: tt.baker}, constants, indexer, tt.cycle)
+			test.CheckErr(t, tt.err, tt.contains, err)
+			assert.Equal(t, tt.from, from)
+			assert.Equal(t, tt.to, to)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package rewards
+
+import (
+	"math/big"
+
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+// Names of the reward models, recorded in the rewards splits they read
+const (
+	LegacyModel  = "legacy"
+	StakingModel = "staking"
+)
+
+// parisCode is tzkt's code for Paris, the first protocol splitting a delegate's stake into staked and delegated funds
+const parisCode = 19
+
+/*
+stakingOverDelegationEdge is how many times more than delegated balances staked funds weigh in a delegate's baking
+power, used to tell the delegated share of rewards that weren't earned yet
+*/
+const stakingOverDelegationEdge = 2
+
+/*
+Model reads a rewards split computed under the rules of a protocol, returning the rewards the baker distributes
+off chain and the balances they're shared among in the fields the payout is computed from. It fails on a split
+it can't make sense of rather than have the payout underpay.
+*/
+type Model interface {
+	Split(rewardsSplit tzkt.RewardsSplit) (tzkt.RewardsSplit, error)
+}
+
+// ModelOf returns the reward model of protocol
+func ModelOf(protocol tzkt.Protocol) Model {
+	if protocol.Code >= parisCode {
+		return Staking{}
+	}
+
+	return Legacy{}
+}
+
+// Legacy is the model of the protocols before Paris, whose whole staking balance shares the rewards off chain
+type Legacy struct{}
+
+// Split satisfies Model
+func (Legacy) Split(rewardsSplit tzkt.RewardsSplit) (tzkt.RewardsSplit, error) {
+	rewardsSplit.Model = LegacyModel
+	return rewardsSplit, nil
+}
+
+/*
+Staking is the model of Paris onward, where staked funds are rewarded on chain and only the delegated balances of the
+baker and its delegators share rewards off chain. The rewards tzkt splits between staked and delegated funds are
+paid from their delegated part. Accusation rewards and missed or future rewards aren't split, so only their
+delegated share is paid, which is the share of the rewards earned that went to delegated balances. Block fees go to
+the baker's spendable balance in full, but they're earned by the whole baking power, so only their delegated share
+is paid as well and staked funds don't get paid off chain for fees. Slashing only burns staked funds, so delegated
+balances never lose rewards.
+
+A split with a staking balance but nothing in any of the delegated fields is refused rather than paid out as nothing,
+as it's what a change of tzkt's schema looks like.
+*/
+type Staking struct{}
+
+// Split satisfies Model
+func (Staking) Split(rewardsSplit tzkt.RewardsSplit) (tzkt.RewardsSplit, error) {
+	if bakingBalance(rewardsSplit) > 0 && delegatedRewards(rewardsSplit) == 0 {
+		return rewardsSplit, errors.Errorf("no delegated rewards for a staking balance of %d, the rewards split may not match tzkt's schema", bakingBalance(rewardsSplit))
+	}
+
+	delegated := delegatedShare(rewardsSplit)
+	split := rewardsSplit
+	split.Model = StakingModel
+
+	split.StakingBalance = rewardsSplit.OwnDelegatedBalance + rewardsSplit.ExternalDelegatedBalance
+	split.DelegatedBalance = rewardsSplit.ExternalDelegatedBalance
+	split.NumDelegators = rewardsSplit.DelegatorsCount
+	split.ExpectedEndorsements = rewardsSplit.ExpectedAttestations
+
+	split.OwnBlocks = rewardsSplit.Blocks
+	split.OwnBlockRewards = rewardsSplit.BlockRewardsDelegated
+	split.Endorsements = rewardsSplit.Attestations
+	split.EndorsementRewards = rewardsSplit.AttestationRewardsDelegated
+	split.RevelationRewards = rewardsSplit.VdfRevelationRewardsDelegated + rewardsSplit.NonceRevelationRewardsDelegated
+	split.OwnBlockFees = delegated(rewardsSplit.BlockFees)
+
+	split.MissedOwnBlocks = rewardsSplit.MissedBlocks
+	split.MissedOwnBlockRewards = delegated(rewardsSplit.MissedBlockRewards)
+	split.MissedOwnBlockFees = delegated(rewardsSplit.MissedBlockFees)
+	split.MissedEndorsements = rewardsSplit.MissedAttestations
+	split.MissedEndorsementRewards = delegated(rewardsSplit.MissedAttestationRewards)
+	split.FutureBlockRewards = delegated(rewardsSplit.FutureBlockRewards)
+	split.FutureEndorsements = rewardsSplit.FutureAttestations
+	split.FutureEndorsementRewards = delegated(rewardsSplit.FutureAttestationRewards)
+
+	split.DoubleBakingRewards = delegated(rewardsSplit.DoubleBakingRewards)
+	split.DoubleEndorsingRewards = delegated(rewardsSplit.DoubleAttestingRewards + rewardsSplit.DoublePreattestingRewards)
+
+	split.Delegators = make(tzkt.Delegators, len(rewardsSplit.Delegators))
+	for i, delegator := range rewardsSplit.Delegators {
+		delegator.Balance = delegator.DelegatedBalance
+		split.Delegators[i] = delegator
+	}
+
+	return split, nil
+}
+
+// bakingBalance returns the delegated and staked balances of the baker and its delegators
+func bakingBalance(rewardsSplit tzkt.RewardsSplit) int {
+	return rewardsSplit.OwnDelegatedBalance + rewardsSplit.ExternalDelegatedBalance + rewardsSplit.OwnStakedBalance + rewardsSplit.ExternalStakedBalance
+}
+
+// delegatedRewards returns the rewards earned or expected that go to delegated balances
+func delegatedRewards(rewardsSplit tzkt.RewardsSplit) int {
+	return rewardsSplit.BlockRewardsDelegated + rewardsSplit.AttestationRewardsDelegated +
+		rewardsSplit.VdfRevelationRewardsDelegated + rewardsSplit.NonceRevelationRewardsDelegated +
+		rewardsSplit.FutureBlockRewards + rewardsSplit.FutureAttestationRewards
+}
+
+/*
+delegatedShare returns a func giving the delegated share of an amount of rewards, which is the share of the block and
+attestation rewards earned that went to delegated balances. Before any are earned, it's the share of the baking power
+delegated balances make up.
+*/
+func delegatedShare(rewardsSplit tzkt.RewardsSplit) func(int) int {
+	delegated := rewardsSplit.BlockRewardsDelegated + rewardsSplit.AttestationRewardsDelegated
+	total := delegated +
+		rewardsSplit.BlockRewardsStakedOwn + rewardsSplit.BlockRewardsStakedEdge + rewardsSplit.BlockRewardsStakedShared +
+		rewardsSplit.AttestationRewardsStakedOwn + rewardsSplit.AttestationRewardsStakedEdge + rewardsSplit.AttestationRewardsStakedShared
+
+	if total == 0 {
+		delegated = rewardsSplit.OwnDelegatedBalance + rewardsSplit.ExternalDelegatedBalance
+		total = delegated + stakingOverDelegationEdge*(rewardsSplit.OwnStakedBalance+rewardsSplit.ExternalStakedBalance)
+	}
+
+	return func(amount int) int {
+		if total == 0 {
+			return 0
+		}
+
+		share := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(int64(delegated)))
+		return int(share.Quo(share, big.NewInt(int64(total))).Int64())
+	}
+}
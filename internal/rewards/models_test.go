@@ -0,0 +1,190 @@
+package rewards
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ModelOf(t *testing.T) {
+	assert.IsType(t, Legacy{}, ModelOf(test.Carthage))
+	assert.IsType(t, Legacy{}, ModelOf(tzkt.Protocol{Code: 18}))
+	assert.IsType(t, Staking{}, ModelOf(tzkt.Protocol{Code: 19}))
+	assert.IsType(t, Staking{}, ModelOf(tzkt.Protocol{Code: 20}))
+}
+
+func Test_Legacy_Split(t *testing.T) {
+	rewardsSplit := tzkt.RewardsSplit{Cycle: 270, StakingBalance: 1000, OwnBlockRewards: 100}
+	want := rewardsSplit
+	want.Model = LegacyModel
+	split, err := Legacy{}.Split(rewardsSplit)
+	assert.Nil(t, err)
+	assert.Equal(t, want, split)
+}
+
+func Test_Staking_Split(t *testing.T) {
+	staking := tzkt.StakingRewards{
+		OwnDelegatedBalance:             1000,
+		ExternalDelegatedBalance:        9000,
+		DelegatorsCount:                 2,
+		OwnStakedBalance:                4000,
+		ExternalStakedBalance:           1000,
+		Blocks:                          3,
+		BlockRewardsDelegated:           300,
+		BlockRewardsStakedOwn:           400,
+		BlockRewardsStakedEdge:          100,
+		BlockRewardsStakedShared:        100,
+		MissedBlocks:                    1,
+		MissedBlockRewards:              1000,
+		ExpectedAttestations:            11.5,
+		Attestations:                    9,
+		AttestationRewardsDelegated:     100,
+		AttestationRewardsStakedOwn:     100,
+		AttestationRewardsStakedShared:  100,
+		MissedAttestations:              2,
+		MissedAttestationRewards:        200,
+		BlockFees:                       500,
+		MissedBlockFees:                 30,
+		DoubleAttestingRewards:          20,
+		DoublePreattestingRewards:       20,
+		VdfRevelationRewardsDelegated:   7,
+		NonceRevelationRewardsDelegated: 3,
+	}
+
+	delegators := tzkt.Delegators{
+		{Address: "tz1a", DelegatedBalance: 6000, StakedBalance: 1000},
+		{Address: "tz1b", DelegatedBalance: 3000},
+	}
+
+	type want struct {
+		rewardsSplit tzkt.RewardsSplit
+		err          bool
+		contains     string
+	}
+
+	cases := []struct {
+		name         string
+		rewardsSplit tzkt.RewardsSplit
+		want         want
+	}{
+		{
+			"pays the delegated rewards and the delegated share of the rest, fees included",
+			tzkt.RewardsSplit{
+				Cycle:               745,
+				FutureBlockRewards:  600,
+				DoubleBakingRewards: 40,
+				Delegators:          delegators,
+				StakingRewards:      staking,
+			},
+			want{rewardsSplit: tzkt.RewardsSplit{
+				Cycle:                    745,
+				Model:                    StakingModel,
+				StakingBalance:           10000,
+				DelegatedBalance:         9000,
+				NumDelegators:            2,
+				ExpectedEndorsements:     11.5,
+				OwnBlocks:                3,
+				OwnBlockRewards:          300,
+				Endorsements:             9,
+				EndorsementRewards:       100,
+				RevelationRewards:        10,
+				OwnBlockFees:             166,
+				MissedOwnBlocks:          1,
+				MissedOwnBlockRewards:    333,
+				MissedOwnBlockFees:       10,
+				MissedEndorsements:       2,
+				MissedEndorsementRewards: 66,
+				FutureBlockRewards:       200,
+				DoubleBakingRewards:      13,
+				DoubleEndorsingRewards:   13,
+				Delegators: tzkt.Delegators{
+					{Address: "tz1a", Balance: 6000, DelegatedBalance: 6000, StakedBalance: 1000},
+					{Address: "tz1b", Balance: 3000, DelegatedBalance: 3000},
+				},
+				StakingRewards: staking,
+			}},
+		},
+		{
+			"shares by baking power before rewards are earned",
+			tzkt.RewardsSplit{
+				Cycle:              750,
+				FutureBlockRewards: 1000,
+				StakingRewards: tzkt.StakingRewards{
+					OwnDelegatedBalance:      1000,
+					ExternalDelegatedBalance: 9000,
+					OwnStakedBalance:         4000,
+					ExternalStakedBalance:    1000,
+					FutureAttestationRewards: 500,
+				},
+			},
+			want{rewardsSplit: tzkt.RewardsSplit{
+				Cycle:                    750,
+				Model:                    StakingModel,
+				StakingBalance:           10000,
+				DelegatedBalance:         9000,
+				FutureBlockRewards:       500,
+				FutureEndorsementRewards: 250,
+				Delegators:               tzkt.Delegators{},
+				StakingRewards: tzkt.StakingRewards{
+					OwnDelegatedBalance:      1000,
+					ExternalDelegatedBalance: 9000,
+					OwnStakedBalance:         4000,
+					ExternalStakedBalance:    1000,
+					FutureAttestationRewards: 500,
+				},
+			}},
+		},
+		{
+			"pays nothing without a baking power",
+			tzkt.RewardsSplit{Cycle: 750, FutureBlockRewards: 1000},
+			want{rewardsSplit: tzkt.RewardsSplit{Cycle: 750, Model: StakingModel, Delegators: tzkt.Delegators{}}},
+		},
+		{
+			"refuses a staking balance without delegated rewards",
+			tzkt.RewardsSplit{
+				Cycle:              750,
+				EndorsementRewards: 100,
+				StakingRewards: tzkt.StakingRewards{
+					OwnDelegatedBalance:         1000,
+					OwnStakedBalance:            4000,
+					AttestationRewardsStakedOwn: 400,
+				},
+			},
+			want{err: true, contains: "no delegated rewards for a staking balance of 5000"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			split, err := Staking{}.Split(tt.rewardsSplit)
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			if !tt.want.err {
+				assert.Equal(t, tt.want.rewardsSplit, split)
+			}
+		})
+	}
+}
+
+// Test_Staking_Split_paris splits the rewards split tzkt serves for a cycle run by Paris
+func Test_Staking_Split_paris(t *testing.T) {
+	byts, err := ioutil.ReadFile(filepath.Join("..", "tzkt", "testdata", "rewards-split-750.json"))
+	assert.Nil(t, err)
+
+	var rewardsSplit tzkt.RewardsSplit
+	assert.Nil(t, json.Unmarshal(byts, &rewardsSplit))
+
+	split, err := Staking{}.Split(rewardsSplit)
+	assert.Nil(t, err)
+	assert.Equal(t, 238387898834, split.StakingBalance)
+	assert.Equal(t, 18575431, split.OwnBlockRewards)
+	assert.Equal(t, 26514328, split.EndorsementRewards)
+	assert.Equal(t, 71402, split.RevelationRewards)
+	assert.Equal(t, 49161, split.OwnBlockFees)
+	assert.Equal(t, 281106, split.MissedEndorsementRewards)
+	assert.Equal(t, 60545965782, split.Delegators[0].Balance)
+}
@@ -0,0 +1,182 @@
+package rewards
+
+import (
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+// frozenRewardsProtocols names the protocols that froze rewards by hash, Ithaca and later protocols don't
+var frozenRewardsProtocols = map[string]string{
+	"PsBabyM1eUXZseaJdmXFApDSBqj8YBfwELoxZHHW77EMcAbbwAS": "Babylon",
+	"PsCARTHAGazKbHtnKfLzQg3kms52kSRpgnDY982a9oYsSXRLQEb": "Carthage",
+	"PsDELPH1Kxsxt8f9eWbxQeRxkjfbxoqM52jvs5Y5fBxWWh4ifpo": "Delphi",
+	"PtEdo2ZkT9oKpimTah6x2embF25oss54njMuPzkJTEi5RqfdZFA": "Edo",
+	"PsFLorenaUUuikDWvMDr6fGBRG8kt3e3D3fHoXK1j1BFRxeSH4i": "Florence",
+	"PtGRANADsDU8R9daYKAgWnQYAJ64omN1o3KMGVCykShA97vQbvV": "Granada",
+	"PtHangz2aRngywmSRGGvrcTyMbbdpWdpFKuS4uMWxg2RaH9i1qx": "Hangzhou",
+}
+
+// maxCycleSteps bounds the blocks visited to find the last block of a cycle, each step crosses at least one protocol
+const maxCycleSteps = 16
+
+/*
+Node provides a rewards split derived from a tezos node alone, for when the tzkt API is wrong or unavailable.
+
+The staking balance, delegations and delegators' balances are read at the cycle's roll snapshot, and the
+rewards and fees are those frozen for the cycle. The node doesn't break frozen rewards down by what earned
+them, so they are all reported as own block rewards, nor does it know of missed or lost rewards, which are
+left at zero. The expected blocks and endorsements are the delegate's priority 0 baking rights and
+endorsement slots in the cycle. Frozen rewards only exist under the legacy reward model and only until they're
+unfrozen, so the node refuses the split of cycles run by Ithaca or later protocols and of cycles it has no frozen
+rewards for, rather than reporting no rewards.
+*/
+type Node struct {
+	rpc rpc.IFace
+}
+
+// NewNode returns a pointer to a new Node provider
+func NewNode(rpcClient rpc.IFace) *Node {
+	return &Node{rpc: rpcClient}
+}
+
+// RewardsSplit satisfies Provider
+func (n *Node) RewardsSplit(delegate string, cycle int) (tzkt.RewardsSplit, error) {
+	block, err := n.lastBlock(cycle)
+	if err != nil {
+		return tzkt.RewardsSplit{Cycle: cycle}, errors.Wrapf(err, "failed to get rewards split for cycle %d", cycle)
+	}
+
+	if _, ok := frozenRewardsProtocols[block.Metadata.Protocol]; !ok {
+		return tzkt.RewardsSplit{Cycle: cycle}, errors.Errorf("failed to get rewards split for cycle %d: the node has no frozen rewards for cycles run by protocol %s, which is Ithaca or later, use the tzkt rewards provider", cycle, block.Metadata.Protocol)
+	}
+
+	rewardsSplit, err := n.delegations(delegate, cycle)
+	if err != nil {
+		return rewardsSplit, errors.Wrapf(err, "failed to get rewards split for cycle %d", cycle)
+	}
+
+	frozen, err := n.rpc.FrozenBalance(cycle, delegate)
+	if err != nil {
+		return rewardsSplit, errors.Wrapf(err, "failed to get rewards split for cycle %d", cycle)
+	}
+
+	// rewards are only frozen until the end of the preserved cycles, after which the node reports none
+	if frozen.Rewards == 0 && frozen.Fees == 0 && frozen.Deposits == 0 {
+		return rewardsSplit, errors.Errorf("failed to get rewards split for cycle %d: the node has no frozen rewards for the cycle, they may have been unfrozen", cycle)
+	}
+	rewardsSplit.OwnBlockRewards = frozen.Rewards
+	rewardsSplit.OwnBlockFees = frozen.Fees
+
+	if err := n.expectations(&rewardsSplit, delegate, cycle, block.Hash); err != nil {
+		return rewardsSplit, errors.Wrapf(err, "failed to get rewards split for cycle %d", cycle)
+	}
+
+	return Legacy{}.Split(rewardsSplit)
+}
+
+/*
+lastBlock returns the last block of the cycle, which the cycle's protocol and rights are read from. The cycle's
+length is read from the constants of a block's own protocol rather than the head's, as it changed between
+protocols: from the head, the level the cycle would end at if every cycle had the block's length is visited
+until a block of the cycle itself is found.
+*/
+func (n *Node) lastBlock(cycle int) (*rpc.Block, error) {
+	block, err := n.rpc.Head()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get last block of cycle")
+	}
+
+	for step := 0; step < maxCycleSteps; step++ {
+		constants, err := n.rpc.Constants(block.Hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get last block of cycle")
+		}
+
+		position := block.Metadata.Level
+		if constants.BlocksPerCycle <= 0 {
+			return nil, errors.Errorf("failed to get last block of cycle: block %d has no blocks per cycle", position.Level)
+		}
+
+		level := position.Level - position.CyclePosition + (cycle-position.Cycle+1)*constants.BlocksPerCycle - 1
+		if position.Cycle == cycle && position.Level == level {
+			return block, nil
+		}
+
+		if level < 1 {
+			level = 1
+		}
+
+		if block, err = n.rpc.Block(level); err != nil {
+			return nil, errors.Wrap(err, "failed to get last block of cycle")
+		}
+	}
+
+	return nil, errors.Errorf("failed to get last block of cycle: not found within %d blocks", maxCycleSteps)
+}
+
+// delegations returns the staking balance and delegators' balances of the delegate at the cycle's roll snapshot
+func (n *Node) delegations(delegate string, cycle int) (tzkt.RewardsSplit, error) {
+	rewardsSplit := tzkt.RewardsSplit{Cycle: cycle}
+
+	snapshot, err := n.rpc.Cycle(cycle)
+	if err != nil {
+		return rewardsSplit, err
+	}
+
+	rewardsSplit.StakingBalance, err = n.rpc.StakingBalance(rpc.StakingBalanceInput{Blockhash: snapshot.BlockHash, Delegate: delegate})
+	if err != nil {
+		return rewardsSplit, err
+	}
+
+	contracts, err := n.rpc.DelegatedContracts(rpc.DelegatedContractsInput{Blockhash: snapshot.BlockHash, Delegate: delegate})
+	if err != nil {
+		return rewardsSplit, err
+	}
+
+	rewardsSplit.Delegators = tzkt.Delegators{}
+	for _, contract := range contracts {
+		// the delegate is delegated to itself, its own balance isn't a delegation
+		if contract == delegate {
+			continue
+		}
+
+		balance, err := n.rpc.Balance(rpc.BalanceInput{Blockhash: snapshot.BlockHash, Address: contract})
+		if err != nil {
+			return rewardsSplit, err
+		}
+
+		rewardsSplit.Delegators = append(rewardsSplit.Delegators, tzkt.Delegator{Address: contract, Balance: balance})
+		rewardsSplit.DelegatedBalance += balance
+	}
+	rewardsSplit.NumDelegators = len(rewardsSplit.Delegators)
+
+	return rewardsSplit, nil
+}
+
+// expectations sets the blocks and endorsements the delegate had the rights to in the cycle, which a block in the cycle knows of
+func (n *Node) expectations(rewardsSplit *tzkt.RewardsSplit, delegate string, cycle int, blockhash string) error {
+	bakingRights, err := n.rpc.BakingRights(rpc.BakingRightsInput{BlockHash: blockhash, Cycle: cycle, Delegate: delegate})
+	if err != nil {
+		return errors.Wrap(err, "failed to get rights")
+	}
+
+	for _, right := range *bakingRights {
+		if right.Delegate == delegate && right.Priority == 0 {
+			rewardsSplit.ExpectedBlocks++
+		}
+	}
+
+	endorsingRights, err := n.rpc.EndorsingRights(rpc.EndorsingRightsInput{BlockHash: blockhash, Cycle: cycle, Delegate: delegate})
+	if err != nil {
+		return errors.Wrap(err, "failed to get rights")
+	}
+
+	for _, right := range *endorsingRights {
+		if right.Delegate == delegate {
+			rewardsSplit.ExpectedEndorsements += float64(len(right.Slots))
+		}
+	}
+
+	return nil
+}
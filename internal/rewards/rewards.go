@@ -0,0 +1,52 @@
+package rewards
+
+import (
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+// Provider is the source of what a delegate earned in a cycle and how its staking balance was split among its delegators
+type Provider interface {
+	RewardsSplit(delegate string, cycle int) (tzkt.RewardsSplit, error)
+}
+
+// New returns the Provider configured in api, reading from the given clients
+func New(api config.API, rpcClient rpc.IFace, tzktClient tzkt.IFace) Provider {
+	if api.RewardsProvider == config.NodeRewardsProvider {
+		return NewNode(rpcClient)
+	}
+
+	return NewTzkt(tzktClient)
+}
+
+// Tzkt provides the rewards split computed by the tzkt API
+type Tzkt struct {
+	tzkt tzkt.IFace
+}
+
+// NewTzkt returns a pointer to a new Tzkt provider
+func NewTzkt(tzktClient tzkt.IFace) *Tzkt {
+	return &Tzkt{tzkt: tzktClient}
+}
+
+// RewardsSplit satisfies Provider, reading the split with the reward model of the protocol the cycle ran
+func (t *Tzkt) RewardsSplit(delegate string, cycle int) (tzkt.RewardsSplit, error) {
+	protocol, err := t.tzkt.GetProtocol(cycle)
+	if err != nil {
+		return tzkt.RewardsSplit{}, errors.Wrapf(err, "failed to get rewards split for cycle %d", cycle)
+	}
+
+	rewardsSplit, err := t.tzkt.GetRewardsSplit(delegate, cycle)
+	if err != nil {
+		return rewardsSplit, err
+	}
+
+	rewardsSplit, err = ModelOf(protocol).Split(rewardsSplit)
+	if err != nil {
+		return rewardsSplit, errors.Wrapf(err, "failed to get rewards split for cycle %d", cycle)
+	}
+
+	return rewardsSplit, nil
+}
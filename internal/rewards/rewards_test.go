@@ -0,0 +1,179 @@
+package rewards
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+const baker = "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc"
+
+func Test_New(t *testing.T) {
+	assert.IsType(t, &Tzkt{}, New(config.API{RewardsProvider: config.TzktRewardsProvider}, nil, nil))
+	assert.IsType(t, &Node{}, New(config.API{RewardsProvider: config.NodeRewardsProvider}, nil, nil))
+}
+
+func Test_Tzkt_RewardsSplit(t *testing.T) {
+	want, _ := (&test.TzktMock{}).GetRewardsSplit(baker, 270)
+	want.Model = LegacyModel
+	rewardsSplit, err := NewTzkt(&test.TzktMock{}).RewardsSplit(baker, 270)
+	assert.Nil(t, err)
+	assert.Equal(t, want, rewardsSplit)
+
+	_, err = NewTzkt(&test.TzktMock{RewardsSplitErr: true}).RewardsSplit(baker, 270)
+	assert.NotNil(t, err)
+
+	_, err = NewTzkt(&test.TzktMock{ProtocolErr: true}).RewardsSplit(baker, 270)
+	test.CheckErr(t, true, "failed to get rewards split for cycle 270: failed to get protocol", err)
+}
+
+func Test_Node_RewardsSplit(t *testing.T) {
+	node, client := newNode(t)
+	defer node.Close()
+
+	rewardsSplit, err := NewNode(client).RewardsSplit(baker, 270)
+	assert.Nil(t, err)
+	assert.Equal(t, tzkt.RewardsSplit{
+		Cycle:                270,
+		Model:                LegacyModel,
+		StakingBalance:       10000000000,
+		DelegatedBalance:     9000000000,
+		NumDelegators:        2,
+		ExpectedBlocks:       2,
+		ExpectedEndorsements: 3,
+		OwnBlockRewards:      70000000,
+		OwnBlockFees:         3000,
+		Delegators: tzkt.Delegators{
+			{Address: "KT1LinsZAnyxajEv4eNFWtwHMdyhbJsGfvp3", Balance: 3000000000},
+			{Address: "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", Balance: 6000000000},
+		},
+	}, rewardsSplit)
+
+	_, err = NewNode(client).RewardsSplit("tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo", 270)
+	test.CheckErr(t, true, "failed to get rewards split for cycle 270", err)
+
+	_, err = NewNode(client).RewardsSplit("tz1iZ9LkpAhN8X1L6RpBtfy3wxpEWzFrXz8j", 270)
+	test.CheckErr(t, true, "failed to get rewards split for cycle 270: the node has no frozen rewards for the cycle, they may have been unfrozen", err)
+}
+
+func Test_Node_RewardsSplit_handlesFailures(t *testing.T) {
+	cases := []struct {
+		name     string
+		rpc      *test.RPCMock
+		contains string
+	}{
+		{"handles failure to get cycle", &test.RPCMock{CycleErr: true}, "failed to get cycle"},
+		{"handles failure to get staking balance", &test.RPCMock{StakingBalanceErr: true}, "failed to get staking balance"},
+		{"handles failure to get delegated contracts", &test.RPCMock{DelegatedContractsErr: true}, "failed to get delegated contracts"},
+		{"handles failure to get balance", &test.RPCMock{BalanceErr: true}, "failed to get balance"},
+		{"handles failure to get frozen balance", &test.RPCMock{FrozenBalanceErr: true}, "failed to get frozen balance"},
+		{"handles failure to get head", &test.RPCMock{HeadErr: true}, "failed to get last block of cycle: failed to get block"},
+		{"handles failure to get constants", &test.RPCMock{ConstantsErr: true}, "failed to get last block of cycle: failed to get constants"},
+		{"handles failure to get last block", &test.RPCMock{BlockErr: true}, "failed to get last block of cycle: failed to get block"},
+		{"refuses cycles run by ithaca or later", &test.RPCMock{Protocol: "Psithaca2MLRFYargivpo7YvUr7wUDqyxrdhC5CQq78mRvimz6A"}, "the node has no frozen rewards for cycles run by protocol Psithaca2MLRFYargivpo7YvUr7wUDqyxrdhC5CQq78mRvimz6A"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewNode(tt.rpc).RewardsSplit(baker, 270)
+			test.CheckErr(t, true, "failed to get rewards split for cycle 270: "+tt.contains, err)
+		})
+	}
+}
+
+// granadaMock is a node whose cycles doubled from 4096 to 8192 blocks with Granada, which started cycle 388
+type granadaMock struct {
+	test.RPCMock
+}
+
+const granadaLevel = 1589249
+
+func (g *granadaMock) Head() (*rpc.Block, error) {
+	return g.Block(1700000)
+}
+
+func (g *granadaMock) Block(id interface{}) (*rpc.Block, error) {
+	level := id.(int)
+	position := rpc.Level{Level: level, Cycle: (level - 1) / 4096, CyclePosition: (level - 1) % 4096}
+	if level >= granadaLevel {
+		position = rpc.Level{Level: level, Cycle: 388 + (level-granadaLevel)/8192, CyclePosition: (level - granadaLevel) % 8192}
+	}
+
+	return &rpc.Block{Hash: strconv.Itoa(level), Header: rpc.Header{Level: level}, Metadata: rpc.Metadata{Level: position}}, nil
+}
+
+func (g *granadaMock) Constants(blockhash string) (rpc.Constants, error) {
+	if level, _ := strconv.Atoi(blockhash); level >= granadaLevel {
+		return rpc.Constants{BlocksPerCycle: 8192}, nil
+	}
+
+	return rpc.Constants{BlocksPerCycle: 4096}, nil
+}
+
+func Test_Node_lastBlock(t *testing.T) {
+	cases := []struct {
+		name  string
+		cycle int
+		want  int
+	}{
+		{"is successful with cycle of earlier protocol", 270, 1110016},
+		{"is successful with last cycle of earlier protocol", 387, 1589248},
+		{"is successful with cycle of head protocol", 390, 1613824},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := NewNode(&granadaMock{}).lastBlock(tt.cycle)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, block.Header.Level)
+			assert.Equal(t, tt.cycle, block.Metadata.Level.Cycle)
+		})
+	}
+}
+
+// newNode starts a fake tezos node knowing of the baker and its delegations in cycle 270
+func newNode(t *testing.T) (*test.Node, *rpc.Client) {
+	node, err := test.NewNode(test.NodeFixture{
+		ChainID:   "NetXdQprcVkpaWU",
+		Protocol:  test.Carthage.Hash,
+		Level:     1130597,
+		Constants: json.RawMessage(`{"blocks_per_cycle":4096,"preserved_cycles":5,"blocks_per_roll_snapshot":256}`),
+		SnapshotBalances: map[string]int{
+			baker:                                  1000000000,
+			"KT1LinsZAnyxajEv4eNFWtwHMdyhbJsGfvp3": 3000000000,
+			"tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd": 6000000000,
+		},
+		Delegates: map[string]test.DelegateFixture{
+			baker: {
+				StakingBalance:     10000000000,
+				DelegatedContracts: []string{"KT1LinsZAnyxajEv4eNFWtwHMdyhbJsGfvp3", baker, "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd"},
+				FrozenBalance:      rpc.FrozenBalance{Deposits: 512000000, Fees: 3000, Rewards: 70000000},
+				BakingRights: rpc.BakingRights{
+					{Level: 1105921, Delegate: baker, Priority: 0},
+					{Level: 1106000, Delegate: baker, Priority: 1},
+					{Level: 1107100, Delegate: baker, Priority: 0},
+				},
+				EndorsingRights: rpc.EndorsingRights{
+					{Level: 1105922, Delegate: baker, Slots: []int{3, 17}},
+					{Level: 1106001, Delegate: baker, Slots: []int{8}},
+				},
+			},
+			"tz1iZ9LkpAhN8X1L6RpBtfy3wxpEWzFrXz8j": {
+				BakingRights:    rpc.BakingRights{{Level: 1106000, Delegate: "tz1iZ9LkpAhN8X1L6RpBtfy3wxpEWzFrXz8j"}},
+				EndorsingRights: rpc.EndorsingRights{{Level: 1106001, Delegate: "tz1iZ9LkpAhN8X1L6RpBtfy3wxpEWzFrXz8j", Slots: []int{1, 2}}},
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	client, err := rpc.New(node.URL)
+	assert.Nil(t, err)
+
+	return node, client
+}
@@ -0,0 +1,63 @@
+package rewards
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+// maxDivergences is how many divergences an error from Validate lists
+const maxDivergences = 10
+
+/*
+Validate cross checks the staking balance, delegated balance and delegators' balances of a rewards split
+of the delegate for cycle against those the tezos node has at the cycle's roll snapshot. It returns an
+error listing the amounts that differ by more than tolerance mutez. A delegator only one of them knows of
+is taken to have no balance in the other, so that emptied delegations don't fail the check.
+*/
+func (n *Node) Validate(rewardsSplit tzkt.RewardsSplit, delegate string, cycle, tolerance int) error {
+	node, err := n.delegations(delegate, cycle)
+	if err != nil {
+		return errors.Wrapf(err, "failed to validate rewards split for cycle %d", cycle)
+	}
+
+	var divergences []string
+	compare := func(what string, got, want int) {
+		if diff := got - want; diff > tolerance || -diff > tolerance {
+			divergences = append(divergences, fmt.Sprintf("%s is %d but %d on the node", what, got, want))
+		}
+	}
+
+	compare("staking balance", rewardsSplit.StakingBalance, node.StakingBalance)
+	compare("delegated balance", rewardsSplit.DelegatedBalance, node.DelegatedBalance)
+
+	balances := map[string]int{}
+	for _, delegator := range node.Delegators {
+		balances[delegator.Address] = delegator.Balance
+	}
+
+	known := map[string]bool{}
+	for _, delegator := range rewardsSplit.Delegators {
+		known[delegator.Address] = true
+		compare(fmt.Sprintf("balance of '%s'", delegator.Address), delegator.Balance, balances[delegator.Address])
+	}
+
+	for _, delegator := range node.Delegators {
+		if !known[delegator.Address] {
+			compare(fmt.Sprintf("balance of missing delegator '%s'", delegator.Address), 0, delegator.Balance)
+		}
+	}
+
+	if len(divergences) == 0 {
+		return nil
+	}
+
+	listed := divergences
+	if len(listed) > maxDivergences {
+		listed = append(listed[:maxDivergences:maxDivergences], fmt.Sprintf("and %d more", len(divergences)-maxDivergences))
+	}
+
+	return errors.Errorf("rewards split for cycle %d diverges from the tezos node: %s", cycle, strings.Join(listed, ", "))
+}
@@ -0,0 +1,87 @@
+package rewards
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+)
+
+func Test_Validate(t *testing.T) {
+	node, client := newNode(t)
+	defer node.Close()
+
+	split := func(stakingBalance, delegatedBalance int, delegators ...tzkt.Delegator) tzkt.RewardsSplit {
+		return tzkt.RewardsSplit{Cycle: 270, StakingBalance: stakingBalance, DelegatedBalance: delegatedBalance, Delegators: delegators}
+	}
+	kt1 := tzkt.Delegator{Address: "KT1LinsZAnyxajEv4eNFWtwHMdyhbJsGfvp3", Balance: 3000000000}
+	tz1 := tzkt.Delegator{Address: "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", Balance: 6000000000}
+
+	type want struct {
+		err      bool
+		contains string
+	}
+
+	cases := []struct {
+		name         string
+		rewardsSplit tzkt.RewardsSplit
+		tolerance    int
+		want         want
+	}{
+		{
+			"is successful",
+			split(10000000000, 9000000000, tz1, kt1),
+			0,
+			want{false, ""},
+		},
+		{
+			"is successful within tolerance",
+			split(10000000010, 9000000010, tz1, tzkt.Delegator{Address: kt1.Address, Balance: kt1.Balance + 10}),
+			10,
+			want{false, ""},
+		},
+		{
+			"is successful with empty delegators",
+			split(10000000000, 9000000000, tz1, kt1, tzkt.Delegator{Address: "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo"}),
+			0,
+			want{false, ""},
+		},
+		{
+			"handles diverging staking balance",
+			split(10000000011, 9000000000, tz1, kt1),
+			10,
+			want{true, "rewards split for cycle 270 diverges from the tezos node: staking balance is 10000000011 but 10000000000 on the node"},
+		},
+		{
+			"handles diverging balances",
+			split(10000000000, 9000000000, tz1, tzkt.Delegator{Address: kt1.Address, Balance: 2000000000}, tzkt.Delegator{Address: "tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo", Balance: 1000000000}),
+			0,
+			want{true, "balance of 'KT1LinsZAnyxajEv4eNFWtwHMdyhbJsGfvp3' is 2000000000 but 3000000000 on the node, balance of 'tz1L8fUQLuwRuywTZUP5JUw9LL3kJa8LMfoo' is 1000000000 but 0 on the node"},
+		},
+		{
+			"handles missing delegators",
+			split(10000000000, 6000000000, tz1),
+			0,
+			want{true, "delegated balance is 6000000000 but 9000000000 on the node, balance of missing delegator 'KT1LinsZAnyxajEv4eNFWtwHMdyhbJsGfvp3' is 0 but 3000000000 on the node"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewNode(client).Validate(tt.rewardsSplit, baker, 270, tt.tolerance)
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+		})
+	}
+
+	// an indexer wrong about everyone doesn't list everyone
+	var delegators tzkt.Delegators
+	for i := 0; i < 20; i++ {
+		delegators = append(delegators, tzkt.Delegator{Address: fmt.Sprintf("tz1delegator%d", i), Balance: 1})
+	}
+	err := NewNode(client).Validate(split(10000000000, 9000000000, delegators...), baker, 270, 0)
+	test.CheckErr(t, true, "and 12 more", err)
+
+	err = NewNode(&test.RPCMock{CycleErr: true}).Validate(split(10000000000, 9000000000), baker, 270, 0)
+	test.CheckErr(t, true, "failed to validate rewards split for cycle 270: failed to get cycle", err)
+}
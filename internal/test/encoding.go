@@ -0,0 +1,217 @@
+package test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var (
+	blockPrefix     = []byte{1, 52}
+	operationPrefix = []byte{5, 116}
+	tz1Prefix       = []byte{6, 161, 159}
+	tz2Prefix       = []byte{6, 161, 161}
+	tz3Prefix       = []byte{6, 161, 164}
+	kt1Prefix       = []byte{2, 90, 121}
+	edpkPrefix      = []byte{13, 15, 37, 217}
+	sppkPrefix      = []byte{3, 254, 226, 86}
+	p2pkPrefix      = []byte{3, 178, 139, 127}
+)
+
+// b58cencode base58check encodes a payload with a tezos prefix
+func b58cencode(payload []byte, prefix []byte) string {
+	n := make([]byte, len(prefix)+len(payload))
+	copy(n, prefix)
+	copy(n[len(prefix):], payload)
+
+	first := sha256.Sum256(n)
+	second := sha256.Sum256(first[:])
+	n = append(n, second[:4]...)
+
+	x := new(big.Int).SetBytes(n)
+	radix := big.NewInt(58)
+	zero := big.NewInt(0)
+	mod := new(big.Int)
+
+	var out []byte
+	for x.Cmp(zero) > 0 {
+		x.DivMod(x, radix, mod)
+		out = append(out, alphabet[mod.Int64()])
+	}
+
+	for _, b := range n {
+		if b != 0 {
+			break
+		}
+		out = append(out, alphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+
+	return string(out)
+}
+
+func blockHash(seed string) string {
+	hash := blake2b.Sum256([]byte(seed))
+	return b58cencode(hash[:], blockPrefix)
+}
+
+func operationHash(signedOperation []byte) string {
+	hash := blake2b.Sum256(signedOperation)
+	return b58cencode(hash[:], operationPrefix)
+}
+
+// decoder reads the binary encoding of a forged manager operation
+type decoder struct {
+	buf *bytes.Reader
+}
+
+func (d *decoder) bytes(n int) ([]byte, error) {
+	out := make([]byte, n)
+	if _, err := io.ReadFull(d.buf, out); err != nil {
+		return nil, errors.Wrap(err, "unexpected end of operation")
+	}
+
+	return out, nil
+}
+
+func (d *decoder) nat() (int64, error) {
+	v, err := binary.ReadUvarint(d.buf)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read zarith number")
+	}
+
+	return int64(v), nil
+}
+
+func (d *decoder) source() (string, error) {
+	b, err := d.bytes(21)
+	if err != nil {
+		return "", err
+	}
+
+	switch b[0] {
+	case 0:
+		return b58cencode(b[1:], tz1Prefix), nil
+	case 1:
+		return b58cencode(b[1:], tz2Prefix), nil
+	case 2:
+		return b58cencode(b[1:], tz3Prefix), nil
+	}
+
+	return "", fmt.Errorf("invalid source tag %d", b[0])
+}
+
+func (d *decoder) address() (string, error) {
+	b, err := d.bytes(22)
+	if err != nil {
+		return "", err
+	}
+
+	if b[0] == 1 {
+		return b58cencode(b[1:21], kt1Prefix), nil
+	}
+
+	return (&decoder{buf: bytes.NewReader(b[1:])}).source()
+}
+
+func (d *decoder) publicKey() (string, error) {
+	tag, err := d.bytes(1)
+	if err != nil {
+		return "", err
+	}
+
+	switch tag[0] {
+	case 0:
+		b, err := d.bytes(32)
+		return b58cencode(b, edpkPrefix), err
+	case 1:
+		b, err := d.bytes(33)
+		return b58cencode(b, sppkPrefix), err
+	case 2:
+		b, err := d.bytes(33)
+		return b58cencode(b, p2pkPrefix), err
+	}
+
+	return "", fmt.Errorf("invalid public key tag %d", tag[0])
+}
+
+/*
+decodeOperation decodes a signed operation forged by go-tezos/forge. Only reveals and
+transactions without parameters are supported, which is all tzpay ever injects.
+*/
+func decodeOperation(signedOperation []byte) (string, rpc.Contents, error) {
+	if len(signedOperation) < 32+64 {
+		return "", nil, errors.New("operation too short")
+	}
+
+	branch := b58cencode(signedOperation[:32], blockPrefix)
+	d := &decoder{buf: bytes.NewReader(signedOperation[32 : len(signedOperation)-64])}
+
+	var contents rpc.Contents
+	for d.buf.Len() > 0 {
+		tag, err := d.nat()
+		if err != nil {
+			return branch, nil, err
+		}
+
+		content := rpc.Content{}
+		if content.Source, err = d.source(); err != nil {
+			return branch, nil, err
+		}
+		if content.Fee, err = d.nat(); err != nil {
+			return branch, nil, err
+		}
+		counter, err := d.nat()
+		if err != nil {
+			return branch, nil, err
+		}
+		content.Counter = int(counter)
+		if content.GasLimit, err = d.nat(); err != nil {
+			return branch, nil, err
+		}
+		if content.StorageLimit, err = d.nat(); err != nil {
+			return branch, nil, err
+		}
+
+		switch tag {
+		case 107:
+			content.Kind = rpc.REVEAL
+			if content.PublicKey, err = d.publicKey(); err != nil {
+				return branch, nil, err
+			}
+		case 108:
+			content.Kind = rpc.TRANSACTION
+			if content.Amount, err = d.nat(); err != nil {
+				return branch, nil, err
+			}
+			if content.Destination, err = d.address(); err != nil {
+				return branch, nil, err
+			}
+			params, err := d.bytes(1)
+			if err != nil {
+				return branch, nil, err
+			}
+			if params[0] != 0 {
+				return branch, nil, errors.New("transaction parameters are not supported")
+			}
+		default:
+			return branch, nil, fmt.Errorf("unsupported operation tag %d", tag)
+		}
+
+		contents = append(contents, content)
+	}
+
+	return branch, contents, nil
+}
@@ -15,6 +15,12 @@ type TzktMock struct {
 	tzkt.IFace
 	TransactionsErr bool
 	RewardsSplitErr bool
+	ProtocolErr     bool
+	CycleErr        bool
+	EntrypointsErr  bool
+	RejectsTez      bool
+	AccountsErr     bool
+	AccountsEmpty   bool
 }
 
 var _ rpc.IFace = &RPCMock{}
@@ -37,6 +43,19 @@ func (t *TzktMock) GetTransactions(options ...tzkt.URLParameters) ([]tzkt.Transa
 
 }
 
+func (t *TzktMock) PageTransactions(fn func([]tzkt.Transaction) error, options ...tzkt.URLParameters) error {
+	transactions, err := t.GetTransactions(options...)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(transactions); err != nil && err != tzkt.ErrStopPaging {
+		return err
+	}
+
+	return nil
+}
+
 func (t *TzktMock) GetRewardsSplit(delegate string, cycle int, options ...tzkt.URLParameters) (tzkt.RewardsSplit, error) {
 	if t.RewardsSplitErr {
 		return tzkt.RewardsSplit{}, errors.New("failed to get rewards split")
@@ -49,12 +68,58 @@ func (t *TzktMock) GetRewardsSplit(delegate string, cycle int, options ...tzkt.U
 	return rewardsSplit, nil
 }
 
+func (t *TzktMock) GetProtocol(cycle int, options ...tzkt.URLParameters) (tzkt.Protocol, error) {
+	if t.ProtocolErr {
+		return tzkt.Protocol{}, errors.New("failed to get protocol")
+	}
+
+	return Carthage, nil
+}
+
+func (t *TzktMock) GetCycle(cycle int, options ...tzkt.URLParameters) (tzkt.Cycle, error) {
+	if t.CycleErr {
+		return tzkt.Cycle{}, errors.New("failed to get cycle")
+	}
+
+	return tzkt.Cycle{Index: cycle, FirstLevel: cycle*4096 + 1, LastLevel: (cycle + 1) * 4096}, nil
+}
+
+func (t *TzktMock) GetEntrypoints(address string, options ...tzkt.URLParameters) ([]tzkt.Entrypoint, error) {
+	if t.EntrypointsErr {
+		return []tzkt.Entrypoint{}, errors.New("failed to get entrypoints")
+	}
+
+	if t.RejectsTez {
+		return []tzkt.Entrypoint{{Name: "transfer", MichelsonParameters: "pair (address :from) (pair (address :to) (nat :value))"}}, nil
+	}
+
+	return []tzkt.Entrypoint{{Name: "default", MichelsonParameters: "unit"}}, nil
+}
+
+func (t *TzktMock) GetAccounts(addresses []string, options ...tzkt.URLParameters) ([]tzkt.Account, error) {
+	if t.AccountsErr {
+		return []tzkt.Account{}, errors.New("failed to get accounts")
+	}
+
+	accounts := []tzkt.Account{}
+	if t.AccountsEmpty {
+		return accounts, nil
+	}
+
+	for _, address := range addresses {
+		accounts = append(accounts, tzkt.Account{Type: "user", Address: address, Balance: 5000000})
+	}
+
+	return accounts, nil
+}
+
 // RPCMock is a test helper mocking the go-tezos/rpc lib
 type RPCMock struct {
 	rpc.IFace
 	HeadErr               bool
 	CounterErr            bool
 	BalanceErr            bool
+	BalanceEmpty          bool
 	FrozenBalanceErr      bool
 	DelegatedContractsErr bool
 	CycleErr              bool
@@ -67,6 +132,24 @@ type RPCMock struct {
 	BigMapErr             bool
 	BakingRightsErr       bool
 	EndorsingRightsErr    bool
+	ConstantsErr          bool
+	ManagerKeyErr         bool
+	Unrevealed            bool
+	BlockErr              bool
+	Protocol              string
+}
+
+// ManagerKey -
+func (r *RPCMock) ManagerKey(blockhash, pkh string) (string, error) {
+	if r.ManagerKeyErr {
+		return "", errors.New("failed to get manager key")
+	}
+
+	if r.Unrevealed {
+		return "", nil
+	}
+
+	return "edpkuHMDkMz46HdRXYwom3xRwqk3zQ5ihWX4j8dwo2R2h8o4gPcbN5", nil
 }
 
 // EndorsingRights -
@@ -102,8 +185,50 @@ func (r *RPCMock) Head() (*rpc.Block, error) {
 	if r.HeadErr {
 		return &rpc.Block{}, errors.New("failed to get block")
 	}
+	return r.block(1130597), nil
+}
+
+// Block -
+func (r *RPCMock) Block(id interface{}) (*rpc.Block, error) {
+	if r.BlockErr {
+		return &rpc.Block{}, errors.New("failed to get block")
+	}
+	level, _ := id.(int)
+	return r.block(level), nil
+}
+
+// block returns a block at level run by Protocol, Carthage if it's empty, in cycles of 4096 blocks
+func (r *RPCMock) block(level int) *rpc.Block {
+	protocol := r.Protocol
+	if protocol == "" {
+		protocol = Carthage.Hash
+	}
+
 	return &rpc.Block{
-		Hash: "BLfEWKVudXH15N8nwHZehyLNjRuNLoJavJDjSZ7nq8ggfzbZ18p",
+		Protocol: protocol,
+		Hash:     "BLfEWKVudXH15N8nwHZehyLNjRuNLoJavJDjSZ7nq8ggfzbZ18p",
+		Header:   rpc.Header{Level: level},
+		Metadata: rpc.Metadata{
+			Protocol: protocol,
+			Level: rpc.Level{
+				Level:         level,
+				Cycle:         (level - 1) / 4096,
+				CyclePosition: (level - 1) % 4096,
+			},
+		},
+	}
+}
+
+// Constants -
+func (r *RPCMock) Constants(blockhash string) (rpc.Constants, error) {
+	if r.ConstantsErr {
+		return rpc.Constants{}, errors.New("failed to get constants")
+	}
+
+	return rpc.Constants{
+		BlocksPerCycle:  4096,
+		OriginationSize: 257,
+		CostPerByte:     250,
 	}, nil
 }
 
@@ -122,6 +247,9 @@ func (r *RPCMock) Balance(input rpc.BalanceInput) (int, error) {
 	if r.BalanceErr {
 		return 0, errors.New("failed to get balance")
 	}
+	if r.BalanceEmpty {
+		return 0, nil
+	}
 	return 5000000, nil
 }
 
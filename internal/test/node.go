@@ -0,0 +1,669 @@
+package test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/pkg/errors"
+)
+
+// NodeFixture is the recorded state of a tezos node served by Node
+type NodeFixture struct {
+	ChainID          string                                `json:"chain_id"`
+	Protocol         string                                `json:"protocol"`
+	Proto            int                                   `json:"proto"`
+	Level            int                                   `json:"level"`
+	Constants        json.RawMessage                       `json:"constants"`
+	Balances         map[string]int                        `json:"balances"`
+	SnapshotBalances map[string]int                        `json:"snapshot_balances"`
+	Counters         map[string]int                        `json:"counters"`
+	ManagerKeys      map[string]string                     `json:"manager_keys"`
+	Storage          map[string]json.RawMessage            `json:"storage"`
+	BigMaps          map[string]map[string]json.RawMessage `json:"big_maps"`
+	Delegates        map[string]DelegateFixture            `json:"delegates"`
+}
+
+// DelegateFixture is the recorded state of a delegate for the cycle of a NodeFixture
+type DelegateFixture struct {
+	StakingBalance     int                 `json:"staking_balance"`
+	DelegatedContracts []string            `json:"delegated_contracts"`
+	FrozenBalance      rpc.FrozenBalance   `json:"frozen_balance"`
+	BakingRights       rpc.BakingRights    `json:"baking_rights"`
+	EndorsingRights    rpc.EndorsingRights `json:"endorsing_rights"`
+}
+
+/*
+Node is a fake tezos node serving the subset of the RPC used by tzpay from a NodeFixture.
+
+Injected operations are decoded, checked against the source's counter and revealed key and
+kept in a mempool until the next block is baked. The counter served for a source is that of its last included operation. They're applied unless they transfer to an address set to
+fail. If AutoBake is set (the default), a block is baked whenever the head is requested while the mempool
+isn't empty, and if Advance is also set whenever the head is requested at all.
+
+The first Reorgs blocks holding operations are orphaned once a block is baked on top of them: their
+operations are undone and dropped, as if a branch without them won. If Requeue is set, they aren't dropped but
+come back to the mempool the next time an operation is injected, as if heard of again from a peer, and their
+counters stay used.
+
+If Refuse is set, the injection with that number, counted from 1, is refused.
+*/
+type Node struct {
+	*httptest.Server
+	AutoBake bool
+	Advance  bool
+	Reorgs   int
+	Requeue  bool
+	Refuse   int
+
+	mu         sync.Mutex
+	fixture    NodeFixture
+	constants  rpc.Constants
+	genesis    time.Time
+	blocks     map[int]*rpc.Block
+	hashes     map[string]int
+	mempool    []rpc.Operations
+	requeued   []rpc.Operations
+	balances   map[string]int
+	counters   map[string]int // counters used by the operations included or in the mempool
+	included   map[string]int // counters used by the operations included, which the rpc serves
+	keys       map[string]string
+	failing    map[string]bool
+	injections int
+}
+
+// LoadNode starts a Node from the node.json fixture found in dir
+func LoadNode(dir string) (*Node, error) {
+	var fixture NodeFixture
+	if err := ReadFixture("node.json", &fixture, dir); err != nil {
+		return nil, errors.Wrap(err, "failed to load node fixture")
+	}
+
+	return NewNode(fixture)
+}
+
+// NewNode starts a Node serving fixture
+func NewNode(fixture NodeFixture) (*Node, error) {
+	n := &Node{
+		AutoBake: true,
+		fixture:  fixture,
+		genesis:  time.Date(2018, 6, 30, 16, 7, 32, 0, time.UTC),
+		blocks:   map[int]*rpc.Block{},
+		hashes:   map[string]int{},
+		balances: map[string]int{},
+		counters: map[string]int{},
+		included: map[string]int{},
+		keys:     map[string]string{},
+		failing:  map[string]bool{},
+	}
+
+	if err := json.Unmarshal(fixture.Constants, &n.constants); err != nil {
+		return nil, errors.Wrap(err, "failed to parse constants")
+	}
+
+	if n.constants.BlocksPerCycle == 0 {
+		return nil, errors.New("invalid constants: blocks_per_cycle is required")
+	}
+
+	for address, balance := range fixture.Balances {
+		n.balances[address] = balance
+	}
+
+	for address, counter := range fixture.Counters {
+		n.counters[address] = counter
+		n.included[address] = counter
+	}
+
+	for address, key := range fixture.ManagerKeys {
+		n.keys[address] = key
+	}
+
+	n.Server = httptest.NewServer(n)
+	return n, nil
+}
+
+// Level returns the level of the current head
+func (n *Node) Level() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.fixture.Level
+}
+
+// Balance returns the current balance of address
+func (n *Node) Balance(address string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.balances[address]
+}
+
+// ManagerKey returns the public key address revealed, empty if it never did
+func (n *Node) ManagerKey(address string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.keys[address]
+}
+
+// SetManagerKey sets the public key address revealed, unrevealing it if key is empty
+func (n *Node) SetManagerKey(address, key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if key == "" {
+		delete(n.keys, address)
+		return
+	}
+	n.keys[address] = key
+}
+
+/*
+Fail makes transfers to address injected from now on fail when applied. As on chain, the contents of the
+operation before a failed transfer are backtracked and the ones after it skipped.
+*/
+func (n *Node) Fail(address string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.failing[address] = true
+}
+
+// Mempool returns the hashes of the operations waiting to be baked
+func (n *Node) Mempool() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var hashes []string
+	for _, op := range n.mempool {
+		hashes = append(hashes, op.Hash)
+	}
+
+	return hashes
+}
+
+// Bake advances the head by one block including every operation in the mempool
+func (n *Node) Bake() *rpc.Block {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.bake()
+}
+
+// Blocks returns every baked block holding operations, ordered by level
+func (n *Node) Blocks() []rpc.Block {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var blocks []rpc.Block
+	for level := 0; level <= n.fixture.Level; level++ {
+		if block, ok := n.blocks[level]; ok && len(block.Operations[3]) > 0 {
+			blocks = append(blocks, *block)
+		}
+	}
+
+	return blocks
+}
+
+func (n *Node) bake() *rpc.Block {
+	n.fixture.Level++
+	block := n.block(n.fixture.Level)
+
+	for _, op := range n.mempool {
+		for i := range op.Contents {
+			content := &op.Contents[i]
+			n.balances[content.Source] -= int(content.Fee)
+			n.included[content.Source] = content.Counter
+			if content.Kind == rpc.TRANSACTION && content.Metadata.OperationResults.Status == "applied" {
+				n.balances[content.Source] -= int(content.Amount)
+				n.balances[content.Destination] += int(content.Amount)
+			}
+		}
+		block.Operations[3] = append(block.Operations[3], op)
+	}
+
+	n.mempool = nil
+	n.blocks[block.Header.Level] = block
+
+	if parent, ok := n.blocks[block.Header.Level-1]; ok && n.Reorgs > 0 && len(parent.Operations[3]) > 0 {
+		n.Reorgs--
+		n.orphan(parent)
+	}
+
+	return block
+}
+
+// orphan replaces block with an empty block of another branch, undoing its operations
+func (n *Node) orphan(block *rpc.Block) {
+	operations := block.Operations[3]
+	for i := len(operations) - 1; i >= 0; i-- {
+		contents := operations[i].Contents
+		for j := len(contents) - 1; j >= 0; j-- {
+			content := contents[j]
+			n.balances[content.Source] += int(content.Fee)
+			if content.Kind == rpc.TRANSACTION && content.Metadata.OperationResults.Status == "applied" {
+				n.balances[content.Source] += int(content.Amount)
+				n.balances[content.Destination] -= int(content.Amount)
+			}
+			n.included[content.Source] = content.Counter - 1
+			if n.Requeue {
+				continue
+			}
+			if content.Kind == rpc.REVEAL {
+				delete(n.keys, content.Source)
+			}
+			n.counters[content.Source] = content.Counter - 1
+		}
+	}
+
+	if n.Requeue {
+		n.requeued = append(n.requeued, operations...)
+	}
+
+	delete(n.hashes, block.Hash)
+	block.Hash = blockHash(fmt.Sprintf("%s/%d/orphan", n.fixture.ChainID, block.Header.Level))
+	block.Operations = [][]rpc.Operations{{}, {}, {}, {}}
+	n.hashes[block.Hash] = block.Header.Level
+}
+
+func (n *Node) block(level int) *rpc.Block {
+	if block, ok := n.blocks[level]; ok {
+		return block
+	}
+
+	hash := blockHash(fmt.Sprintf("%s/%d", n.fixture.ChainID, level))
+	n.hashes[hash] = level
+
+	return &rpc.Block{
+		Protocol: n.fixture.Protocol,
+		ChainID:  n.fixture.ChainID,
+		Hash:     hash,
+		Header: rpc.Header{
+			Level:       level,
+			Proto:       n.fixture.Proto,
+			Predecessor: blockHash(fmt.Sprintf("%s/%d", n.fixture.ChainID, level-1)),
+			Timestamp:   n.genesis.Add(time.Minute * time.Duration(level)),
+		},
+		Metadata: rpc.Metadata{
+			Protocol:     n.fixture.Protocol,
+			NextProtocol: n.fixture.Protocol,
+			Level: rpc.Level{
+				Level:         level,
+				Cycle:         (level - 1) / n.constants.BlocksPerCycle,
+				CyclePosition: (level - 1) % n.constants.BlocksPerCycle,
+			},
+		},
+		Operations: [][]rpc.Operations{{}, {}, {}, {}},
+	}
+}
+
+// resolve finds the level of a block id (head, a level or a hash) on the main chain
+func (n *Node) resolve(id string) (int, bool) {
+	if id == "head" {
+		return n.fixture.Level, true
+	}
+
+	if level, err := strconv.Atoi(id); err == nil {
+		return level, level >= 0 && level <= n.fixture.Level
+	}
+
+	// every hash a client knows about was handed out by block
+	level, ok := n.hashes[id]
+	return level, ok && level <= n.fixture.Level
+}
+
+// ServeHTTP satisfies http.Handler
+func (n *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if r.Method == http.MethodPost && r.URL.Path == "/injection/operation" {
+		n.inject(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.URL.Path == "/chains/main/chain_id" {
+		writeJSON(w, n.fixture.ChainID)
+		return
+	}
+
+	if r.URL.Path == "/chains/main/is_bootstrapped" {
+		writeJSON(w, map[string]interface{}{"bootstrapped": true, "sync_state": "synced"})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "chains" || parts[1] != "main" || parts[2] != "blocks" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if parts[3] == "head" && n.AutoBake && (n.Advance || len(n.mempool) > 0) {
+		n.bake()
+	}
+
+	level, ok := n.resolve(parts[3])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	block := n.block(level)
+
+	switch path := strings.Join(parts[4:], "/"); {
+	case path == "":
+		writeJSON(w, block)
+	case path == "operations/3":
+		writeJSON(w, block.Operations[3])
+	case path == "operation_hashes":
+		var hashes [][]string
+		for _, pass := range block.Operations {
+			var out []string
+			for _, op := range pass {
+				out = append(out, op.Hash)
+			}
+			hashes = append(hashes, out)
+		}
+		writeJSON(w, hashes)
+	case path == "context/constants":
+		w.Write(n.fixture.Constants)
+	case strings.HasPrefix(path, "context/raw/json/cycle/"):
+		writeJSON(w, map[string]interface{}{
+			"random_seed":   blockHash(path),
+			"roll_snapshot": 0,
+		})
+	case strings.HasPrefix(path, "context/raw/json/contracts/index/") && len(parts) == 12 && parts[10] == "frozen_balance":
+		delegate, ok := n.fixture.Delegates[parts[9]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]string{
+			"deposits": strconv.Itoa(delegate.FrozenBalance.Deposits),
+			"fees":     strconv.Itoa(delegate.FrozenBalance.Fees),
+			"rewards":  strconv.Itoa(delegate.FrozenBalance.Rewards),
+		})
+	case strings.HasPrefix(path, "context/contracts/"):
+		n.contract(w, r, level, parts[6:])
+	case strings.HasPrefix(path, "context/delegates/"):
+		n.delegate(w, r, parts[6:])
+	case path == "helpers/baking_rights" || path == "helpers/endorsing_rights":
+		n.rights(w, r, path)
+	case strings.HasPrefix(path, "context/big_maps/") && len(parts) == 8:
+		if value, ok := n.fixture.BigMaps[parts[6]][parts[7]]; ok {
+			w.Write(value)
+			return
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (n *Node) contract(w http.ResponseWriter, r *http.Request, level int, parts []string) {
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	address := parts[0]
+	switch parts[1] {
+	case "balance":
+		balance := n.balances[address]
+		if level != n.fixture.Level {
+			if b, ok := n.fixture.SnapshotBalances[address]; ok {
+				balance = b
+			}
+		}
+		writeJSON(w, strconv.Itoa(balance))
+	case "counter":
+		writeJSON(w, strconv.Itoa(n.included[address]))
+	case "manager_key":
+		if key, ok := n.keys[address]; ok {
+			writeJSON(w, key)
+			return
+		}
+		writeJSON(w, nil)
+	case "storage":
+		if storage, ok := n.fixture.Storage[address]; ok {
+			w.Write(storage)
+			return
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (n *Node) delegate(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	delegate, ok := n.fixture.Delegates[parts[0]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch parts[1] {
+	case "staking_balance":
+		writeJSON(w, strconv.Itoa(delegate.StakingBalance))
+	case "delegated_contracts":
+		writeJSON(w, delegate.DelegatedContracts)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// rights serves the recorded rights of the delegate queried for, or of every delegate
+func (n *Node) rights(w http.ResponseWriter, r *http.Request, path string) {
+	var rights []interface{}
+	for address, delegate := range n.fixture.Delegates {
+		if query := r.URL.Query().Get("delegate"); query != "" && query != address {
+			continue
+		}
+
+		if path == "helpers/baking_rights" {
+			for _, right := range delegate.BakingRights {
+				rights = append(rights, right)
+			}
+		} else {
+			for _, right := range delegate.EndorsingRights {
+				rights = append(rights, right)
+			}
+		}
+	}
+
+	if rights == nil {
+		rights = []interface{}{}
+	}
+	writeJSON(w, rights)
+}
+
+func (n *Node) inject(w http.ResponseWriter, r *http.Request) {
+	var operation string
+	if err := json.NewDecoder(r.Body).Decode(&operation); err != nil {
+		writeRPCError(w, "invalid_operation", err.Error())
+		return
+	}
+
+	signed, err := hex.DecodeString(operation)
+	if err != nil {
+		writeRPCError(w, "invalid_operation", err.Error())
+		return
+	}
+
+	branch, contents, err := decodeOperation(signed)
+	if err != nil {
+		writeRPCError(w, "invalid_operation", err.Error())
+		return
+	}
+
+	if _, ok := n.resolve(branch); !ok {
+		writeRPCError(w, "unknown_branch", branch)
+		return
+	}
+
+	n.injections++
+	if n.injections == n.Refuse {
+		writeRPCError(w, "refused", fmt.Sprintf("injection %d is refused", n.injections))
+		return
+	}
+
+	n.mempool = append(n.mempool, n.requeued...)
+	n.requeued = nil
+
+	// a source must have revealed its key before or earlier in the operation
+	revealed := map[string]string{}
+	for _, content := range contents {
+		if content.Counter != n.counters[content.Source]+1 {
+			writeRPCError(w, "counter_in_the_past", fmt.Sprintf("expected counter %d for '%s' but got %d", n.counters[content.Source]+1, content.Source, content.Counter))
+			return
+		}
+
+		if content.Kind == rpc.REVEAL {
+			revealed[content.Source] = content.PublicKey
+		} else if _, ok := n.keys[content.Source]; !ok && revealed[content.Source] == "" {
+			writeRPCError(w, "unrevealed_key", fmt.Sprintf("unrevealed public key for manager '%s'", content.Source))
+			return
+		}
+		n.counters[content.Source] = content.Counter
+	}
+
+	for address, key := range revealed {
+		n.keys[address] = key
+	}
+
+	failed := len(contents)
+	for i, content := range contents {
+		if content.Kind == rpc.TRANSACTION && n.failing[content.Destination] {
+			failed = i
+			break
+		}
+	}
+
+	for i := range contents {
+		status, gas := "applied", int64(10207)
+		switch {
+		case i < failed && failed < len(contents):
+			status = "backtracked"
+		case i == failed:
+			status = "failed"
+			gas = 0
+		case i > failed:
+			status, gas = "skipped", 0
+		}
+
+		contents[i].Metadata = &rpc.ContentsHelperMetadata{
+			OperationResults: &rpc.OperationResultsHelper{
+				Status:      status,
+				ConsumedGas: gas,
+			},
+		}
+	}
+
+	hash := operationHash(signed)
+	n.mempool = append(n.mempool, rpc.Operations{
+		Protocol:  n.fixture.Protocol,
+		ChainID:   n.fixture.ChainID,
+		Hash:      hash,
+		Branch:    branch,
+		Contents:  contents,
+		Signature: hex.EncodeToString(signed[len(signed)-64:]),
+	})
+
+	writeJSON(w, hash)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeRPCError(w http.ResponseWriter, id, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode([]map[string]string{
+		{
+			"kind":  "temporary",
+			"id":    id,
+			"error": msg,
+		},
+	})
+}
+
+// Fixture returns the path to a recorded fixture directory in internal/test/fixtures
+func Fixture(name string) string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "fixtures", name)
+}
+
+/*
+ReadFixture reads the fixture named name into v from dirs, each overriding the ones before it: objects are merged
+key by key, a null removes the key it overrides and any other value replaces it. Dirs without the fixture are
+skipped, but one of them must have it.
+*/
+func ReadFixture(name string, v interface{}, dirs ...string) error {
+	var fixture interface{}
+	for _, dir := range dirs {
+		byts, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		var override interface{}
+		if err := json.Unmarshal(byts, &override); err != nil {
+			return errors.Wrapf(err, "failed to parse '%s'", filepath.Join(dir, name))
+		}
+		fixture = mergeFixture(fixture, override)
+	}
+
+	if fixture == nil {
+		return errors.Errorf("no '%s' found in %s", name, strings.Join(dirs, ", "))
+	}
+
+	byts, err := json.Marshal(fixture)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(byts, v)
+}
+
+func mergeFixture(fixture, override interface{}) interface{} {
+	overrides, ok := override.(map[string]interface{})
+	if !ok {
+		return override
+	}
+
+	merged, ok := fixture.(map[string]interface{})
+	if !ok {
+		merged = map[string]interface{}{}
+	}
+
+	for key, value := range overrides {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = mergeFixture(merged[key], value)
+	}
+
+	return merged
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goat-systems/go-tezos/v3/forge"
+	"github.com/goat-systems/go-tezos/v3/keys"
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Node(t *testing.T) {
+	node, err := LoadNode(Fixture("cycle-270"))
+	assert.Nil(t, err)
+	defer node.Close()
+
+	client, err := rpc.New(node.URL)
+	assert.Nil(t, err)
+
+	key, err := keys.NewKey(keys.NewKeyInput{
+		Esk:      "edesk1fddn27MaLcQVEdZpAYiyGQNm6UjtWiBfNP2ZenTy3CFsoSVJgeHM9pP9cvLJ2r5Xp2quQ5mYexW1LRKee2",
+		Password: "password12345##",
+		Kind:     keys.Ed25519,
+	})
+	assert.Nil(t, err)
+	source := key.PubKey.GetPublicKeyHash()
+
+	head, err := client.Head()
+	assert.Nil(t, err)
+	assert.Equal(t, 1130597, head.Header.Level)
+	assert.Equal(t, 276, head.Metadata.Level.Cycle)
+
+	balance, err := client.Balance(rpc.BalanceInput{Cycle: 270, Address: "tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc"})
+	assert.Nil(t, err)
+	assert.Equal(t, 185182986721, balance)
+
+	counter, err := client.Counter(head.Hash, source)
+	assert.Nil(t, err)
+	assert.Equal(t, 100, counter)
+
+	inject := func(counter int) (string, error) {
+		op, err := forge.Encode(head.Hash, rpc.Content{
+			Kind:        rpc.TRANSACTION,
+			Source:      source,
+			Fee:         2941,
+			Counter:     counter,
+			GasLimit:    26283,
+			Amount:      1000000,
+			Destination: "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC",
+		})
+		assert.Nil(t, err)
+
+		signature, err := key.Sign(keys.SignInput{Message: op})
+		assert.Nil(t, err)
+
+		return client.InjectionOperation(rpc.InjectionOperationInput{
+			Operation: fmt.Sprintf("%s%s", op, hex.EncodeToString(signature.Bytes)),
+		})
+	}
+
+	_, err = inject(counter)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "counter_in_the_past")
+
+	ophash, err := inject(counter + 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{ophash}, node.Mempool())
+
+	head, err = client.Head()
+	assert.Nil(t, err)
+	assert.Equal(t, 1130598, head.Header.Level)
+	assert.Empty(t, node.Mempool())
+
+	hashes, err := client.OperationHashes(head.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{ophash}, hashes[3])
+
+	transaction := head.Operations[3][0].Contents[0]
+	assert.Equal(t, "applied", transaction.Metadata.OperationResults.Status)
+	assert.Equal(t, int64(1000000), transaction.Amount)
+	assert.Equal(t, "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", transaction.Destination)
+
+	assert.Equal(t, 1000000000-1000000-2941, node.Balance(source))
+	assert.Equal(t, 176566401+1000000, node.Balance("KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC"))
+
+	counter, err = client.Counter(head.Hash, source)
+	assert.Nil(t, err)
+	assert.Equal(t, 101, counter)
+
+	indexer, err := LoadTzkt(Fixture("cycle-270"), node)
+	assert.Nil(t, err)
+	defer indexer.Close()
+
+	transactions, err := tzkt.NewTZKT(indexer.URL).GetTransactions(tzkt.URLParameters{Key: "sender", Value: source})
+	assert.Nil(t, err)
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, ophash, transactions[0].Hash)
+	assert.Equal(t, 1130598, transactions[0].Level)
+}
+
+func Test_ReadFixture(t *testing.T) {
+	base, err := ioutil.TempDir("", "fixture")
+	assert.Nil(t, err)
+	defer os.RemoveAll(base)
+
+	override, err := ioutil.TempDir("", "fixture")
+	assert.Nil(t, err)
+	defer os.RemoveAll(override)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(base, "node.json"), []byte(`{"level":10,"balances":{"tz1a":1,"tz1b":2},"counters":{"tz1a":3}}`), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(override, "node.json"), []byte(`{"level":20,"balances":{"tz1b":4,"tz1c":5},"counters":null}`), 0644))
+
+	var fixture NodeFixture
+	assert.Nil(t, ReadFixture("node.json", &fixture, base, override))
+	assert.Equal(t, 20, fixture.Level)
+	assert.Equal(t, map[string]int{"tz1a": 1, "tz1b": 4, "tz1c": 5}, fixture.Balances)
+	assert.Nil(t, fixture.Counters)
+
+	// dirs without the fixture are skipped
+	fixture = NodeFixture{}
+	assert.Nil(t, ReadFixture("node.json", &fixture, override, filepath.Join(base, "missing")))
+	assert.Equal(t, 20, fixture.Level)
+
+	err = ReadFixture("tzkt.json", &fixture, base, override)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "no 'tzkt.json' found")
+}
@@ -0,0 +1,380 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+/*
+TzktFixture is the recorded state of a tzkt indexer served by Tzkt. Quotes is a price history ordered by
+level, Quote is the price at every other level. Entrypoints are keyed by contract, a contract without any
+has none. Accounts are only served when Tzkt isn't backed by a Node. Protocols are ordered by first cycle,
+without any every cycle was run by Carthage. Cycles are counted from the first cycle of their protocol.
+*/
+type TzktFixture struct {
+	RewardsSplits map[string]json.RawMessage `json:"rewards_splits"`
+	Transactions  []tzkt.Transaction         `json:"transactions"`
+	Rights        tzkt.Rights                `json:"rights"`
+	Quote         struct {
+		Btc float64 `json:"btc"`
+		Eur float64 `json:"eur"`
+		Usd float64 `json:"usd"`
+	} `json:"quote"`
+	Quotes      []tzkt.Quote                 `json:"quotes"`
+	Entrypoints map[string][]tzkt.Entrypoint `json:"entrypoints"`
+	Accounts    []tzkt.Account               `json:"accounts"`
+	Protocols   []tzkt.Protocol              `json:"protocols"`
+}
+
+// Carthage is the protocol the fixtures were recorded under
+var Carthage = tzkt.Protocol{
+	Code:            6,
+	Hash:            "PsCARTHAGazKbHtnKfLzQg3kms52kSRpgnDY982a9oYsSXRLQEb",
+	FirstLevel:      851969,
+	FirstCycle:      208,
+	FirstCycleLevel: 851969,
+}
+
+/*
+Tzkt is a fake tzkt api serving a TzktFixture. Rewards splits are keyed by cycle. When
+backed by a Node, the head follows the node's head, every transaction baked by the node
+is indexed alongside the recorded transactions and accounts have the node's balances.
+*/
+type Tzkt struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	fixture TzktFixture
+	node    *Node
+}
+
+// LoadTzkt starts a Tzkt from the tzkt.json fixture found in dir
+func LoadTzkt(dir string, node *Node) (*Tzkt, error) {
+	var fixture TzktFixture
+	if err := ReadFixture("tzkt.json", &fixture, dir); err != nil {
+		return nil, errors.Wrap(err, "failed to load tzkt fixture")
+	}
+
+	return NewTzkt(fixture, node), nil
+}
+
+// NewTzkt starts a Tzkt serving fixture
+func NewTzkt(fixture TzktFixture, node *Node) *Tzkt {
+	t := &Tzkt{
+		fixture: fixture,
+		node:    node,
+	}
+
+	t.Server = httptest.NewServer(t)
+	return t
+}
+
+// ServeHTTP satisfies http.Handler
+func (t *Tzkt) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/v1/head":
+		writeJSON(w, t.head())
+	case len(parts) == 5 && parts[1] == "rewards" && parts[2] == "split":
+		split, ok := t.fixture.RewardsSplits[parts[4]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, pageDelegators(r, split))
+	case len(parts) == 4 && parts[1] == "protocols" && parts[2] == "cycles":
+		cycle, err := strconv.Atoi(parts[3])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, t.protocol(cycle))
+	case len(parts) == 3 && parts[1] == "cycles":
+		cycle, err := strconv.Atoi(parts[2])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, t.cycle(cycle))
+	case r.URL.Path == "/v1/operations/transactions":
+		writeJSON(w, page(r, t.transactions(r)))
+	case r.URL.Path == "/v1/quotes":
+		writeJSON(w, t.quotes(r))
+	case len(parts) == 4 && parts[1] == "contracts" && parts[3] == "entrypoints":
+		entrypoints := t.fixture.Entrypoints[parts[2]]
+		if entrypoints == nil {
+			entrypoints = []tzkt.Entrypoint{}
+		}
+		writeJSON(w, entrypoints)
+	case r.URL.Path == "/v1/accounts":
+		writeJSON(w, t.accounts(r))
+	case r.URL.Path == "/v1/rights":
+		offset, limit := bounds(r, len(t.fixture.Rights))
+		writeJSON(w, append(tzkt.Rights{}, t.fixture.Rights[offset:limit]...))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (t *Tzkt) head() tzkt.Head {
+	head := tzkt.Head{
+		Synced:   true,
+		QuoteBtc: t.fixture.Quote.Btc,
+		QuoteEur: t.fixture.Quote.Eur,
+		QuoteUsd: t.fixture.Quote.Usd,
+	}
+
+	if t.node != nil {
+		t.node.mu.Lock()
+		block := t.node.block(t.node.fixture.Level)
+		t.node.mu.Unlock()
+
+		head.Level = block.Header.Level
+		head.KnownLevel = block.Header.Level
+		head.QuoteLevel = block.Header.Level
+		head.Hash = block.Hash
+		head.Protocol = block.Protocol
+		head.Timestamp = block.Header.Timestamp
+		head.LastSync = block.Header.Timestamp
+	}
+
+	return head
+}
+
+// protocol returns the last protocol to start by cycle
+func (t *Tzkt) protocol(cycle int) tzkt.Protocol {
+	protocol := Carthage
+	for _, p := range t.fixture.Protocols {
+		if p.FirstCycle <= cycle {
+			protocol = p
+		}
+	}
+
+	return protocol
+}
+
+// cycle returns the levels of cycle, counted from the first cycle of its protocol with the node's blocks per cycle
+func (t *Tzkt) cycle(cycle int) tzkt.Cycle {
+	blocksPerCycle := 4096
+	if t.node != nil {
+		t.node.mu.Lock()
+		blocksPerCycle = t.node.constants.BlocksPerCycle
+		t.node.mu.Unlock()
+	}
+
+	protocol := t.protocol(cycle)
+	firstLevel := protocol.FirstCycleLevel + (cycle-protocol.FirstCycle)*blocksPerCycle
+	return tzkt.Cycle{
+		Index:      cycle,
+		FirstLevel: firstLevel,
+		LastLevel:  firstLevel + blocksPerCycle - 1,
+	}
+}
+
+// accounts supports looking up accounts by address (address.in), accounts that were never allocated aren't found
+func (t *Tzkt) accounts(r *http.Request) []tzkt.Account {
+	accounts := append([]tzkt.Account{}, t.fixture.Accounts...)
+	if t.node != nil {
+		accounts = []tzkt.Account{}
+		t.node.mu.Lock()
+		for address, balance := range t.node.balances {
+			if balance > 0 {
+				kind := "user"
+				if strings.HasPrefix(address, "KT1") {
+					kind = "contract"
+				}
+				accounts = append(accounts, tzkt.Account{Type: kind, Address: address, Balance: balance})
+			}
+		}
+		t.node.mu.Unlock()
+	}
+
+	out := []tzkt.Account{}
+	addresses := strings.Split(r.URL.Query().Get("address.in"), ",")
+	for _, account := range accounts {
+		if contains(addresses, account.Address) {
+			out = append(out, account)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+
+	return out
+}
+
+// quotes supports looking up the latest quote at or before a level (level.le, sort.desc=level, limit=1)
+func (t *Tzkt) quotes(r *http.Request) []tzkt.Quote {
+	level, err := strconv.Atoi(r.URL.Query().Get("level.le"))
+	if err != nil {
+		level = t.head().Level
+	}
+
+	quote := tzkt.Quote{
+		Level: level,
+		Btc:   t.fixture.Quote.Btc,
+		Eur:   t.fixture.Quote.Eur,
+		Usd:   t.fixture.Quote.Usd,
+	}
+
+	for _, q := range t.fixture.Quotes {
+		if q.Level <= level {
+			quote = q
+		}
+	}
+
+	return []tzkt.Quote{quote}
+}
+
+func (t *Tzkt) transactions(r *http.Request) []tzkt.Transaction {
+	transactions := append([]tzkt.Transaction{}, t.fixture.Transactions...)
+	if t.node != nil {
+		for _, block := range t.node.Blocks() {
+			for _, op := range block.Operations[3] {
+				for _, content := range op.Contents {
+					if content.Kind != rpc.TRANSACTION {
+						continue
+					}
+
+					var transaction tzkt.Transaction
+					transaction.Type = "transaction"
+					transaction.ID = len(transactions) + 1
+					transaction.Level = block.Header.Level
+					transaction.Timestamp = block.Header.Timestamp
+					transaction.Block = block.Hash
+					transaction.Hash = op.Hash
+					transaction.Counter = content.Counter
+					transaction.Sender.Address = content.Source
+					transaction.Target.Address = content.Destination
+					transaction.Amount = int(content.Amount)
+					transaction.BakerFee = int(content.Fee)
+					transaction.GasLimit = int(content.GasLimit)
+					transaction.StorageLimit = int(content.StorageLimit)
+					transaction.Status = content.Metadata.OperationResults.Status
+					transaction.Quote.Btc = t.fixture.Quote.Btc
+					transaction.Quote.Eur = t.fixture.Quote.Eur
+					transaction.Quote.Usd = t.fixture.Quote.Usd
+					transactions = append(transactions, transaction)
+				}
+			}
+		}
+	}
+
+	query := r.URL.Query()
+	var out []tzkt.Transaction
+	for _, transaction := range transactions {
+		if v := query.Get("sender"); v != "" && transaction.Sender.Address != v {
+			continue
+		}
+		if v := query.Get("target"); v != "" && transaction.Target.Address != v {
+			continue
+		}
+		if v := query.Get("target.in"); v != "" && !contains(strings.Split(v, ","), transaction.Target.Address) {
+			continue
+		}
+		if v := query.Get("parameters.as"); v != "" && !matches(v, transaction.Parameters) {
+			continue
+		}
+		if v, err := strconv.Atoi(query.Get("level.ge")); err == nil && transaction.Level < v {
+			continue
+		}
+		if v, err := strconv.Atoi(query.Get("level.le")); err == nil && transaction.Level > v {
+			continue
+		}
+		if v, err := strconv.Atoi(query.Get("offset.cr")); err == nil && transaction.ID <= v {
+			continue
+		}
+		out = append(out, transaction)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out
+}
+
+// page applies tzkt's offset and limit query parameters (default limit 100)
+func page(r *http.Request, transactions []tzkt.Transaction) []tzkt.Transaction {
+	offset, limit := bounds(r, len(transactions))
+	return append([]tzkt.Transaction{}, transactions[offset:limit]...)
+}
+
+// pageDelegators applies tzkt's offset and limit query parameters to the delegators of a rewards split
+func pageDelegators(r *http.Request, split json.RawMessage) map[string]json.RawMessage {
+	var fields map[string]json.RawMessage
+	var delegators []json.RawMessage
+	json.Unmarshal(split, &fields)
+	json.Unmarshal(fields["delegators"], &delegators)
+
+	offset, limit := bounds(r, len(delegators))
+	fields["delegators"], _ = json.Marshal(append([]json.RawMessage{}, delegators[offset:limit]...))
+
+	return fields
+}
+
+// bounds returns the start and end of the page of n elements tzkt's offset and limit query parameters select
+func bounds(r *http.Request, n int) (int, int) {
+	query := r.URL.Query()
+
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil {
+		limit = 100
+	}
+
+	if offset > n {
+		offset = n
+	}
+	if offset+limit > n {
+		return offset, n
+	}
+
+	return offset, offset + limit
+}
+
+// matches implements tzkt's `as` mode, where '*' matches any sequence of characters
+func matches(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 {
+			return strings.HasSuffix(value, part)
+		}
+
+		index := strings.Index(value, part)
+		if index < 0 {
+			return false
+		}
+		value = value[index+len(part):]
+	}
+
+	return true
+}
+
+func contains(list []string, value string) bool {
+	for _, element := range list {
+		if element == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package trueup
+
+import (
+	"sort"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/ledger"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/pkg/errors"
+)
+
+// Entry is the true up of a single address, its difference is what it's still owed (negative if it was overpaid)
+type Entry struct {
+	Address    string `json:"address"`
+	Expected   int    `json:"expected"`
+	Actual     int    `json:"actual"`
+	Difference int    `json:"difference"`
+}
+
+/*
+Report is the true up of a cycle's payout made from expected rewards against its actual rewards: the total
+rewards distributed by each, the net rewards owed to the delegators and liquidity providers by each and
+the difference still owed to them.
+*/
+type Report struct {
+	Cycle           int     `json:"cycle"`
+	ExpectedRewards int     `json:"expected_rewards"`
+	ActualRewards   int     `json:"actual_rewards"`
+	Expected        int     `json:"expected"`
+	Actual          int     `json:"actual"`
+	Difference      int     `json:"difference"`
+	Entries         []Entry `json:"entries"`
+}
+
+// Payout computes the rewards split a cycle's actual rewards pay out
+type Payout interface {
+	Execute() (tzkt.RewardsSplit, error)
+}
+
+// TrueUp compares a cycle's payout made from expected rewards with the payout its actual rewards make
+type TrueUp struct {
+	config config.Config
+	cycle  int
+	payout Payout
+}
+
+// New returns a pointer to a new TrueUp of cycle against payout, which must be computed from actual rewards and not inject
+func New(config config.Config, cycle int, payout Payout) *TrueUp {
+	return &TrueUp{
+		config: config,
+		cycle:  cycle,
+		payout: payout,
+	}
+}
+
+/*
+Execute computes the payout from the cycle's actual rewards and records what it owes every delegator and
+liquidity provider in the true up ledger, next to what the payout made from the cycle's expected rewards
+did. Trueing up a cycle again replaces what was recorded for it before.
+*/
+func (t *TrueUp) Execute() (Report, error) {
+	expectations, err := ledger.LoadExpectations(t.config.Baker.TrueUpLedger)
+	if err != nil {
+		return Report{Cycle: t.cycle}, errors.Wrapf(err, "failed to true up cycle %d", t.cycle)
+	}
+
+	rewardsSplit, err := t.payout.Execute()
+	if err != nil {
+		return Report{Cycle: t.cycle}, errors.Wrapf(err, "failed to true up cycle %d", t.cycle)
+	}
+
+	expectation, err := expectations.TrueUp(rewardsSplit)
+	if err != nil {
+		return Report{Cycle: t.cycle}, err
+	}
+
+	if err := expectations.Save(); err != nil {
+		return Report{Cycle: t.cycle}, errors.Wrapf(err, "failed to true up cycle %d", t.cycle)
+	}
+
+	return NewReport(expectation), nil
+}
+
+// NewReport returns the report of a trued up expectation, with an entry per address sorted by address
+func NewReport(expectation ledger.Expectation) Report {
+	report := Report{
+		Cycle:           expectation.Cycle,
+		ExpectedRewards: expectation.Rewards,
+		ActualRewards:   expectation.ActualRewards,
+	}
+
+	addresses := map[string]struct{}{}
+	for address := range expectation.Expected {
+		addresses[address] = struct{}{}
+	}
+	for address := range expectation.Actual {
+		addresses[address] = struct{}{}
+	}
+
+	for address := range addresses {
+		entry := Entry{
+			Address:  address,
+			Expected: expectation.Expected[address],
+			Actual:   expectation.Actual[address],
+		}
+		entry.Difference = entry.Actual - entry.Expected
+
+		report.Expected += entry.Expected
+		report.Actual += entry.Actual
+		report.Difference += entry.Difference
+		report.Entries = append(report.Entries, entry)
+	}
+
+	sort.Slice(report.Entries, func(i, j int) bool { return report.Entries[i].Address < report.Entries[j].Address })
+
+	return report
+}
@@ -0,0 +1,122 @@
+package trueup
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goat-systems/tzpay/v3/internal/config"
+	"github.com/goat-systems/tzpay/v3/internal/ledger"
+	"github.com/goat-systems/tzpay/v3/internal/test"
+	"github.com/goat-systems/tzpay/v3/internal/tzkt"
+	"github.com/stretchr/testify/assert"
+)
+
+type payoutMock struct {
+	rewardsSplit tzkt.RewardsSplit
+	err          error
+}
+
+func (p *payoutMock) Execute() (tzkt.RewardsSplit, error) {
+	return p.rewardsSplit, p.err
+}
+
+func Test_Execute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trueup")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "true-up.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"cycles":{"270":{"cycle":270,"rewards":3000,"expected":{"tz1a":400,"tz1b":2000}}}}`), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{`), 0644))
+
+	actual := tzkt.RewardsSplit{
+		Cycle:      270,
+		Accounting: &tzkt.Accounting{Total: 2900},
+		Delegators: tzkt.Delegators{
+			{Address: "tz1a", NetRewards: 380, Status: tzkt.Paid},
+			{Address: "tz1b", NetRewards: 2100, Status: tzkt.Paid},
+			{Address: "tz1c", NetRewards: 15, Status: tzkt.Paid},
+		},
+	}
+
+	type want struct {
+		err      bool
+		contains string
+		report   Report
+	}
+
+	cases := []struct {
+		name   string
+		cycle  int
+		ledger string
+		payout *payoutMock
+		want   want
+	}{
+		{
+			"is successful",
+			270,
+			path,
+			&payoutMock{rewardsSplit: actual},
+			want{
+				false,
+				"",
+				Report{
+					Cycle:           270,
+					ExpectedRewards: 3000,
+					ActualRewards:   2900,
+					Expected:        2400,
+					Actual:          2495,
+					Difference:      95,
+					Entries: []Entry{
+						{Address: "tz1a", Expected: 400, Actual: 380, Difference: -20},
+						{Address: "tz1b", Expected: 2000, Actual: 2100, Difference: 100},
+						{Address: "tz1c", Actual: 15, Difference: 15},
+					},
+				},
+			},
+		},
+		{
+			"handles cycle not paid from expected rewards",
+			271,
+			path,
+			&payoutMock{rewardsSplit: tzkt.RewardsSplit{Cycle: 271}},
+			want{true, "failed to true up cycle 271: no payout was made from its expected rewards", Report{Cycle: 271}},
+		},
+		{
+			"handles failure to compute payout",
+			270,
+			path,
+			&payoutMock{err: errors.New("failed to construct")},
+			want{true, "failed to true up cycle 270: failed to construct", Report{Cycle: 270}},
+		},
+		{
+			"handles bad ledger",
+			270,
+			filepath.Join(dir, "bad.json"),
+			&payoutMock{rewardsSplit: actual},
+			want{true, "failed to true up cycle 270: failed to load ledger", Report{Cycle: 270}},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := New(config.Config{Baker: config.Baker{TrueUpLedger: tt.ledger}}, tt.cycle, tt.payout).Execute()
+			test.CheckErr(t, tt.want.err, tt.want.contains, err)
+			assert.Equal(t, tt.want.report, report)
+		})
+	}
+
+	// the actual amounts are recorded next to the expected ones
+	expectations, err := ledger.LoadExpectations(path)
+	assert.Nil(t, err)
+	assert.Equal(t, ledger.Expectation{
+		Cycle:         270,
+		Rewards:       3000,
+		Expected:      map[string]int{"tz1a": 400, "tz1b": 2000},
+		ActualRewards: 2900,
+		Actual:        map[string]int{"tz1a": 380, "tz1b": 2100, "tz1c": 15},
+	}, expectations.Cycles[270])
+}
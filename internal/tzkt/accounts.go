@@ -0,0 +1,58 @@
+package tzkt
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// accountsPerRequest is the number of addresses looked up per request, keeping urls short enough for any server
+const accountsPerRequest = 100
+
+/*
+Account -
+See: https://api.tzkt.io/#operation/Accounts_Get
+*/
+type Account struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+	Balance int    `json:"balance"`
+}
+
+/*
+GetAccounts returns the accounts of addresses, looked up in bulk. Addresses that were never allocated
+aren't returned, and emptied implicit accounts have a zero balance.
+See: https://api.tzkt.io/#operation/Accounts_Get
+*/
+func (t *Tzkt) GetAccounts(addresses []string, options ...URLParameters) ([]Account, error) {
+	accounts := []Account{}
+	for len(addresses) > 0 {
+		n := accountsPerRequest
+		if len(addresses) < n {
+			n = len(addresses)
+		}
+
+		opts := append([]URLParameters{
+			{Key: "address.in", Value: strings.Join(addresses[:n], ",")},
+			{Key: "select", Value: "type,address,balance"},
+			{Key: "limit", Value: strconv.Itoa(n)},
+		}, options...)
+
+		resp, err := t.get("/v1/accounts", opts...)
+		if err != nil {
+			return []Account{}, errors.Wrap(err, "failed to get accounts")
+		}
+
+		var page []Account
+		if err := json.Unmarshal(resp, &page); err != nil {
+			return []Account{}, errors.Wrap(err, "failed to get accounts")
+		}
+
+		accounts = append(accounts, page...)
+		addresses = addresses[n:]
+	}
+
+	return accounts, nil
+}
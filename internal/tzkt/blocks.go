@@ -16,9 +16,9 @@ type Head struct {
 	LastSync   time.Time `json:"lastSync"`
 	Synced     bool      `json:"synced"`
 	QuoteLevel int       `json:"quoteLevel"`
-	QuoteBtc   int       `json:"quoteBtc"`
-	QuoteEur   int       `json:"quoteEur"`
-	QuoteUsd   int       `json:"quoteUsd"`
+	QuoteBtc   float64   `json:"quoteBtc"`
+	QuoteEur   float64   `json:"quoteEur"`
+	QuoteUsd   float64   `json:"quoteUsd"`
 }
 
 type Blocks []struct {
@@ -49,9 +49,9 @@ type Blocks []struct {
 		Slots   int `json:"slots"`
 		Rewards int `json:"rewards"`
 		Quote   struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"endorsements"`
 	Proposals []struct {
@@ -78,9 +78,9 @@ type Blocks []struct {
 		Rolls      int  `json:"rolls"`
 		Duplicated bool `json:"duplicated"`
 		Quote      struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"proposals"`
 	Ballots []struct {
@@ -107,9 +107,9 @@ type Blocks []struct {
 		Rolls int    `json:"rolls"`
 		Vote  string `json:"vote"`
 		Quote struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"ballots"`
 	Activations []struct {
@@ -125,9 +125,9 @@ type Blocks []struct {
 		} `json:"account"`
 		Balance int `json:"balance"`
 		Quote   struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"activations"`
 	DoubleBaking []struct {
@@ -151,9 +151,9 @@ type Blocks []struct {
 		OffenderLostRewards  int `json:"offenderLostRewards"`
 		OffenderLostFees     int `json:"offenderLostFees"`
 		Quote                struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"doubleBaking"`
 	DoubleEndorsing []struct {
@@ -177,9 +177,9 @@ type Blocks []struct {
 		OffenderLostRewards  int `json:"offenderLostRewards"`
 		OffenderLostFees     int `json:"offenderLostFees"`
 		Quote                struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"doubleEndorsing"`
 	NonceRevelations []struct {
@@ -200,9 +200,9 @@ type Blocks []struct {
 		} `json:"sender"`
 		RevealedLevel int `json:"revealedLevel"`
 		Quote         struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"nonceRevelations"`
 	Delegations []struct {
@@ -238,9 +238,9 @@ type Blocks []struct {
 			Type string `json:"type"`
 		} `json:"errors"`
 		Quote struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"delegations"`
 	Originations []struct {
@@ -286,9 +286,9 @@ type Blocks []struct {
 			Address string `json:"address"`
 		} `json:"originatedContract"`
 		Quote struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"originations"`
 	Transactions []struct {
@@ -327,9 +327,9 @@ type Blocks []struct {
 		} `json:"errors"`
 		HasInternals bool `json:"hasInternals"`
 		Quote        struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"transactions"`
 	Reveals []struct {
@@ -352,15 +352,15 @@ type Blocks []struct {
 			Type string `json:"type"`
 		} `json:"errors"`
 		Quote struct {
-			Btc int `json:"btc"`
-			Eur int `json:"eur"`
-			Usd int `json:"usd"`
+			Btc float64 `json:"btc"`
+			Eur float64 `json:"eur"`
+			Usd float64 `json:"usd"`
 		} `json:"quote"`
 	} `json:"reveals"`
 	Quote struct {
-		Btc int `json:"btc"`
-		Eur int `json:"eur"`
-		Usd int `json:"usd"`
+		Btc float64 `json:"btc"`
+		Eur float64 `json:"eur"`
+		Usd float64 `json:"usd"`
 	} `json:"quote"`
 }
 
@@ -3,6 +3,7 @@ package tzkt
 import (
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net"
 	"net/http"
 	"strings"
@@ -13,7 +14,6 @@ import (
 
 type client interface {
 	Do(req *http.Request) (*http.Response, error)
-	CloseIdleConnections()
 }
 
 type URLParameters struct {
@@ -23,29 +23,90 @@ type URLParameters struct {
 
 type IFace interface {
 	GetTransactions(options ...URLParameters) ([]Transaction, error)
+	PageTransactions(fn func([]Transaction) error, options ...URLParameters) error
 	GetRewardsSplit(delegate string, cycle int, options ...URLParameters) (RewardsSplit, error)
+	GetProtocol(cycle int, options ...URLParameters) (Protocol, error)
+	GetCycle(cycle int, options ...URLParameters) (Cycle, error)
 	GetRights(options ...URLParameters) (Rights, error)
+	PageRights(fn func(Rights) error, options ...URLParameters) error
 	GetHead() (Head, error)
 	GetBlocks(options ...URLParameters) (Blocks, error)
+	PageBlocks(fn func(Blocks) error, options ...URLParameters) error
+	GetQuotes(options ...URLParameters) ([]Quote, error)
+	GetEntrypoints(address string, options ...URLParameters) ([]Entrypoint, error)
+	GetAccounts(addresses []string, options ...URLParameters) ([]Account, error)
+}
+
+/*
+Options configures how a Tzkt paces and retries its requests. Failed requests are retried up to Retries
+times after a jittered exponential backoff starting at MinBackoff and capped at MaxBackoff, or after the
+delay the api asks for with Retry-After. At most RateLimit requests are made per second, zero for no limit.
+Durations left zero take their DefaultOptions value. Requests are sent with Transport if set, e.g. to fail
+over between several endpoints of the api.
+*/
+type Options struct {
+	Timeout    time.Duration
+	Retries    int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	RateLimit  float64
+	Transport  http.RoundTripper
+}
+
+// DefaultOptions stay well within the limits of the public api at api.tzkt.io
+var DefaultOptions = Options{
+	Timeout:    10 * time.Second,
+	Retries:    3,
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 30 * time.Second,
+	RateLimit:  10,
 }
 
 type Tzkt struct {
-	client client
-	Host   string
+	client  client
+	options Options
+	limiter *limiter
+	sleep   func(time.Duration)
+	Host    string
 }
 
+// NewTZKT returns a pointer to a new Tzkt for host with the DefaultOptions
 func NewTZKT(host string) *Tzkt {
+	return NewTZKTWithOptions(host, DefaultOptions)
+}
+
+// NewTZKTWithOptions returns a pointer to a new Tzkt for host, retrying and pacing requests as options say
+func NewTZKTWithOptions(host string, options Options) *Tzkt {
+	if options.Timeout == 0 {
+		options.Timeout = DefaultOptions.Timeout
+	}
+	if options.MinBackoff == 0 {
+		options.MinBackoff = DefaultOptions.MinBackoff
+	}
+	if options.MaxBackoff == 0 {
+		options.MaxBackoff = DefaultOptions.MaxBackoff
+	}
+
+	transport := options.Transport
+	if transport == nil {
+		transport = &http.Transport{
+			Dial: (&net.Dialer{
+				Timeout: 10 * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: 4,
+		}
+	}
+
 	return &Tzkt{
 		client: &http.Client{
-			Timeout: time.Second * 10,
-			Transport: &http.Transport{
-				Dial: (&net.Dialer{
-					Timeout: 10 * time.Second,
-				}).Dial,
-				TLSHandshakeTimeout: 10 * time.Second,
-			},
+			Timeout:   options.Timeout,
+			Transport: transport,
 		},
-		Host: cleanseHost(host),
+		options: options,
+		limiter: newLimiter(options.RateLimit),
+		sleep:   time.Sleep,
+		Host:    cleanseHost(host),
 	}
 }
 
@@ -57,15 +118,26 @@ func (t *Tzkt) get(path string, opts ...URLParameters) ([]byte, error) {
 
 	constructQueryParams(req, opts...)
 
-	return t.do(req)
+	// every request is a GET, so a failed one can safely be made again
+	for attempt := 0; ; attempt++ {
+		byts, err := t.do(req)
+		if err == nil || attempt >= t.options.Retries || !retryable(err) {
+			return byts, err
+		}
+
+		t.sleep(t.backoff(attempt, err))
+	}
 }
 
 func (t *Tzkt) do(req *http.Request) ([]byte, error) {
+	t.limiter.wait(t.sleep)
+
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := t.client.Do(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to complete request")
 	}
+	defer resp.Body.Close()
 
 	byts, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -73,14 +145,38 @@ func (t *Tzkt) do(req *http.Request) ([]byte, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return byts, fmt.Errorf("response returned code %d with body %s", resp.StatusCode, string(byts))
+		return byts, &StatusError{
+			Code:       resp.StatusCode,
+			Body:       string(byts),
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
-	t.client.CloseIdleConnections()
-
 	return byts, nil
 }
 
+// backoff returns how long to wait before retrying after attempt failed with err
+func (t *Tzkt) backoff(attempt int, err error) time.Duration {
+	if statusErr, ok := errors.Cause(err).(*StatusError); ok && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter
+	}
+
+	backoff := t.options.MinBackoff
+	for i := 0; i < attempt && backoff < t.options.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > t.options.MaxBackoff {
+		backoff = t.options.MaxBackoff
+	}
+
+	// wait between half and all of the backoff so clients failing together don't retry together
+	if half := int64(backoff / 2); half > 0 {
+		return time.Duration(half + rand.Int63n(half+1))
+	}
+
+	return backoff
+}
+
 func constructQueryParams(req *http.Request, opts ...URLParameters) {
 	q := req.URL.Query()
 	for _, opt := range opts {
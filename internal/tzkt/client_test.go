@@ -0,0 +1,125 @@
+package tzkt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_get(t *testing.T) {
+	type want struct {
+		err         bool
+		contains    string
+		attempts    int
+		sleeps      []time.Duration
+		notFound    bool
+		rateLimited bool
+		serverError bool
+	}
+
+	cases := []struct {
+		name     string
+		statuses []int
+		header   string
+		want     want
+	}{
+		{"is successful", []int{200}, "", want{false, "", 1, nil, false, false, false}},
+		{"retries server errors", []int{502, 503, 200}, "", want{false, "", 3, []time.Duration{time.Second, 2 * time.Second}, false, false, false}},
+		{"honours retry after", []int{429, 200}, "7", want{false, "", 2, []time.Duration{7 * time.Second}, false, false, false}},
+		{"gives up after retries", []int{500, 500, 500, 500, 500}, "", want{true, "response returned code 500", 4, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, false, false, true}},
+		{"gives up when rate limited", []int{429, 429, 429, 429}, "1", want{true, "response returned code 429", 4, []time.Duration{time.Second, time.Second, time.Second}, false, true, false}},
+		{"doesn't retry not found", []int{404, 200}, "", want{true, "response returned code 404", 1, nil, true, false, false}},
+		{"doesn't retry bad requests", []int{400, 200}, "", want{true, "response returned code 400", 1, nil, false, false, false}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[attempts]
+				attempts++
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(status)
+				w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			var sleeps []time.Duration
+			tzkt := NewTZKTWithOptions(server.URL, Options{Retries: 3, MinBackoff: time.Second, MaxBackoff: 4 * time.Second})
+			tzkt.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+			_, err := tzkt.get("/v1/head")
+			if tt.want.err {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), tt.want.contains)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			assert.Equal(t, tt.want.attempts, attempts)
+			assert.Len(t, sleeps, len(tt.want.sleeps))
+			for i, sleep := range sleeps {
+				if tt.header != "" {
+					assert.Equal(t, tt.want.sleeps[i], sleep)
+					continue
+				}
+
+				// jittered between half and all of the backoff
+				assert.True(t, sleep >= tt.want.sleeps[i]/2 && sleep <= tt.want.sleeps[i], "sleep %s for backoff %s", sleep, tt.want.sleeps[i])
+			}
+
+			assert.Equal(t, tt.want.notFound, IsNotFound(errors.Wrap(err, "failed to get head")))
+			assert.Equal(t, tt.want.rateLimited, IsRateLimited(err))
+			assert.Equal(t, tt.want.serverError, IsServerError(err))
+		})
+	}
+}
+
+func Test_get_retriesFailedRequests(t *testing.T) {
+	var sleeps int
+	tzkt := NewTZKTWithOptions("http://127.0.0.1:0", Options{Retries: 2})
+	tzkt.sleep = func(d time.Duration) { sleeps++ }
+
+	_, err := tzkt.get("/v1/head")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed to complete request")
+	assert.Equal(t, 2, sleeps)
+	assert.False(t, IsServerError(err))
+}
+
+func Test_limiter(t *testing.T) {
+	var sleeps []time.Duration
+	sleep := func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	limiter := newLimiter(4)
+	for i := 0; i < 3; i++ {
+		limiter.wait(sleep)
+	}
+
+	assert.Len(t, sleeps, 2)
+	assert.InDelta(t, float64(250*time.Millisecond), float64(sleeps[0]), float64(50*time.Millisecond))
+	assert.InDelta(t, float64(500*time.Millisecond), float64(sleeps[1]), float64(50*time.Millisecond))
+
+	sleeps = nil
+	limiter = newLimiter(0)
+	for i := 0; i < 3; i++ {
+		limiter.wait(sleep)
+	}
+	assert.Nil(t, sleeps)
+}
+
+func Test_retryAfter(t *testing.T) {
+	assert.Equal(t, 120*time.Second, retryAfter("120"))
+	assert.Equal(t, time.Duration(0), retryAfter(""))
+	assert.Equal(t, time.Duration(0), retryAfter("soon"))
+	assert.Equal(t, time.Duration(0), retryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)))
+
+	d := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, d > 58*time.Second && d <= time.Minute, "retry after %s", d)
+}
@@ -0,0 +1,42 @@
+package tzkt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Entrypoint -
+See: https://api.tzkt.io/#operation/Contracts_GetEntrypoints
+*/
+type Entrypoint struct {
+	Name                string          `json:"name"`
+	JSONParameters      json.RawMessage `json:"jsonParameters"`
+	MichelineParameters json.RawMessage `json:"michelineParameters"`
+	MichelsonParameters string          `json:"michelsonParameters"`
+	Unused              bool            `json:"unused"`
+}
+
+/*
+GetEntrypoints -
+See: https://api.tzkt.io/#operation/Contracts_GetEntrypoints
+*/
+func (t *Tzkt) GetEntrypoints(address string, options ...URLParameters) ([]Entrypoint, error) {
+	resp, err := t.get(fmt.Sprintf("/v1/contracts/%s/entrypoints", address), options...)
+	if err != nil {
+		return []Entrypoint{}, errors.Wrapf(err, "failed to get entrypoints")
+	}
+
+	var entrypoints []Entrypoint
+	if len(resp) == 0 {
+		return entrypoints, nil
+	}
+
+	if err := json.Unmarshal(resp, &entrypoints); err != nil {
+		return []Entrypoint{}, errors.Wrap(err, "failed to get entrypoints")
+	}
+
+	return entrypoints, nil
+}
@@ -0,0 +1,40 @@
+package tzkt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Cycle -
+See: https://api.tzkt.io/#operation/Cycles_GetByIndex
+*/
+type Cycle struct {
+	Index         int       `json:"index"`
+	FirstLevel    int       `json:"firstLevel"`
+	StartTime     time.Time `json:"startTime"`
+	LastLevel     int       `json:"lastLevel"`
+	EndTime       time.Time `json:"endTime"`
+	SnapshotLevel int       `json:"snapshotLevel"`
+}
+
+/*
+GetCycle -
+See: https://api.tzkt.io/#operation/Cycles_GetByIndex
+*/
+func (t *Tzkt) GetCycle(cycle int, options ...URLParameters) (Cycle, error) {
+	resp, err := t.get(fmt.Sprintf("/v1/cycles/%d", cycle), options...)
+	if err != nil {
+		return Cycle{}, errors.Wrapf(err, "failed to get cycle %d", cycle)
+	}
+
+	var c Cycle
+	if err := json.Unmarshal(resp, &c); err != nil {
+		return Cycle{}, errors.Wrapf(err, "failed to get cycle %d", cycle)
+	}
+
+	return c, nil
+}
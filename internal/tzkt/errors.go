@@ -0,0 +1,72 @@
+package tzkt
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StatusError is a response from the api that isn't a success
+type StatusError struct {
+	Code       int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (s *StatusError) Error() string {
+	return fmt.Sprintf("response returned code %d with body %s", s.Code, s.Body)
+}
+
+// IsNotFound returns true if err is the api not finding what was asked for
+func IsNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+// IsRateLimited returns true if err is the api refusing a request over its rate limit
+func IsRateLimited(err error) bool {
+	return statusCode(err) == http.StatusTooManyRequests
+}
+
+// IsServerError returns true if err is the api failing to serve a request
+func IsServerError(err error) bool {
+	return statusCode(err) >= http.StatusInternalServerError
+}
+
+func statusCode(err error) int {
+	if statusErr, ok := errors.Cause(err).(*StatusError); ok {
+		return statusErr.Code
+	}
+
+	return 0
+}
+
+// retryable returns true if a request that failed with err may succeed if made again
+func retryable(err error) bool {
+	if _, ok := errors.Cause(err).(*StatusError); !ok {
+		return true // the request didn't complete
+	}
+
+	return IsRateLimited(err) || IsServerError(err) || statusCode(err) == http.StatusRequestTimeout
+}
+
+// retryAfter parses a Retry-After header, either a number of seconds or an http date
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,42 @@
+package tzkt
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter spaces requests evenly so that no more than a rate are made per second
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newLimiter returns a limiter for rate requests per second, which doesn't limit anything if rate isn't positive
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return &limiter{}
+	}
+
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait reserves the next slot for a request and sleeps until it comes
+func (l *limiter) wait(sleep func(time.Duration)) {
+	if l.interval == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	slot := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if d := slot.Sub(now); d > 0 {
+		sleep(d)
+	}
+}
@@ -45,12 +45,8 @@ type Transaction struct {
 	Errors     []struct {
 		Type string `json:"type"`
 	} `json:"errors"`
-	HasInternals bool `json:"hasInternals"`
-	Quote        struct {
-		Btc int `json:"btc"`
-		Eur int `json:"eur"`
-		Usd int `json:"usd"`
-	} `json:"quote"`
+	HasInternals bool  `json:"hasInternals"`
+	Quote        Quote `json:"quote"`
 }
 
 /*
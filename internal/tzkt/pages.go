@@ -0,0 +1,146 @@
+package tzkt
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// pageSize is the number of elements requested per page when paging through a list endpoint
+const pageSize = 1000
+
+// ErrStopPaging is returned by a page callback to stop paging without an error
+var ErrStopPaging = errors.New("stop paging")
+
+/*
+pages requests path a page at a time and hands every page to fn, which returns the number of elements in
+the page and the id of the last one. With byID set the pages are ordered by id and each one starts after
+the last id seen (tzkt's cursor paging), which is stable while new operations are indexed; otherwise they
+are paged by offset. Paging stops at the first empty page, whatever page size the api allows. The caller's
+limit, offset and sort options are replaced by the paging's own.
+*/
+func (t *Tzkt) pages(path string, byID bool, fn func(resp []byte) (int, int, error), options ...URLParameters) error {
+	opts := unpaged(options)
+
+	var offset, lastID int
+	for {
+		page := append([]URLParameters{{Key: "limit", Value: strconv.Itoa(pageSize)}}, opts...)
+		if byID {
+			page = append(page, URLParameters{Key: "sort.asc", Value: "id"})
+			if offset > 0 {
+				page = append(page, URLParameters{Key: "offset.cr", Value: strconv.Itoa(lastID)})
+			}
+		} else if offset > 0 {
+			page = append(page, URLParameters{Key: "offset", Value: strconv.Itoa(offset)})
+		}
+
+		resp, err := t.get(path, page...)
+		if err != nil {
+			return err
+		}
+
+		n, id, err := fn(resp)
+		if err == ErrStopPaging {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return nil
+		}
+
+		offset += n
+		lastID = id
+	}
+}
+
+// unpaged returns options without their limit, offset and sort options, which a paging sets itself
+func unpaged(options []URLParameters) []URLParameters {
+	var opts []URLParameters
+	for _, option := range options {
+		switch option.Key {
+		case "limit", "offset", "offset.cr", "sort", "sort.asc", "sort.desc":
+		default:
+			opts = append(opts, option)
+		}
+	}
+
+	return opts
+}
+
+/*
+PageTransactions calls fn with the transactions matching options a page at a time, ordered by id, until
+they are all seen or fn returns an error. Returning ErrStopPaging stops paging without an error.
+See: https://api.tzkt.io/#operation/Operations_GetTransactions
+*/
+func (t *Tzkt) PageTransactions(fn func([]Transaction) error, options ...URLParameters) error {
+	err := t.pages("/v1/operations/transactions", true, func(resp []byte) (int, int, error) {
+		var transactions []Transaction
+		if err := json.Unmarshal(resp, &transactions); err != nil {
+			return 0, 0, err
+		}
+
+		if len(transactions) == 0 {
+			return 0, 0, nil
+		}
+
+		return len(transactions), transactions[len(transactions)-1].ID, fn(transactions)
+	}, options...)
+	if err != nil {
+		return errors.Wrap(err, "failed to get transactions")
+	}
+
+	return nil
+}
+
+/*
+PageRights calls fn with the rights matching options a page at a time until they are all seen or fn
+returns an error. Returning ErrStopPaging stops paging without an error.
+See: https://api.tzkt.io/#operation/Rights_Get
+*/
+func (t *Tzkt) PageRights(fn func(Rights) error, options ...URLParameters) error {
+	err := t.pages("/v1/rights", false, func(resp []byte) (int, int, error) {
+		var rights Rights
+		if err := json.Unmarshal(resp, &rights); err != nil {
+			return 0, 0, err
+		}
+
+		if len(rights) == 0 {
+			return 0, 0, nil
+		}
+
+		return len(rights), 0, fn(rights)
+	}, options...)
+	if err != nil {
+		return errors.Wrap(err, "failed to get rights")
+	}
+
+	return nil
+}
+
+/*
+PageBlocks calls fn with the blocks matching options a page at a time until they are all seen or fn
+returns an error. Returning ErrStopPaging stops paging without an error.
+See: https://api.tzkt.io/#operation/Blocks_Get
+*/
+func (t *Tzkt) PageBlocks(fn func(Blocks) error, options ...URLParameters) error {
+	err := t.pages("/v1/blocks", false, func(resp []byte) (int, int, error) {
+		var blocks Blocks
+		if err := json.Unmarshal(resp, &blocks); err != nil {
+			return 0, 0, err
+		}
+
+		if len(blocks) == 0 {
+			return 0, 0, nil
+		}
+
+		return len(blocks), 0, fn(blocks)
+	}, options...)
+	if err != nil {
+		return errors.Wrap(err, "failed to get blocks")
+	}
+
+	return nil
+}
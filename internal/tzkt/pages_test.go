@@ -0,0 +1,122 @@
+package tzkt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PageTransactions(t *testing.T) {
+	var requests int
+
+	// serves 2500 transactions with ids 2, 4, ... in pages of at most 400, by offset or cursor
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		query := r.URL.Query()
+		if query.Get("sender") == "bad" {
+			w.Write([]byte(`[{"id":"1"}]`))
+			return
+		}
+
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		if limit > 400 {
+			limit = 400
+		}
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		if cursor, err := strconv.Atoi(query.Get("offset.cr")); err == nil {
+			offset = cursor / 2
+		}
+
+		transactions := []Transaction{}
+		for id := (offset + 1) * 2; id <= 5000 && len(transactions) < limit; id += 2 {
+			transactions = append(transactions, Transaction{ID: id})
+		}
+
+		json.NewEncoder(w).Encode(transactions)
+	}))
+	defer server.Close()
+
+	type want struct {
+		err          bool
+		contains     string
+		transactions int
+		requests     int
+	}
+
+	cases := []struct {
+		name    string
+		fn      func([]Transaction) error
+		options []URLParameters
+		want    want
+	}{
+		{"is successful", nil, nil, want{false, "", 2500, 8}},
+		{"ignores the caller's limit and offset", nil, []URLParameters{{Key: "limit", Value: "10"}, {Key: "offset", Value: "100"}}, want{false, "", 2500, 8}},
+		{"stops paging", func(transactions []Transaction) error { return ErrStopPaging }, nil, want{false, "", 400, 1}},
+		{"handles callback failure", func(transactions []Transaction) error { return errors.New("oops") }, nil, want{true, "failed to get transactions: oops", 400, 1}},
+		{"handles bad json", nil, []URLParameters{{Key: "sender", Value: "bad"}}, want{true, "failed to get transactions", 0, 1}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+
+			var transactions []Transaction
+			err := NewTZKTWithOptions(server.URL, Options{}).PageTransactions(func(page []Transaction) error {
+				transactions = append(transactions, page...)
+				if tt.fn != nil {
+					return tt.fn(page)
+				}
+				return nil
+			}, tt.options...)
+			if tt.want.err {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), tt.want.contains)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			assert.Len(t, transactions, tt.want.transactions)
+			for i, transaction := range transactions {
+				assert.Equal(t, (i+1)*2, transaction.ID)
+			}
+			assert.Equal(t, tt.want.requests, requests)
+		})
+	}
+}
+
+func Test_PageRights(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+		var rights Rights
+		json.Unmarshal([]byte(`[{"level":1},{"level":2},{"level":3},{"level":4},{"level":5}]`), &rights)
+		if offset > len(rights) {
+			offset = len(rights)
+		}
+		if end := offset + 2; end < len(rights) {
+			rights = rights[:end]
+		}
+
+		json.NewEncoder(w).Encode(rights[offset:])
+	}))
+	defer server.Close()
+
+	var levels []int
+	err := NewTZKTWithOptions(server.URL, Options{}).PageRights(func(rights Rights) error {
+		for _, right := range rights {
+			levels = append(levels, right.Level)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, levels)
+
+	err = NewTZKTWithOptions("http://127.0.0.1:0", Options{}).PageRights(func(rights Rights) error { return nil })
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed to get rights")
+}
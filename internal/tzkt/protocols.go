@@ -0,0 +1,42 @@
+package tzkt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Protocol -
+See: https://api.tzkt.io/#operation/Protocols_GetByCycle
+*/
+type Protocol struct {
+	Code            int    `json:"code"`
+	Hash            string `json:"hash"`
+	FirstLevel      int    `json:"firstLevel"`
+	FirstCycle      int    `json:"firstCycle"`
+	FirstCycleLevel int    `json:"firstCycleLevel"`
+	LastLevel       int    `json:"lastLevel"`
+	Metadata        struct {
+		Alias string `json:"alias"`
+	} `json:"metadata"`
+}
+
+/*
+GetProtocol -
+See: https://api.tzkt.io/#operation/Protocols_GetByCycle
+*/
+func (t *Tzkt) GetProtocol(cycle int, options ...URLParameters) (Protocol, error) {
+	resp, err := t.get(fmt.Sprintf("/v1/protocols/cycles/%d", cycle), options...)
+	if err != nil {
+		return Protocol{}, errors.Wrapf(err, "failed to get protocol of cycle %d", cycle)
+	}
+
+	var protocol Protocol
+	if err := json.Unmarshal(resp, &protocol); err != nil {
+		return Protocol{}, errors.Wrapf(err, "failed to get protocol of cycle %d", cycle)
+	}
+
+	return protocol, nil
+}
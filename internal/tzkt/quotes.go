@@ -0,0 +1,96 @@
+package tzkt
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Quote -
+See: https://api.tzkt.io/#operation/Quotes_Get
+*/
+type Quote struct {
+	Level     int       `json:"level"`
+	Timestamp time.Time `json:"timestamp"`
+	Btc       float64   `json:"btc"`
+	Eur       float64   `json:"eur"`
+	Usd       float64   `json:"usd"`
+	Cny       float64   `json:"cny"`
+	Jpy       float64   `json:"jpy"`
+	Krw       float64   `json:"krw"`
+	Eth       float64   `json:"eth"`
+	Gbp       float64   `json:"gbp"`
+}
+
+// Fiat holds amounts or prices of a tez keyed by lower case currency (e.g. usd)
+type Fiat map[string]float64
+
+/*
+Valuation is the value of an amount of mutez at the end of the cycle it was earned in and at the level
+it was paid out at.
+*/
+type Valuation struct {
+	CycleEnd  Fiat `json:"cycle_end,omitempty"`
+	Injection Fiat `json:"injection,omitempty"`
+}
+
+/*
+Prices returns the price of a tez in each of currencies that is a fiat currency. The btc and eth quotes aren't
+priced, as values are rounded to the cent and would round to nothing in them.
+*/
+func (q Quote) Prices(currencies ...string) Fiat {
+	all := Fiat{
+		"eur": q.Eur,
+		"usd": q.Usd,
+		"cny": q.Cny,
+		"jpy": q.Jpy,
+		"krw": q.Krw,
+		"gbp": q.Gbp,
+	}
+
+	prices := Fiat{}
+	for _, currency := range currencies {
+		currency = strings.ToLower(currency)
+		if price, ok := all[currency]; ok {
+			prices[currency] = price
+		}
+	}
+
+	return prices
+}
+
+// Value returns the value of mutez at prices, rounded to the cent
+func (f Fiat) Value(mutez int) Fiat {
+	if f == nil {
+		return nil
+	}
+
+	value := Fiat{}
+	for currency, price := range f {
+		value[currency] = math.Round(float64(mutez)/1000000*price*100) / 100
+	}
+
+	return value
+}
+
+/*
+GetQuotes -
+See: https://api.tzkt.io/#operation/Quotes_Get
+*/
+func (t *Tzkt) GetQuotes(options ...URLParameters) ([]Quote, error) {
+	resp, err := t.get("/v1/quotes", options...)
+	if err != nil {
+		return []Quote{}, errors.Wrapf(err, "failed to get quotes")
+	}
+
+	var quotes []Quote
+	if err := json.Unmarshal(resp, &quotes); err != nil {
+		return []Quote{}, errors.Wrap(err, "failed to get quotes")
+	}
+
+	return quotes, nil
+}
@@ -3,10 +3,59 @@ package tzkt
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"github.com/pkg/errors"
 )
 
+// Status is whether a delegator or liquidity provider is paid and, if not, why it's skipped
+type Status string
+
+const (
+	// Paid means the rewards are paid out
+	Paid Status = "paid"
+	// Blacklisted means the address is in the baker's blacklist
+	Blacklisted Status = "blacklisted"
+	// BelowMinimum means the rewards are below the baker's minimum payment
+	BelowMinimum Status = "below_minimum"
+	// NeedsBurn means the account is empty and paying it would burn tez for its allocation
+	NeedsBurn Status = "needs_burn"
+	// RejectsTez means the address is a contract that can't receive a plain transfer
+	RejectsTez Status = "rejects_tez"
+	// Redirected means the rewards are paid out to the liquidity providers of a dexter contract instead
+	Redirected Status = "redirected"
+	// CarriedOver means the rewards are held back and added to a later payout
+	CarriedOver Status = "carried_over"
+	// Failed means the transfer was included on chain but not applied, so the rewards are still owed
+	Failed Status = "failed"
+)
+
+// Skipped returns true if the rewards aren't paid out to the address. An unset status is paid.
+func (s Status) Skipped() bool {
+	return s != "" && s != Paid
+}
+
+// Owed returns true if the rewards aren't paid out but are still owed to the address in a later payout
+func (s Status) Owed() bool {
+	return s == CarriedOver || s == Failed
+}
+
+/*
+Receipt is the result of applying a transfer on chain. Its status is the status of the transfer (applied, failed,
+backtracked or skipped), or that of the first internal operation it triggered that wasn't applied. The gas consumed
+includes that of its internal operations.
+*/
+type Receipt struct {
+	Status              string `json:"status"`
+	ConsumedGas         int    `json:"consumed_gas"`
+	PaidStorageSizeDiff int    `json:"paid_storage_size_diff,omitempty"`
+}
+
+// Applied returns true if the transfer and every internal operation it triggered were applied
+func (r Receipt) Applied() bool {
+	return r.Status == "applied"
+}
+
 /*
 Delegators -
 See: https://api.tzkt.io/#operation/Rewards_GetRewardSplit
@@ -32,6 +81,8 @@ ExtraFields:
 type Delegator struct {
 	Address            string              `json:"address"`
 	Balance            int                 `json:"balance"`
+	DelegatedBalance   int                 `json:"delegatedBalance,omitempty"`
+	StakedBalance      int                 `json:"stakedBalance,omitempty"`
 	CurrentBalance     int                 `json:"currentBalance"`
 	Emptied            bool                `json:"emptied"`
 	NetRewards         int                 `json:"net_rewards"`
@@ -39,7 +90,15 @@ type Delegator struct {
 	Share              float64             `json:"share"`
 	Fee                int                 `json:"fee"`
 	LiquidityProviders []LiquidityProvider `json:"liquidity_providers,omitempty"`
-	BlackListed        bool                `json:"blacklisted,omitempty"`
+	Status             Status              `json:"status,omitempty"`
+	CarriedOver        int                 `json:"carried_over,omitempty"`
+	Redistributed      int                 `json:"redistributed,omitempty"`
+	BurnFee            int                 `json:"burn_fee,omitempty"`
+	NetworkFee         int                 `json:"network_fee,omitempty"`
+	Dust               int                 `json:"dust,omitempty"`
+	OperationHash      string              `json:"operation_hash,omitempty"`
+	Receipt            *Receipt            `json:"receipt,omitempty"`
+	Fiat               *Valuation          `json:"fiat,omitempty"`
 }
 
 /*
@@ -48,13 +107,90 @@ This is an extra structure to be embedded in Delegators for the purpose of payin
 out Dexter Exchange Contracts.
 */
 type LiquidityProvider struct {
-	Address      string  `json:"address"`
-	Balance      int     `json:"balance"`
-	NetRewards   int     `json:"net_rewards"`
-	GrossRewards int     `json:"gross_rewards"`
-	Share        float64 `json:"share"`
-	Fee          int     `json:"fee"`
-	BlackListed  bool    `json:"blacklisted"`
+	Address       string     `json:"address"`
+	Balance       int        `json:"balance"`
+	NetRewards    int        `json:"net_rewards"`
+	GrossRewards  int        `json:"gross_rewards"`
+	Share         float64    `json:"share"`
+	Fee           int        `json:"fee"`
+	Status        Status     `json:"status,omitempty"`
+	CarriedOver   int        `json:"carried_over,omitempty"`
+	Redistributed int        `json:"redistributed,omitempty"`
+	BurnFee       int        `json:"burn_fee,omitempty"`
+	NetworkFee    int        `json:"network_fee,omitempty"`
+	OperationHash string     `json:"operation_hash,omitempty"`
+	Receipt       *Receipt   `json:"receipt,omitempty"`
+	Fiat          *Valuation `json:"fiat,omitempty"`
+}
+
+/*
+Amount returns the net rewards plus the rewards carried over from earlier cycles and the rewards of skipped
+delegators redistributed to it, less the burn for allocating its account and the network fee of its transfer if
+they're deducted, which is what's paid out
+*/
+func (d Delegator) Amount() int {
+	return d.NetRewards + d.CarriedOver + d.Redistributed - d.BurnFee - d.NetworkFee
+}
+
+// Pending returns the rewards still owed after the payout
+func (d Delegator) Pending() int {
+	return pending(d.NetRewards, d.CarriedOver, d.Status)
+}
+
+/*
+Amount returns the net rewards plus the rewards carried over from earlier cycles and the rewards of skipped
+delegators redistributed to it, less the burn for allocating its account and the network fee of its transfer if
+they're deducted, which is what's paid out
+*/
+func (l LiquidityProvider) Amount() int {
+	return l.NetRewards + l.CarriedOver + l.Redistributed - l.BurnFee - l.NetworkFee
+}
+
+// Pending returns the rewards still owed after the payout
+func (l LiquidityProvider) Pending() int {
+	return pending(l.NetRewards, l.CarriedOver, l.Status)
+}
+
+func pending(netRewards, carriedOver int, status Status) int {
+	switch {
+	case status.Owed():
+		return netRewards + carriedOver
+	case status.Skipped():
+		return carriedOver
+	default:
+		return 0
+	}
+}
+
+/*
+Skipped is the net rewards of the delegators and liquidity providers that were skipped (e.g. blacklisted or
+below the minimum payment), rewards carried over excluded, and the policy applied to them. They're either
+kept by the baker, redistributed pro rata to the paid delegators and liquidity providers, or sent to an
+address.
+*/
+type Skipped struct {
+	Policy        string     `json:"policy"`
+	Amount        int        `json:"amount"`
+	Address       string     `json:"address,omitempty"`
+	OperationHash string     `json:"operation_hash,omitempty"`
+	Fiat          *Valuation `json:"fiat,omitempty"`
+}
+
+/*
+Accounting is the accounting policy a payout was computed with and what it distributed, in mutez: the rewards
+(and fees, if shared) earned, the rewards of future rights paid on expectation, the missed or uncovered
+rewards covered as if they were earned, the accusation rewards shared and the losses deducted, as well as
+the block fees the baker kept.
+*/
+type Accounting struct {
+	Policy      string `json:"policy"`
+	Earned      int    `json:"earned"`
+	Future      int    `json:"future,omitempty"`
+	Covered     int    `json:"covered"`
+	Accusations int    `json:"accusations"`
+	Deducted    int    `json:"deducted"`
+	KeptFees    int    `json:"kept_fees"`
+	Total       int    `json:"total"`
 }
 
 /*
@@ -62,76 +198,166 @@ RewardsSplit -
 See: https://api.tzkt.io/#operation/Rewards_GetRewardSplit
 */
 type RewardsSplit struct {
-	Cycle                       int        `json:"cycle"`
-	StakingBalance              int        `json:"stakingBalance"`
-	DelegatedBalance            int        `json:"delegatedBalance"`
-	NumDelegators               int        `json:"numDelegators"`
-	ExpectedBlocks              float64    `json:"expectedBlocks"`
-	ExpectedEndorsements        float64    `json:"expectedEndorsements"`
-	FutureBlocks                int        `json:"futureBlocks"`
-	FutureBlockRewards          int        `json:"futureBlockRewards"`
-	FutureBlockDeposits         int        `json:"futureBlockDeposits"`
-	OwnBlocks                   int        `json:"ownBlocks"`
-	OwnBlockRewards             int        `json:"ownBlockRewards"`
-	ExtraBlocks                 int        `json:"extraBlocks"`
-	ExtraBlockRewards           int        `json:"extraBlockRewards"`
-	MissedOwnBlocks             int        `json:"missedOwnBlocks"`
-	MissedOwnBlockRewards       int        `json:"missedOwnBlockRewards"`
-	MissedExtraBlocks           int        `json:"missedExtraBlocks"`
-	MissedExtraBlockRewards     int        `json:"missedExtraBlockRewards"`
-	UncoveredOwnBlocks          int        `json:"uncoveredOwnBlocks"`
-	UncoveredOwnBlockRewards    int        `json:"uncoveredOwnBlockRewards"`
-	UncoveredExtraBlocks        int        `json:"uncoveredExtraBlocks"`
-	UncoveredExtraBlockRewards  int        `json:"uncoveredExtraBlockRewards"`
-	BlockDeposits               int        `json:"blockDeposits"`
-	FutureEndorsements          int        `json:"futureEndorsements"`
-	FutureEndorsementRewards    int        `json:"futureEndorsementRewards"`
-	FutureEndorsementDeposits   int        `json:"futureEndorsementDeposits"`
-	Endorsements                int        `json:"endorsements"`
-	EndorsementRewards          int        `json:"endorsementRewards"`
-	MissedEndorsements          int        `json:"missedEndorsements"`
-	MissedEndorsementRewards    int        `json:"missedEndorsementRewards"`
-	UncoveredEndorsements       int        `json:"uncoveredEndorsements"`
-	UncoveredEndorsementRewards int        `json:"uncoveredEndorsementRewards"`
-	EndorsementDeposits         int        `json:"endorsementDeposits"`
-	OwnBlockFees                int        `json:"ownBlockFees"`
-	ExtraBlockFees              int        `json:"extraBlockFees"`
-	MissedOwnBlockFees          int        `json:"missedOwnBlockFees"`
-	MissedExtraBlockFees        int        `json:"missedExtraBlockFees"`
-	UncoveredOwnBlockFees       int        `json:"uncoveredOwnBlockFees"`
-	UncoveredExtraBlockFees     int        `json:"uncoveredExtraBlockFees"`
-	DoubleBakingRewards         int        `json:"doubleBakingRewards"`
-	DoubleBakingLostDeposits    int        `json:"doubleBakingLostDeposits"`
-	DoubleBakingLostRewards     int        `json:"doubleBakingLostRewards"`
-	DoubleBakingLostFees        int        `json:"doubleBakingLostFees"`
-	DoubleEndorsingRewards      int        `json:"doubleEndorsingRewards"`
-	DoubleEndorsingLostDeposits int        `json:"doubleEndorsingLostDeposits"`
-	DoubleEndorsingLostRewards  int        `json:"doubleEndorsingLostRewards"`
-	DoubleEndorsingLostFees     int        `json:"doubleEndorsingLostFees"`
-	RevelationRewards           int        `json:"revelationRewards"`
-	RevelationLostRewards       int        `json:"revelationLostRewards"`
-	RevelationLostFees          int        `json:"revelationLostFees"`
-	Delegators                  Delegators `json:"delegators"`
-	OperationLink               []string   `json:"operation_links,omitempty"`
-	BakerRewards                int        `json:"baker_rewards,omitempty"`
-	BakerShare                  float64    `json:"baker_share,omitempty"`
-	BakerCollectedFees          int        `json:"collected_fees,omitempty"`
+	Cycle                       int         `json:"cycle"`
+	StakingBalance              int         `json:"stakingBalance"`
+	DelegatedBalance            int         `json:"delegatedBalance"`
+	NumDelegators               int         `json:"numDelegators"`
+	ExpectedBlocks              float64     `json:"expectedBlocks"`
+	ExpectedEndorsements        float64     `json:"expectedEndorsements"`
+	FutureBlocks                int         `json:"futureBlocks"`
+	FutureBlockRewards          int         `json:"futureBlockRewards"`
+	FutureBlockDeposits         int         `json:"futureBlockDeposits"`
+	OwnBlocks                   int         `json:"ownBlocks"`
+	OwnBlockRewards             int         `json:"ownBlockRewards"`
+	ExtraBlocks                 int         `json:"extraBlocks"`
+	ExtraBlockRewards           int         `json:"extraBlockRewards"`
+	MissedOwnBlocks             int         `json:"missedOwnBlocks"`
+	MissedOwnBlockRewards       int         `json:"missedOwnBlockRewards"`
+	MissedExtraBlocks           int         `json:"missedExtraBlocks"`
+	MissedExtraBlockRewards     int         `json:"missedExtraBlockRewards"`
+	UncoveredOwnBlocks          int         `json:"uncoveredOwnBlocks"`
+	UncoveredOwnBlockRewards    int         `json:"uncoveredOwnBlockRewards"`
+	UncoveredExtraBlocks        int         `json:"uncoveredExtraBlocks"`
+	UncoveredExtraBlockRewards  int         `json:"uncoveredExtraBlockRewards"`
+	BlockDeposits               int         `json:"blockDeposits"`
+	FutureEndorsements          int         `json:"futureEndorsements"`
+	FutureEndorsementRewards    int         `json:"futureEndorsementRewards"`
+	FutureEndorsementDeposits   int         `json:"futureEndorsementDeposits"`
+	Endorsements                int         `json:"endorsements"`
+	EndorsementRewards          int         `json:"endorsementRewards"`
+	MissedEndorsements          int         `json:"missedEndorsements"`
+	MissedEndorsementRewards    int         `json:"missedEndorsementRewards"`
+	UncoveredEndorsements       int         `json:"uncoveredEndorsements"`
+	UncoveredEndorsementRewards int         `json:"uncoveredEndorsementRewards"`
+	EndorsementDeposits         int         `json:"endorsementDeposits"`
+	OwnBlockFees                int         `json:"ownBlockFees"`
+	ExtraBlockFees              int         `json:"extraBlockFees"`
+	MissedOwnBlockFees          int         `json:"missedOwnBlockFees"`
+	MissedExtraBlockFees        int         `json:"missedExtraBlockFees"`
+	UncoveredOwnBlockFees       int         `json:"uncoveredOwnBlockFees"`
+	UncoveredExtraBlockFees     int         `json:"uncoveredExtraBlockFees"`
+	DoubleBakingRewards         int         `json:"doubleBakingRewards"`
+	DoubleBakingLostDeposits    int         `json:"doubleBakingLostDeposits"`
+	DoubleBakingLostRewards     int         `json:"doubleBakingLostRewards"`
+	DoubleBakingLostFees        int         `json:"doubleBakingLostFees"`
+	DoubleEndorsingRewards      int         `json:"doubleEndorsingRewards"`
+	DoubleEndorsingLostDeposits int         `json:"doubleEndorsingLostDeposits"`
+	DoubleEndorsingLostRewards  int         `json:"doubleEndorsingLostRewards"`
+	DoubleEndorsingLostFees     int         `json:"doubleEndorsingLostFees"`
+	RevelationRewards           int         `json:"revelationRewards"`
+	RevelationLostRewards       int         `json:"revelationLostRewards"`
+	RevelationLostFees          int         `json:"revelationLostFees"`
+	Delegators                  Delegators  `json:"delegators"`
+	OperationLink               []string    `json:"operation_links,omitempty"`
+	BakerRewards                int         `json:"baker_rewards,omitempty"`
+	BakerShare                  float64     `json:"baker_share,omitempty"`
+	BakerCollectedFees          int         `json:"collected_fees,omitempty"`
+	Dust                        int         `json:"dust,omitempty"`
+	SkippedRewards              *Skipped    `json:"skipped_rewards,omitempty"`
+	Reveal                      *Reveal     `json:"reveal,omitempty"`
+	Accounting                  *Accounting `json:"accounting,omitempty"`
+	Model                       string      `json:"model,omitempty"`
+	Quotes                      *Valuation  `json:"quotes,omitempty"`
+	BakerRewardsFiat            *Valuation  `json:"baker_rewards_fiat,omitempty"`
+	BakerCollectedFeesFiat      *Valuation  `json:"collected_fees_fiat,omitempty"`
+	StakingRewards
+}
+
+// Reveal is the reveal of the payout wallet's public key a payout was injected with
+type Reveal struct {
+	PublicKey     string `json:"public_key"`
+	Fee           int    `json:"fee"`
+	OperationHash string `json:"operation_hash,omitempty"`
 }
 
+/*
+StakingRewards is the part of a rewards split tzkt reports for protocols splitting a delegate's stake into
+staked funds and delegated balances (Paris onward). The rewards of staked funds are paid on chain, those of
+delegated balances are paid to the delegate, which shares them with its delegators off chain. Endorsements
+are called attestations from Paris on, which tzkt's fields follow.
+*/
+type StakingRewards struct {
+	OwnDelegatedBalance             int     `json:"ownDelegatedBalance,omitempty"`
+	ExternalDelegatedBalance        int     `json:"externalDelegatedBalance,omitempty"`
+	DelegatorsCount                 int     `json:"delegatorsCount,omitempty"`
+	OwnStakedBalance                int     `json:"ownStakedBalance,omitempty"`
+	ExternalStakedBalance           int     `json:"externalStakedBalance,omitempty"`
+	StakersCount                    int     `json:"stakersCount,omitempty"`
+	ExpectedAttestations            float64 `json:"expectedAttestations,omitempty"`
+	Blocks                          int     `json:"blocks,omitempty"`
+	BlockRewardsDelegated           int     `json:"blockRewardsDelegated,omitempty"`
+	BlockRewardsStakedOwn           int     `json:"blockRewardsStakedOwn,omitempty"`
+	BlockRewardsStakedEdge          int     `json:"blockRewardsStakedEdge,omitempty"`
+	BlockRewardsStakedShared        int     `json:"blockRewardsStakedShared,omitempty"`
+	MissedBlocks                    int     `json:"missedBlocks,omitempty"`
+	MissedBlockRewards              int     `json:"missedBlockRewards,omitempty"`
+	FutureAttestations              int     `json:"futureAttestations,omitempty"`
+	FutureAttestationRewards        int     `json:"futureAttestationRewards,omitempty"`
+	Attestations                    int     `json:"attestations,omitempty"`
+	AttestationRewardsDelegated     int     `json:"attestationRewardsDelegated,omitempty"`
+	AttestationRewardsStakedOwn     int     `json:"attestationRewardsStakedOwn,omitempty"`
+	AttestationRewardsStakedEdge    int     `json:"attestationRewardsStakedEdge,omitempty"`
+	AttestationRewardsStakedShared  int     `json:"attestationRewardsStakedShared,omitempty"`
+	MissedAttestations              int     `json:"missedAttestations,omitempty"`
+	MissedAttestationRewards        int     `json:"missedAttestationRewards,omitempty"`
+	BlockFees                       int     `json:"blockFees,omitempty"`
+	MissedBlockFees                 int     `json:"missedBlockFees,omitempty"`
+	DoubleAttestingRewards          int     `json:"doubleAttestingRewards,omitempty"`
+	DoublePreattestingRewards       int     `json:"doublePreattestingRewards,omitempty"`
+	VdfRevelationRewardsDelegated   int     `json:"vdfRevelationRewardsDelegated,omitempty"`
+	NonceRevelationRewardsDelegated int     `json:"nonceRevelationRewardsDelegated,omitempty"`
+}
+
+// delegatorsPerPage is the number of delegators requested per page of a rewards split
+const delegatorsPerPage = 1000
+
 /*
 GetRewardsSplit -
 See: https://api.tzkt.io/#operation/Rewards_GetRewardSplit
+The delegators are paged through until all NumDelegators are fetched, whatever page size the api allows. The
+caller's limit and offset options are replaced by the paging's own.
 */
 func (t *Tzkt) GetRewardsSplit(delegate string, cycle int, options ...URLParameters) (RewardsSplit, error) {
-	resp, err := t.get(fmt.Sprintf("/v1/rewards/split/%s/%d", delegate, cycle), options...)
-	if err != nil {
-		return RewardsSplit{}, errors.Wrapf(err, "failed to get reward split")
-	}
+	options = unpaged(options)
 
 	var rewardsSplit RewardsSplit
-	if err := json.Unmarshal(resp, &rewardsSplit); err != nil {
-		return RewardsSplit{}, errors.Wrap(err, "failed to get reward split")
+	for {
+		opts := append([]URLParameters{
+			{Key: "offset", Value: strconv.Itoa(len(rewardsSplit.Delegators))},
+			{Key: "limit", Value: strconv.Itoa(delegatorsPerPage)},
+		}, options...)
+
+		resp, err := t.get(fmt.Sprintf("/v1/rewards/split/%s/%d", delegate, cycle), opts...)
+		if err != nil {
+			return RewardsSplit{}, errors.Wrapf(err, "failed to get reward split")
+		}
+
+		var page RewardsSplit
+		if err := json.Unmarshal(resp, &page); err != nil {
+			return RewardsSplit{}, errors.Wrap(err, "failed to get reward split")
+		}
+
+		delegators := append(rewardsSplit.Delegators, page.Delegators...)
+		rewardsSplit = page
+		rewardsSplit.Delegators = delegators
+
+		if len(page.Delegators) == 0 || len(rewardsSplit.Delegators) >= rewardsSplit.delegatorsCount() {
+			break
+		}
+	}
+
+	if len(rewardsSplit.Delegators) != rewardsSplit.delegatorsCount() {
+		return RewardsSplit{}, errors.Errorf("failed to get reward split: got %d delegators of %d", len(rewardsSplit.Delegators), rewardsSplit.delegatorsCount())
 	}
 
 	return rewardsSplit, nil
 }
+
+// delegatorsCount returns how many delegators the split has, which tzkt counts in DelegatorsCount for protocols with staking
+func (r RewardsSplit) delegatorsCount() int {
+	if r.DelegatorsCount > 0 {
+		return r.DelegatorsCount
+	}
+
+	return r.NumDelegators
+}
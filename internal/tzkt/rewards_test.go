@@ -0,0 +1,136 @@
+package tzkt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetRewardsSplit(t *testing.T) {
+	delegators := func(n int) Delegators {
+		var delegators Delegators
+		for i := 0; i < n; i++ {
+			delegators = append(delegators, Delegator{Address: fmt.Sprintf("tz1%d", i), Balance: i})
+		}
+		return delegators
+	}
+
+	// serves split with the delegators in pages of at most maxLimit, like the public api does
+	server := func(split RewardsSplit, delegators Delegators, maxLimit int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the api takes the first of repeated keys
+			if len(r.URL.Query()["offset"]) > 1 || len(r.URL.Query()["limit"]) > 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			if limit > maxLimit {
+				limit = maxLimit
+			}
+
+			page := Delegators{}
+			for i := offset; i < offset+limit && i < len(delegators); i++ {
+				page = append(page, delegators[i])
+			}
+
+			split.Delegators = page
+			json.NewEncoder(w).Encode(split)
+		}))
+	}
+
+	legacy := func(n int) RewardsSplit { return RewardsSplit{Cycle: 270, NumDelegators: n} }
+	staking := func(n int) RewardsSplit {
+		return RewardsSplit{Cycle: 270, StakingRewards: StakingRewards{DelegatorsCount: n}}
+	}
+
+	type want struct {
+		err        bool
+		contains   string
+		delegators Delegators
+	}
+
+	cases := []struct {
+		name    string
+		server  *httptest.Server
+		options []URLParameters
+		want    want
+	}{
+		{"is successful with one page", server(legacy(50), delegators(50), 100), nil, want{false, "", delegators(50)}},
+		{"is successful with many pages", server(legacy(250), delegators(250), 100), nil, want{false, "", delegators(250)}},
+		{"is successful without delegators", server(legacy(0), nil, 100), nil, want{false, "", nil}},
+		{"handles missing delegators", server(legacy(251), delegators(250), 100), nil, want{true, "got 250 delegators of 251", nil}},
+		{"handles extra delegators", server(legacy(50), delegators(60), 100), nil, want{true, "got 60 delegators of 50", nil}},
+		{"is successful with staking", server(staking(250), delegators(250), 100), nil, want{false, "", delegators(250)}},
+		{"handles missing delegators with staking", server(staking(251), delegators(250), 100), nil, want{true, "got 250 delegators of 251", nil}},
+		{"is successful with paging options", server(legacy(250), delegators(250), 100), []URLParameters{{Key: "limit", Value: "10"}, {Key: "offset", Value: "5"}}, want{false, "", delegators(250)}},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			defer tt.server.Close()
+
+			rewardsSplit, err := NewTZKTWithOptions(tt.server.URL, Options{}).GetRewardsSplit("tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", 270, tt.options...)
+			if tt.want.err {
+				assert.NotNil(t, err)
+				assert.Contains(t, err.Error(), tt.want.contains)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, 270, rewardsSplit.Cycle)
+			}
+			assert.Equal(t, tt.want.delegators, rewardsSplit.Delegators)
+		})
+	}
+}
+
+// Test_GetRewardsSplit_paris decodes the rewards split tzkt serves for a cycle run by Paris
+func Test_GetRewardsSplit_paris(t *testing.T) {
+	byts, err := ioutil.ReadFile(filepath.Join("testdata", "rewards-split-750.json"))
+	assert.Nil(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(byts)
+	}))
+	defer server.Close()
+
+	rewardsSplit, err := NewTZKTWithOptions(server.URL, Options{}).GetRewardsSplit("tz1SUgyRB8T5jXgXAwS33pgRHAKrafyg87Yc", 750)
+	assert.Nil(t, err)
+	assert.Equal(t, StakingRewards{
+		OwnDelegatedBalance:             5000000000,
+		ExternalDelegatedBalance:        233387898834,
+		DelegatorsCount:                 4,
+		OwnStakedBalance:                300000000000,
+		ExternalStakedBalance:           10000000000,
+		StakersCount:                    1,
+		ExpectedAttestations:            107842.55,
+		Blocks:                          16,
+		BlockRewardsDelegated:           18575431,
+		BlockRewardsStakedOwn:           46751010,
+		BlockRewardsStakedEdge:          2337550,
+		BlockRewardsStakedShared:        1558366,
+		Attestations:                    107721,
+		AttestationRewardsDelegated:     26514328,
+		AttestationRewardsStakedOwn:     66731907,
+		AttestationRewardsStakedEdge:    3336595,
+		AttestationRewardsStakedShared:  2224397,
+		MissedAttestations:              121,
+		MissedAttestationRewards:        1047561,
+		BlockFees:                       183204,
+		NonceRevelationRewardsDelegated: 71402,
+	}, rewardsSplit.StakingRewards)
+	assert.Equal(t, 15.41, rewardsSplit.ExpectedBlocks)
+	assert.Equal(t, Delegators{
+		{Address: "tz1icdoLr8vof5oXiEKCFSyrVoouGiKDQ3Gd", DelegatedBalance: 60545965782, StakedBalance: 10000000000},
+		{Address: "KT1FPyY6mAhnzyVGP8ApGvuRyF7SKcT9TDWy", DelegatedBalance: 60075572992},
+		{Address: "KT1LgkGigaMrnim3TonQWfwDHnM3fHkF1jMv", DelegatedBalance: 57461165021},
+		{Address: "KT1C8S2vLYbzgQHhdC8MBehunhcp1Q9hj6MC", DelegatedBalance: 55305195039},
+	}, rewardsSplit.Delegators)
+}
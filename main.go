@@ -14,6 +14,10 @@ func main() {
 		cmd.DryRunCommand(),
 		cmd.ServCommand(),
 		cmd.RunCommand(),
+		cmd.ReconcileCommand(),
+		cmd.TrueUpCommand(),
+		cmd.ExportCommand(),
+		cmd.PnLCommand(),
 		cmd.NewVersionCommand(),
 		cmd.NewSetupCommand(),
 	)